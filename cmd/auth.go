@@ -1,8 +1,14 @@
 package cmd
 
 import (
+	"bufio"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"os"
+	"strings"
 	"time"
 
 	"github.com/AustinMusiku/spotifycli/internal/api"
@@ -15,9 +21,18 @@ import (
 var loginCmd = &cobra.Command{
 	Use:   "login",
 	Short: "Authenticate with Spotify",
-	Long:  `Authenticate with Spotify using OAuth2 PKCE flow. This will open your browser for authorization.`,
+	Long: `Authenticate with Spotify using OAuth2 PKCE flow. This will open your browser for authorization.
+
+On remote machines where the browser cannot reach the local callback server,
+use --no-browser and paste the URL you were redirected to back into the terminal.
+
+The scopes requested can be replaced with --scopes, which is remembered for the
+profile. --add-scope re-authorizes with the scopes already granted plus new ones.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		return runLogin()
+		noBrowser, _ := cmd.Flags().GetBool("no-browser")
+		scopes, _ := cmd.Flags().GetStringSlice("scopes")
+		addScopes, _ := cmd.Flags().GetStringSlice("add-scope")
+		return runLogin(cmd.Context(), noBrowser, scopes, addScopes)
 	},
 }
 
@@ -30,91 +45,173 @@ var logoutCmd = &cobra.Command{
 	},
 }
 
+// authCmd represents the auth commands group
+var authCmd = &cobra.Command{
+	Use:   "auth",
+	Short: "Inspect authentication",
+	Long:  `Inspect the authentication state of the current profile.`,
+}
+
+var authStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show who you are logged in as",
+	Long: `Show the logged in Spotify user, granted scopes, token expiry and where credentials are stored.
+
+Exits with a non-zero status when not authenticated, so it can be used as a health check.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		asJSON, _ := cmd.Flags().GetBool("json")
+		return runAuthStatus(cmd.Context(), asJSON)
+	},
+}
+
+var whoamiCmd = &cobra.Command{
+	Use:   "whoami",
+	Short: "Show who you are logged in as",
+	Long:  `Show the logged in Spotify user. Same as 'auth status'.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		asJSON, _ := cmd.Flags().GetBool("json")
+		return runAuthStatus(cmd.Context(), asJSON)
+	},
+}
+
 func init() {
 	rootCmd.AddCommand(loginCmd)
 	rootCmd.AddCommand(logoutCmd)
+	rootCmd.AddCommand(authCmd)
+	authCmd.AddCommand(authStatusCmd)
+	rootCmd.AddCommand(whoamiCmd)
+
+	authStatusCmd.Flags().Bool("json", false, "Output as JSON")
+	whoamiCmd.Flags().Bool("json", false, "Output as JSON")
+
+	loginCmd.Flags().Bool("no-browser", false, "Don't start a callback server; paste the redirect URL or code instead")
+	loginCmd.Flags().StringSlice("scopes", nil, "Comma separated scopes to request instead of the defaults")
+	loginCmd.Flags().StringSlice("add-scope", nil, "Scope to add to those already granted (repeatable)")
 }
 
-func runLogin() error {
-	cfg, err := config.LoadConfig()
+func runLogin(ctx context.Context, noBrowser bool, scopes, addScopes []string) error {
+	cfg, err := loadConfig()
 	if err != nil {
-		return fmt.Errorf("failed to load config: %w", err)
+		return err
 	}
 
+	if cfg.TokensFromEnv() {
+		ui.PrintInfo("Using the refresh token from SPOTIFYCLI_REFRESH_TOKEN, no login needed")
+		return nil
+	}
+
+	// Logging in again is the way out of tokens that can't be decrypted
+	if err := cfg.LoadTokens(); errors.Is(err, config.ErrTokenDecrypt) {
+		ui.PrintWarning(fmt.Sprintf("The stored tokens for profile %s could not be decrypted and will be replaced", cfg.Name()))
+		cfg.ClearTokens()
+	} else if err != nil {
+		return err
+	}
+
+	changingScopes := len(scopes) > 0 || len(addScopes) > 0
+
 	// Check if already authenticated
-	if cfg.IsAuthenticated() && !cfg.IsTokenExpired() {
+	if cfg.IsAuthenticated() && !cfg.IsTokenExpired() && !changingScopes {
 		ui.PrintInfo("Already authenticated with Spotify")
 		return nil
 	}
 
+	// A single reader so piped input isn't lost between prompts
+	stdin := bufio.NewReader(os.Stdin)
+
 	// Get client ID if not set
 	if cfg.ClientID == "" {
 		ui.PrintInfo("Please enter your Spotify Client ID:")
 		fmt.Print("Client ID: ")
 
-		var clientID string
-		fmt.Scanln(&clientID)
+		clientID, err := readLine(ctx, stdin)
+		if err != nil && err != io.EOF {
+			return fmt.Errorf("failed to read client ID: %w", err)
+		}
 
 		if clientID == "" {
-			return fmt.Errorf("client ID is required")
+			return fmt.Errorf("client ID is required, enter it or set SPOTIFYCLI_CLIENT_ID")
 		}
 
 		cfg.ClientID = clientID
 	}
 
-	if cfg.Port == "" {
-		cfg.Port = "8080"
+	requested := cfg.Scopes
+	if len(requested) == 0 {
+		requested = auth.DefaultScopes
 	}
 
-	if cfg.RedirectPath == "" {
-		cfg.RedirectPath = "callback"
+	if len(scopes) > 0 {
+		requested = scopes
 	}
 
-	// Create PKCE auth handler
-	redirectURI := fmt.Sprintf("http://127.0.0.1:%s/%s", cfg.Port, cfg.RedirectPath)
-	pkceAuth := auth.NewPKCEAuth(cfg.ClientID, redirectURI)
-
-	// Fire up callback server
-	server := auth.NewCallbackServer(cfg.Port)
-	if err := server.Start(); err != nil {
-		return fmt.Errorf("failed to start callback server: %w", err)
+	requested = auth.MergeScopes(requested, addScopes)
+	if err := auth.ValidateScopes(requested); err != nil {
+		return err
 	}
-	defer server.Stop()
 
-	// Get authorization URL
-	authURL := pkceAuth.GetAuthURL()
+	if changingScopes {
+		cfg.Scopes = requested
+	}
 
-	ui.PrintInfo("Opening browser for authentication...")
-	ui.PrintInfo(fmt.Sprintf("If the browser doesn't open automatically, visit: %s", authURL))
+	// Adding a scope keeps everything granted so far
+	if len(addScopes) > 0 {
+		requested = auth.MergeScopes(cfg.GrantedScopes(), requested)
+	}
 
-	// TODO: Autonmatically open browser
+	// Create PKCE auth handler. With a port range the callback server
+	// replaces the redirect URI with the port it actually bound.
+	firstPort, _, err := auth.ParsePortRange(cfg.Port)
+	if err != nil {
+		return err
+	}
+	redirectURI := fmt.Sprintf("http://127.0.0.1:%d/%s", firstPort, strings.TrimPrefix(cfg.RedirectPath, "/"))
+	pkceAuth := auth.NewPKCEAuth(cfg.ClientID, redirectURI)
+	pkceAuth.Scopes = requested
 
-	ui.PrintInfo("Waiting for authentication...")
-	code, state, err := server.WaitForCallback(5 * time.Minute)
+	httpClient, err := newHTTPClient(cfg)
 	if err != nil {
-		return fmt.Errorf("authentication failed: %w", err)
+		return err
 	}
 
-	// Verify state
-	if state != pkceAuth.State {
-		return fmt.Errorf("invalid state parameter")
+	pkceAuth.Endpoint = auth.Endpoint{
+		AccountsURL: cfg.AccountsURL,
+		HTTPClient:  httpClient,
+	}
+
+	var code string
+	if noBrowser {
+		code, err = waitForPastedCode(ctx, pkceAuth, stdin)
+	} else {
+		code, err = waitForCallbackCode(ctx, pkceAuth, cfg.Port, cfg.RedirectPath)
+	}
+	if err != nil {
+		return err
 	}
 
 	// Exchange code for token
-	ctx := context.Background()
 	token, err := pkceAuth.ExchangeCode(ctx, code)
 	if err != nil {
 		return fmt.Errorf("failed to exchange code for token: %w", err)
 	}
 
-	cfg.SetTokens(token.AccessToken, token.RefreshToken, token.TokenType, 3600) // Default 1 hour
+	cfg.SetTokens(token.AccessToken, token.RefreshToken, token.TokenType, auth.ExpiresIn(token))
+
+	cfg.Scope = auth.GrantedScope(token)
+	if cfg.Scope == "" {
+		cfg.Scope = strings.Join(requested, " ")
+	}
 	if err := cfg.Save(); err != nil {
 		return fmt.Errorf("failed to save tokens: %w", err)
 	}
 
 	// Test authentication
-	client := api.NewClient(cfg)
-	if err := client.Authenticate(token.AccessToken); err != nil {
+	client, err := newClient(cfg)
+	if err != nil {
+		return err
+	}
+
+	if err := client.Authenticate(ctx, token.AccessToken); err != nil {
 		return fmt.Errorf("authentication test failed: %w", err)
 	}
 
@@ -122,17 +219,210 @@ func runLogin() error {
 	return nil
 }
 
+// waitForCallbackCode runs the local callback server and waits for the browser redirect
+func waitForCallbackCode(ctx context.Context, pkceAuth *auth.PKCEAuth, ports, redirectPath string) (string, error) {
+	// Fire up callback server
+	server := auth.NewCallbackServer(ports, redirectPath, pkceAuth.State)
+	if err := server.Start(); err != nil {
+		return "", fmt.Errorf("failed to start callback server: %w", err)
+	}
+	defer server.Stop()
+
+	// The port may differ from the first in the range
+	pkceAuth.RedirectURI = server.RedirectURI()
+
+	// Get authorization URL
+	authURL := pkceAuth.GetAuthURL()
+
+	ui.PrintInfo("Opening browser for authentication...")
+	ui.PrintInfo(fmt.Sprintf("If the browser doesn't open automatically, visit: %s", authURL))
+	ui.PrintInfo(fmt.Sprintf("Make sure %s is a redirect URI of your Spotify app", pkceAuth.RedirectURI))
+
+	// TODO: Autonmatically open browser
+
+	ui.PrintInfo("Waiting for authentication...")
+	code, err := server.WaitForCallback(ctx, 5*time.Minute)
+	if err != nil {
+		return "", fmt.Errorf("authentication failed: %w", err)
+	}
+
+	return code, nil
+}
+
+// waitForPastedCode asks the user to authorize in any browser and paste the
+// redirect URL (or the bare code) back, so no listener is needed
+func waitForPastedCode(ctx context.Context, pkceAuth *auth.PKCEAuth, stdin *bufio.Reader) (string, error) {
+	ui.PrintInfo("Visit the following URL in a browser on any machine:")
+	fmt.Println(pkceAuth.GetAuthURL())
+	ui.PrintInfo("After authorizing, the browser will fail to load the redirect page. That's expected.")
+	ui.PrintInfo("Paste the full URL from the address bar (or just the code):")
+	fmt.Print("Redirect URL: ")
+
+	input, err := readLine(ctx, stdin)
+	if err != nil {
+		return "", fmt.Errorf("failed to read redirect URL: %w", err)
+	}
+
+	code, err := pkceAuth.ParseRedirect(input)
+	if err != nil {
+		return "", fmt.Errorf("authentication failed: %w", err)
+	}
+
+	return code, nil
+}
+
+// readLine reads a single trimmed line, tolerating a missing trailing newline
+// on piped input. It gives up when ctx is done.
+func readLine(ctx context.Context, r *bufio.Reader) (string, error) {
+	return interruptible(ctx, func() (string, error) {
+		line, err := r.ReadString('\n')
+		if err != nil && (err != io.EOF || line == "") {
+			return "", err
+		}
+
+		return strings.TrimSpace(line), nil
+	})
+}
+
 func runLogout() error {
-	cfg, err := config.LoadConfig()
+	cfg, err := loadConfig()
 	if err != nil {
-		return fmt.Errorf("failed to load config: %w", err)
+		return err
 	}
 
+	// The tokens are dropped without decrypting them, so this also gets rid of
+	// tokens that can no longer be decrypted
 	cfg.ClearTokens()
 	if err := cfg.Save(); err != nil {
 		return fmt.Errorf("failed to save config: %w", err)
 	}
 
 	ui.PrintSuccess("Successfully logged out from Spotify")
+	if cfg.TokensFromEnv() {
+		ui.PrintWarning("SPOTIFYCLI_REFRESH_TOKEN is still set and will keep being used")
+	}
 	return nil
 }
+
+// authStatus is the output of 'auth status', also used for its JSON form
+type authStatus struct {
+	Profile        string   `json:"profile"`
+	Authenticated  bool     `json:"authenticated"`
+	Error          string   `json:"error,omitempty"`
+	DisplayName    string   `json:"display_name,omitempty"`
+	UserID         string   `json:"user_id,omitempty"`
+	Country        string   `json:"country,omitempty"`
+	Product        string   `json:"product,omitempty"`
+	Scopes         []string `json:"scopes,omitempty"`
+	TokenExpiry    int64    `json:"token_expiry,omitempty"`
+	ExpiresIn      int64    `json:"expires_in,omitempty"`
+	ConfigPath     string   `json:"config_path"`
+	TokenStore     string   `json:"token_store"`
+	KeySource      string   `json:"key_source,omitempty"`
+	AppCredentials bool     `json:"app_credentials"`
+}
+
+func runAuthStatus(ctx context.Context, asJSON bool) error {
+	cfg, err := loadLogin()
+	if err != nil {
+		return err
+	}
+
+	path, err := config.Path()
+	if err != nil {
+		return err
+	}
+
+	status := authStatus{
+		Profile:        cfg.Name(),
+		ConfigPath:     path,
+		TokenStore:     cfg.File().TokenStoreName(),
+		Scopes:         cfg.GrantedScopes(),
+		AppCredentials: cfg.HasAppCredentials(),
+	}
+
+	// The key source only applies to tokens encrypted in the config file
+	if status.TokenStore == config.TokenStoreFile {
+		status.KeySource = cfg.File().KeySourceName()
+	}
+
+	var authErr error
+	if cfg.IsAuthenticated() {
+		client, err := newClient(cfg)
+		if err != nil {
+			return err
+		}
+
+		if authErr = client.Authenticate(ctx, cfg.GetAccessToken()); authErr != nil {
+			status.Error = authErr.Error()
+		} else {
+			user := client.CurrentUser()
+			status.Authenticated = true
+			status.DisplayName = user.DisplayName
+			status.UserID = user.ID
+			status.Country = user.Country
+			status.Product = user.Product
+		}
+
+		// Authenticating may have refreshed the token
+		status.TokenExpiry = cfg.TokenExpiry
+		status.ExpiresIn = max(cfg.TokenExpiry-time.Now().Unix(), 0)
+	}
+
+	if asJSON {
+		data, err := json.MarshalIndent(status, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+	} else {
+		printAuthStatus(status)
+	}
+
+	switch {
+	case authErr == nil && !status.Authenticated:
+		return fmt.Errorf("%w, please run 'spotifycli login'", api.ErrNotAuthenticated)
+	case errors.Is(authErr, api.ErrTokenExpired):
+		// The refresh token was rejected, so there is no login left
+		return fmt.Errorf("%w: %w", api.ErrNotAuthenticated, authErr)
+	case authErr != nil:
+		return fmt.Errorf("failed to check the login: %w", authErr)
+	}
+
+	return nil
+}
+
+func printAuthStatus(status authStatus) {
+	if status.Authenticated {
+		fmt.Printf("👤 %s (%s)\n", ui.BoldColor.Sprint(status.DisplayName), status.UserID)
+		fmt.Printf("   Country: %s\n", status.Country)
+		fmt.Printf("   Product: %s\n", status.Product)
+	} else if status.Error != "" {
+		ui.PrintError(status.Error)
+	} else {
+		ui.PrintWarning("Not logged in")
+	}
+
+	if len(status.Scopes) > 0 {
+		fmt.Printf("   Scopes: %s\n", strings.Join(status.Scopes, ", "))
+	}
+
+	if status.TokenExpiry > 0 {
+		expiresIn := time.Duration(status.ExpiresIn) * time.Second
+		if expiresIn > 0 {
+			fmt.Printf("   Token expires in: %s\n", expiresIn)
+		} else {
+			fmt.Printf("   Token expired\n")
+		}
+	}
+
+	fmt.Printf("   Profile: %s\n", status.Profile)
+	fmt.Printf("   Config: %s\n", status.ConfigPath)
+	fmt.Printf("   Token store: %s\n", status.TokenStore)
+	if status.KeySource != "" {
+		fmt.Printf("   Key source: %s\n", status.KeySource)
+	}
+	if status.AppCredentials {
+		fmt.Printf("   App credentials: configured (search works without a login)\n")
+	}
+}
@@ -0,0 +1,297 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AustinMusiku/spotifycli/internal/api"
+	"github.com/AustinMusiku/spotifycli/spotifytest"
+)
+
+func TestLogin(t *testing.T) {
+	srv := newServer(t)
+
+	login := start(t, "login", "--set", "port="+freePort(t))
+
+	// Follow the printed link as the browser would; the fake approves it
+	// straight away and redirects to the callback server
+	authURL := waitForAuthURL(t, login)
+	resp, err := http.Get(authURL)
+	if err != nil {
+		t.Fatalf("failed to follow the authorization URL: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("callback answered %s", resp.Status)
+	}
+
+	out, err := login.wait(t)
+	if err != nil {
+		t.Fatalf("login: %v\n%s", err, out)
+	}
+	assertOutput(t, out, "Successfully authenticated with Spotify!")
+
+	assertCalls(t, srv,
+		"302 GET /authorize",
+		"200 POST /api/token",
+		"200 GET /v1/me",
+	)
+
+	cfg := loadProfile(t)
+	if !cfg.IsAuthenticated() || cfg.IsTokenExpired() {
+		t.Errorf("login did not store a valid token")
+	}
+
+	srv.ResetCalls()
+	out = mustRun(t, "whoami")
+	assertOutput(t, out, "Test User")
+	srv.AssertNotCalled(t, "POST", "/api/token")
+}
+
+func TestLoginStateMismatch(t *testing.T) {
+	srv := newServer(t)
+
+	login := start(t, "login", "--set", "port="+freePort(t), "--timeout", "2s")
+	authURL := waitForAuthURL(t, login)
+
+	// A callback that didn't come from the authorization must not end the login
+	resp, err := http.Get(strings.Replace(authURL, "state=", "state=forged", 1))
+	if err != nil {
+		t.Fatalf("failed to follow the authorization URL: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("forged callback answered %s, want 400 Bad Request", resp.Status)
+	}
+
+	_, err = login.wait(t)
+	if got := exitCode(err); got != exitTimedOut {
+		t.Errorf("login: exit code %d, want %d (%v)", got, exitTimedOut, err)
+	}
+	srv.AssertNotCalled(t, "POST", "/api/token")
+}
+
+func TestLoginNoBrowser(t *testing.T) {
+	tests := []struct {
+		name string
+		// paste is what the user pastes given the URL the browser ended up on
+		paste func(redirect *url.URL) string
+		// wantErr is part of the error expected, empty when the login succeeds
+		wantErr string
+	}{
+		{"redirect URL", func(redirect *url.URL) string { return redirect.String() }, ""},
+		{"query string", func(redirect *url.URL) string { return redirect.RawQuery }, ""},
+		{"bare code", func(redirect *url.URL) string { return redirect.Query().Get("code") }, ""},
+		{"state mismatch", func(redirect *url.URL) string {
+			q := redirect.Query()
+			q.Set("state", "forged")
+			redirect.RawQuery = q.Encode()
+			return redirect.String()
+		}, "invalid state parameter"},
+		{"denied", func(redirect *url.URL) string {
+			return redirect.Scheme + "://" + redirect.Host + redirect.Path + "?error=access_denied&state=" + redirect.Query().Get("state")
+		}, "access_denied"},
+		{"nothing", func(redirect *url.URL) string { return "" }, "no redirect URL or code"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newServer(t)
+			stdin := useStdin(t)
+
+			login := start(t, "login", "--no-browser")
+			authURL := waitForLine(t, login, "/authorize?")
+
+			// Authorize in a browser elsewhere, which can't load the redirect
+			noRedirects := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			}}
+			resp, err := noRedirects.Get(authURL)
+			if err != nil {
+				t.Fatalf("failed to follow the authorization URL: %v", err)
+			}
+			resp.Body.Close()
+			redirect, err := resp.Location()
+			if err != nil {
+				t.Fatalf("authorization did not redirect: %v", err)
+			}
+
+			fmt.Fprintln(stdin, tt.paste(redirect))
+
+			out, err := login.wait(t)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("login error %v, want %q\n%s", err, tt.wantErr, out)
+				}
+				srv.AssertNotCalled(t, "POST", "/api/token")
+				return
+			}
+			if err != nil {
+				t.Fatalf("login: %v\n%s", err, out)
+			}
+			assertOutput(t, out, "Successfully authenticated with Spotify!")
+
+			// No callback server is needed
+			if strings.Contains(out, "Waiting for authentication") {
+				t.Errorf("login waited for a callback:\n%s", out)
+			}
+			if cfg := loadProfile(t); !cfg.IsAuthenticated() {
+				t.Errorf("login did not store a token")
+			}
+		})
+	}
+}
+
+func TestRefreshExpiredToken(t *testing.T) {
+	srv := newLoggedInServer(t)
+	before := loadProfile(t)
+
+	srv.ExpireTokens()
+	mustRun(t, "devices")
+
+	// The rejected call is retried once with the refreshed token
+	assertCalls(t, srv,
+		"401 GET /v1/me",
+		"200 POST /api/token",
+		"200 GET /v1/me",
+		"200 GET /v1/me/player/devices",
+	)
+
+	after := loadProfile(t)
+	if after.GetAccessToken() == before.GetAccessToken() {
+		t.Errorf("refreshed access token was not saved")
+	}
+	if after.GetRefreshToken() != before.GetRefreshToken() {
+		t.Errorf("refresh token changed although Spotify did not rotate it")
+	}
+}
+
+func TestRefreshTokenRotation(t *testing.T) {
+	srv := newLoggedInServer(t, spotifytest.WithRefreshTokenRotation())
+	original := loadProfile(t).GetRefreshToken()
+
+	srv.ExpireTokens()
+	mustRun(t, "devices")
+
+	rotated := loadProfile(t).GetRefreshToken()
+	if rotated == original {
+		t.Fatalf("rotated refresh token was not saved")
+	}
+
+	// The old refresh token is now revoked, so the next refresh only works
+	// with the saved one
+	srv.ResetCalls()
+	srv.ExpireTokens()
+	mustRun(t, "devices")
+
+	srv.AssertCalled(t, "POST", "/api/token", 1)
+	if call := srv.CallsTo("POST", "/api/token")[0]; call.Status != http.StatusOK || !strings.Contains(string(call.Body), rotated) {
+		t.Errorf("refresh used %s and got %d, want the rotated token to work", call.Body, call.Status)
+	}
+}
+
+func TestRevokedLogin(t *testing.T) {
+	srv := newLoggedInServer(t)
+
+	srv.RevokeTokens()
+	_, err := runFailing(t, exitAuth, "devices")
+	assertErrorIs(t, err, api.ErrTokenExpired)
+
+	srv.AssertNotCalled(t, "GET", "/v1/me/player/devices")
+}
+
+func TestAuthStatus(t *testing.T) {
+	newLoggedInServer(t)
+
+	out := mustRun(t, "auth", "status")
+	assertOutput(t, out, "Test User", "Token store: file")
+}
+
+func TestAuthStatusNotLoggedIn(t *testing.T) {
+	newServer(t)
+
+	out, err := runFailing(t, exitAuth, "auth", "status")
+	assertErrorIs(t, err, api.ErrNotAuthenticated)
+	assertOutput(t, out, "Not logged in")
+}
+
+func TestAuthStatusRevoked(t *testing.T) {
+	srv := newLoggedInServer(t)
+
+	// Only a rejected refresh token means there is no login left
+	srv.RevokeTokens()
+	_, err := runFailing(t, exitAuth, "auth", "status")
+	assertErrorIs(t, err, api.ErrNotAuthenticated)
+	assertErrorIs(t, err, api.ErrTokenExpired)
+}
+
+func TestAuthStatusUnavailable(t *testing.T) {
+	srv := newLoggedInServer(t)
+	srv.Fail(spotifytest.Failure{Method: "GET", Path: "/v1/me", Status: http.StatusServiceUnavailable, Times: -1})
+
+	// Spotify being down says nothing about the login
+	out, err := runFailing(t, exitUnavailable, "auth", "status", "--set", "max_retries=0")
+	assertErrorIs(t, err, api.ErrUnavailable)
+	if errors.Is(err, api.ErrNotAuthenticated) {
+		t.Errorf("error %v, want the login not blamed", err)
+	}
+	assertOutput(t, out, "temporarily unavailable")
+}
+
+// useStdin replaces stdin with a pipe for the test and returns its write end
+func useStdin(t *testing.T) *os.File {
+	t.Helper()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stdin := os.Stdin
+	os.Stdin = r
+	t.Cleanup(func() {
+		os.Stdin = stdin
+		w.Close()
+		r.Close()
+	})
+	return w
+}
+
+// waitForLine returns the first line the command prints containing substr
+func waitForLine(t *testing.T, e *execution, substr string) string {
+	t.Helper()
+
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		// The last line may not be finished yet
+		lines := strings.Split(e.output(), "\n")
+		for _, line := range lines[:len(lines)-1] {
+			if strings.Contains(line, substr) {
+				return strings.TrimSpace(line)
+			}
+		}
+
+		select {
+		case <-e.done:
+			t.Fatalf("command finished without printing %q: %v\n%s", substr, e.err, e.output())
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	t.Fatalf("command did not print %q:\n%s", substr, e.output())
+	return ""
+}
+
+// waitForAuthURL returns the authorization URL printed by a login
+func waitForAuthURL(t *testing.T, login *execution) string {
+	t.Helper()
+
+	_, authURL, _ := strings.Cut(waitForLine(t, login, "visit: "), "visit: ")
+	return strings.TrimSpace(authURL)
+}
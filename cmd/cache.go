@@ -0,0 +1,138 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/AustinMusiku/spotifycli/internal/cache"
+	"github.com/AustinMusiku/spotifycli/internal/config"
+	"github.com/AustinMusiku/spotifycli/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Manage the response cache",
+	Long: `Manage the on-disk cache of catalog lookups and search results.
+
+Tracks, albums, artists and shows are cached for a day and search results for
+an hour, after which they are revalidated with Spotify. Player state, playlists
+and your library are never cached. Use --no-cache to bypass the cache for a
+single command.`,
+}
+
+var cacheClearCmd = &cobra.Command{
+	Use:   "clear",
+	Short: "Remove every cached response",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runCacheClear()
+	},
+}
+
+var cacheStatsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Show what the cache holds",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runCacheStats()
+	},
+}
+
+var noCache bool
+
+func init() {
+	rootCmd.AddCommand(cacheCmd)
+	cacheCmd.AddCommand(cacheClearCmd)
+	cacheCmd.AddCommand(cacheStatsCmd)
+
+	rootCmd.PersistentFlags().BoolVar(&noCache, "no-cache", false, "Don't read or write the response cache")
+}
+
+// openCache opens the profile's response cache, nil when it is disabled
+func openCache(cfg *config.Config) (*cache.Cache, error) {
+	maxSize := cfg.CacheMaxSize()
+	if maxSize == 0 {
+		return nil, nil
+	}
+
+	dir, err := cache.Dir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to find cache directory: %w", err)
+	}
+
+	c := cache.New(dir, maxSize)
+
+	out, err := debugOutput()
+	if err != nil {
+		return nil, err
+	}
+	c.SetDebug(out)
+
+	return c, nil
+}
+
+func runCacheClear() error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	c, err := openCache(cfg)
+	if err != nil {
+		return err
+	}
+
+	if c == nil {
+		// A disabled cache may still hold entries from before
+		dir, err := cache.Dir()
+		if err != nil {
+			return err
+		}
+		c = cache.New(dir, 0)
+	}
+
+	removed, err := c.Clear()
+	if err != nil {
+		return err
+	}
+
+	ui.PrintSuccess(fmt.Sprintf("Removed %d cached response(s)", removed))
+	return nil
+}
+
+func runCacheStats() error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	c, err := openCache(cfg)
+	if err != nil {
+		return err
+	}
+
+	if c == nil {
+		ui.PrintInfo("The response cache is disabled, set cache_size to enable it")
+		return nil
+	}
+
+	stats, err := c.Stats()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("📦 Response cache: %s\n", stats.Dir)
+	fmt.Printf("   Entries: %d (%d fresh)\n", stats.Entries, stats.Fresh)
+	fmt.Printf("   Size: %s of %s\n", formatSize(stats.Size), formatSize(stats.MaxSize))
+
+	return nil
+}
+
+// formatSize formats a size in bytes for display
+func formatSize(size int64) string {
+	switch {
+	case size >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(size)/(1<<20))
+	case size >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(size)/(1<<10))
+	}
+	return fmt.Sprintf("%d B", size)
+}
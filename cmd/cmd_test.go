@@ -0,0 +1,296 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/AustinMusiku/spotifycli/internal/config"
+	"github.com/AustinMusiku/spotifycli/spotifytest"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// The tests in this package run commands the way the spotifycli binary does,
+// against the fake Spotify server in spotifytest. Each test gets a config
+// file and cache of its own, so commands run one at a time, never in parallel.
+
+func TestMain(m *testing.M) {
+	markUsageErrors(rootCmd)
+	reportCancellation(rootCmd)
+
+	rootCmd.SetErr(io.Discard)
+	color.NoColor = true
+
+	os.Exit(m.Run())
+}
+
+// newServer starts a fake Spotify server and points the default profile of a
+// new config file at it, without logging in
+func newServer(t *testing.T, opts ...spotifytest.Option) *spotifytest.Server {
+	t.Helper()
+
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(dir, "config"))
+	t.Setenv("XDG_CACHE_HOME", filepath.Join(dir, "cache"))
+	t.Setenv("SPOTIFYCLI_CONFIG", filepath.Join(dir, "config", "spotifycli.json"))
+
+	// Nothing from the environment running the tests may leak in
+	for _, name := range []string{"SPOTIFYCLI_PROFILE", "SPOTIFYCLI_REFRESH_TOKEN", "SPOTIFYCLI_ACCESS_TOKEN", "SPOTIFYCLI_KEY", "SPOTIFYCLI_PASSPHRASE", "SPOTIFYCLI_DEBUG", "SPOTIFYCLI_DEBUG_FILE"} {
+		t.Setenv(name, "")
+	}
+	for _, s := range config.Settings() {
+		t.Setenv("SPOTIFYCLI_"+strings.ToUpper(s.Key), "")
+	}
+
+	srv := spotifytest.NewServer(opts...)
+	t.Cleanup(srv.Close)
+
+	file, err := config.LoadFile()
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+
+	profile, err := file.Profile(config.DefaultProfile)
+	if err != nil {
+		t.Fatalf("failed to get profile: %v", err)
+	}
+	profile.ClientID = "spotifytest"
+	profile.AccountsURL = srv.AccountsURL()
+	profile.APIURL = srv.APIURL()
+
+	if err := file.Save(); err != nil {
+		t.Fatalf("failed to save config: %v", err)
+	}
+
+	return srv
+}
+
+// newLoggedInServer is newServer with the default profile logged in
+func newLoggedInServer(t *testing.T, opts ...spotifytest.Option) *spotifytest.Server {
+	t.Helper()
+
+	srv := newServer(t, opts...)
+
+	profile := loadProfile(t)
+	token := srv.IssueToken()
+	profile.SetTokens(token.AccessToken, token.RefreshToken, token.TokenType, token.ExpiresIn)
+	profile.Scope = token.Scope
+
+	if err := profile.Save(); err != nil {
+		t.Fatalf("failed to save tokens: %v", err)
+	}
+
+	return srv
+}
+
+// loadProfile loads the default profile along with its tokens
+func loadProfile(t *testing.T) *config.Config {
+	t.Helper()
+
+	cfg, err := config.LoadProfile(config.DefaultProfile)
+	if err != nil {
+		t.Fatalf("failed to load profile: %v", err)
+	}
+	if err := cfg.LoadTokens(); err != nil {
+		t.Fatalf("failed to load tokens: %v", err)
+	}
+	return cfg
+}
+
+// execution is a command started with start
+type execution struct {
+	done chan struct{}
+	err  error
+
+	mu  sync.Mutex
+	out bytes.Buffer
+}
+
+// start runs spotifycli with args in the background, capturing its output.
+// Only one command may run at a time.
+func start(t *testing.T, args ...string) *execution {
+	t.Helper()
+
+	return startContext(t, context.Background(), args...)
+}
+
+// startContext is start with a context to cancel, as Ctrl-C would
+func startContext(t *testing.T, ctx context.Context, args ...string) *execution {
+	t.Helper()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("failed to capture output: %v", err)
+	}
+
+	stdout, colorOutput := os.Stdout, color.Output
+	os.Stdout, color.Output = w, w
+
+	e := &execution{done: make(chan struct{})}
+	copied := make(chan struct{})
+	go func() {
+		defer close(copied)
+
+		buf := make([]byte, 4096)
+		for {
+			n, err := r.Read(buf)
+			e.mu.Lock()
+			e.out.Write(buf[:n])
+			e.mu.Unlock()
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	resetCommands(rootCmd)
+	rootCmd.SetArgs(args)
+
+	go func() {
+		defer close(e.done)
+
+		e.err = rootCmd.ExecuteContext(ctx)
+		stopTimeout()
+
+		w.Close()
+		<-copied
+		r.Close()
+		os.Stdout, color.Output = stdout, colorOutput
+	}()
+
+	return e
+}
+
+// output returns what the command has printed so far
+func (e *execution) output() string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.out.String()
+}
+
+// wait waits for the command to finish and returns its output and error
+func (e *execution) wait(t *testing.T) (string, error) {
+	t.Helper()
+
+	select {
+	case <-e.done:
+	case <-time.After(30 * time.Second):
+		t.Fatalf("command did not finish, output so far:\n%s", e.output())
+	}
+	return e.output(), e.err
+}
+
+// run runs spotifycli with args and returns its output and error
+func run(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+
+	return start(t, args...).wait(t)
+}
+
+// mustRun runs spotifycli with args, failing the test if the command fails
+func mustRun(t *testing.T, args ...string) string {
+	t.Helper()
+
+	out, err := run(t, args...)
+	if err != nil {
+		t.Fatalf("spotifycli %s: %v\n%s", strings.Join(args, " "), err, out)
+	}
+	return out
+}
+
+// runFailing runs spotifycli with args, failing the test unless the command
+// fails with the given exit code
+func runFailing(t *testing.T, code int, args ...string) (string, error) {
+	t.Helper()
+
+	out, err := run(t, args...)
+	if err == nil {
+		t.Fatalf("spotifycli %s succeeded, want exit code %d\n%s", strings.Join(args, " "), code, out)
+	}
+	if got := exitCode(err); got != code {
+		t.Errorf("spotifycli %s: exit code %d, want %d (%v)", strings.Join(args, " "), got, code, err)
+	}
+	return out, err
+}
+
+// resetCommands puts every flag of cmd and its subcommands back to its
+// default and drops the context of the last run, such as one limited by
+// --timeout, as the commands are run many times in one process
+func resetCommands(cmd *cobra.Command) {
+	reset := func(f *pflag.Flag) {
+		if slice, ok := f.Value.(pflag.SliceValue); ok {
+			slice.Replace(nil)
+		} else {
+			f.Value.Set(f.DefValue)
+		}
+		f.Changed = false
+	}
+	cmd.Flags().VisitAll(reset)
+	cmd.PersistentFlags().VisitAll(reset)
+	cmd.SetContext(nil)
+
+	for _, sub := range cmd.Commands() {
+		resetCommands(sub)
+	}
+}
+
+// freePort returns a port on 127.0.0.1 that nothing is listening on
+func freePort(t *testing.T) string {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to find a free port: %v", err)
+	}
+	defer l.Close()
+
+	return strconv.Itoa(l.Addr().(*net.TCPAddr).Port)
+}
+
+// assertCalls fails the test unless the server answered exactly these
+// requests, given as "STATUS METHOD PATH", in order
+func assertCalls(t *testing.T, srv *spotifytest.Server, want ...string) {
+	t.Helper()
+
+	var got []string
+	for _, call := range srv.Calls() {
+		got = append(got, strconv.Itoa(call.Status)+" "+call.Method+" "+call.Path)
+	}
+
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("calls:\n  %s\nwant:\n  %s", strings.Join(got, "\n  "), strings.Join(want, "\n  "))
+	}
+}
+
+// assertOutput fails the test unless out contains each of want
+func assertOutput(t *testing.T, out string, want ...string) {
+	t.Helper()
+
+	for _, s := range want {
+		if !strings.Contains(out, s) {
+			t.Errorf("output does not contain %q:\n%s", s, out)
+		}
+	}
+}
+
+// assertErrorIs fails the test unless err wraps target
+func assertErrorIs(t *testing.T, err, target error) {
+	t.Helper()
+
+	if !errors.Is(err, target) {
+		t.Errorf("error %v, want %v", err, target)
+	}
+}
@@ -0,0 +1,443 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/AustinMusiku/spotifycli/internal/config"
+	"github.com/AustinMusiku/spotifycli/internal/ui"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+// configCmd represents the config commands group
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Manage configuration",
+	Long: `Manage spotifycli configuration and token encryption.
+
+Settings are read and written for the selected profile. Secret settings such as
+client_secret are masked unless --show-secrets is given.`,
+}
+
+var configGetCmd = &cobra.Command{
+	Use:   "get <key>",
+	Short: "Print the value of a setting",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runConfigGet(args[0])
+	},
+}
+
+var configSetCmd = &cobra.Command{
+	Use:   "set <key> <value>",
+	Short: "Change a setting",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runConfigSet(args[0], args[1])
+	},
+}
+
+var configUnsetCmd = &cobra.Command{
+	Use:   "unset <key>",
+	Short: "Remove a setting so its default applies",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runConfigUnset(args[0])
+	},
+}
+
+var configListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List all settings and where their values come from",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runConfigList()
+	},
+}
+
+var configEditCmd = &cobra.Command{
+	Use:   "edit",
+	Short: "Edit the profile's settings in $EDITOR",
+	Long: `Edit the profile's settings in $VISUAL or $EDITOR. Only the settings are shown,
+not the stored tokens; changes are validated before they are saved. Secret settings
+are left out unless --show-secrets is given.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runConfigEdit()
+	},
+}
+
+var configPathCmd = &cobra.Command{
+	Use:   "path",
+	Short: "Print the location of the config file",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path, err := config.Path()
+		if err != nil {
+			return err
+		}
+		fmt.Println(path)
+		return nil
+	},
+}
+
+var showSecrets bool
+
+var configRotateKeyCmd = &cobra.Command{
+	Use:   "rotate-key",
+	Short: "Re-encrypt stored tokens with a new key",
+	Long: `Re-encrypt stored tokens with a new key, optionally switching key source.
+
+Key sources:
+  file        a random key kept in a 0600 file next to the config (default)
+  passphrase  a key derived from a passphrase, read from SPOTIFYCLI_PASSPHRASE or prompted for
+  env         the key in SPOTIFYCLI_KEY
+
+When rotating to a passphrase the new one is read from SPOTIFYCLI_NEW_PASSPHRASE or
+prompted for; when rotating to env the new key is read from SPOTIFYCLI_NEW_KEY.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		source, _ := cmd.Flags().GetString("source")
+		return runConfigRotateKey(source)
+	},
+}
+
+var configTokenStoreCmd = &cobra.Command{
+	Use:   "token-store [file|secret-service]",
+	Short: "Show or change where tokens are stored",
+	Long: `Show or change where tokens are stored, moving existing tokens to the new store.
+
+Token stores:
+  file            encrypted in the config file (default)
+  secret-service  the freedesktop Secret Service keyring (GNOME Keyring, KWallet)`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) == 0 {
+			return runConfigTokenStore("")
+		}
+		return runConfigTokenStore(args[0])
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+	configCmd.AddCommand(configGetCmd)
+	configCmd.AddCommand(configSetCmd)
+	configCmd.AddCommand(configUnsetCmd)
+	configCmd.AddCommand(configListCmd)
+	configCmd.AddCommand(configEditCmd)
+	configCmd.AddCommand(configPathCmd)
+	configCmd.AddCommand(configRotateKeyCmd)
+	configCmd.AddCommand(configTokenStoreCmd)
+
+	configCmd.PersistentFlags().BoolVar(&showSecrets, "show-secrets", false, "Show secret settings instead of masking them")
+
+	// Add aliases
+	configListCmd.Aliases = []string{"ls"}
+
+	configRotateKeyCmd.Flags().String("source", "", "Key source to switch to (file, passphrase, env); defaults to the current one")
+
+	config.PromptPassphrase = promptPassphrase
+	config.EnvTokenRotated = warnEnvTokenRotated
+}
+
+// displayValue masks secret values unless --show-secrets was given
+func displayValue(setting config.Setting, value string) string {
+	if setting.Secret && value != "" && !showSecrets {
+		return "********"
+	}
+	return value
+}
+
+func runConfigGet(key string) error {
+	setting, err := config.LookupSetting(key)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	value, _, err := cfg.Get(key)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(displayValue(setting, value))
+	return nil
+}
+
+func runConfigSet(key, value string) error {
+	setting, err := config.LookupSetting(key)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	if err := cfg.Set(key, value); err != nil {
+		return err
+	}
+
+	if err := cfg.Save(); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+
+	ui.PrintSuccess(fmt.Sprintf("Set %s to %s for profile %s", key, displayValue(setting, value), cfg.Name()))
+	warnEnvOverride(key)
+	return nil
+}
+
+func runConfigUnset(key string) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	if err := cfg.Unset(key); err != nil {
+		return err
+	}
+
+	if err := cfg.Save(); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+
+	ui.PrintSuccess(fmt.Sprintf("Unset %s for profile %s", key, cfg.Name()))
+	warnEnvOverride(key)
+	return nil
+}
+
+// warnEnvOverride points out an environment variable that hides the saved value
+func warnEnvOverride(key string) {
+	if env, ok := config.EnvOverride(key); ok {
+		ui.PrintWarning(fmt.Sprintf("%s is set and overrides this setting", env))
+	}
+}
+
+func runConfigList() error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("⚙️  Settings for profile %s:\n", ui.BoldColor.Sprint(cfg.Name()))
+	for _, setting := range config.Settings() {
+		value, source, err := cfg.Get(setting.Key)
+		if err != nil {
+			return err
+		}
+
+		if value == "" {
+			fmt.Printf("  %-15s %s\n", setting.Key, "(unset)")
+			continue
+		}
+		fmt.Printf("  %-15s %s (%s)\n", setting.Key, displayValue(setting, value), source)
+	}
+
+	return nil
+}
+
+func runConfigEdit() error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	// Only what the file holds, so values from the environment or flags aren't saved
+	current := make(map[string]string)
+	for _, setting := range config.Settings() {
+		if setting.Secret && !showSecrets {
+			continue
+		}
+
+		value, err := cfg.FileValue(setting.Key)
+		if err != nil {
+			return err
+		}
+		current[setting.Key] = value
+	}
+
+	data, err := json.MarshalIndent(current, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp("", "spotifycli-*.json")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write temporary file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write temporary file: %w", err)
+	}
+
+	if err := openEditor(tmp.Name()); err != nil {
+		return err
+	}
+
+	data, err = os.ReadFile(tmp.Name())
+	if err != nil {
+		return fmt.Errorf("failed to read edited settings: %w", err)
+	}
+
+	var edited map[string]string
+	if err := json.Unmarshal(data, &edited); err != nil {
+		return fmt.Errorf("invalid settings, nothing was saved: %w", err)
+	}
+
+	for key := range edited {
+		if _, err := config.LookupSetting(key); err != nil {
+			return fmt.Errorf("%w, nothing was saved", err)
+		}
+	}
+
+	changed := 0
+	for _, setting := range config.Settings() {
+		value, ok := edited[setting.Key]
+		if !ok || value == current[setting.Key] {
+			continue
+		}
+
+		if value == "" {
+			err = cfg.Unset(setting.Key)
+		} else {
+			err = cfg.Set(setting.Key, value)
+		}
+		if err != nil {
+			return fmt.Errorf("%w, nothing was saved", err)
+		}
+		changed++
+	}
+
+	if changed == 0 {
+		ui.PrintInfo("No changes")
+		return nil
+	}
+
+	if err := cfg.Save(); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+
+	ui.PrintSuccess(fmt.Sprintf("Updated %d setting(s) for profile %s", changed, cfg.Name()))
+	return nil
+}
+
+// openEditor opens path in $VISUAL or $EDITOR, falling back to vi
+func openEditor(path string) error {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+
+	// The editor may carry arguments, e.g. "code --wait"
+	args := strings.Fields(editor)
+	cmd := exec.Command(args[0], append(args[1:], path)...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("editor failed: %w", err)
+	}
+	return nil
+}
+
+func runConfigRotateKey(sourceName string) error {
+	file, err := config.LoadFile()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	if sourceName == "" {
+		sourceName = file.KeySourceName()
+	}
+
+	source, err := config.KeySourceByName(sourceName)
+	if err != nil {
+		return err
+	}
+
+	if err := file.RotateKey(source); err != nil {
+		return fmt.Errorf("failed to rotate key: %w", err)
+	}
+
+	ui.PrintSuccess(fmt.Sprintf("Tokens re-encrypted with a new %s key", source.Name()))
+	if source.Name() == config.KeySourceEnv {
+		ui.PrintInfo("Use the value of SPOTIFYCLI_NEW_KEY as SPOTIFYCLI_KEY from now on")
+	}
+
+	return nil
+}
+
+func runConfigTokenStore(name string) error {
+	file, err := config.LoadFile()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	if name == "" {
+		fmt.Println(file.TokenStoreName())
+		return nil
+	}
+
+	if err := config.ValidateTokenStore(name); err != nil {
+		return usageError{err}
+	}
+
+	if err := file.SwitchTokenStore(name); err != nil {
+		return err
+	}
+
+	if err := file.Save(); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+
+	ui.PrintSuccess(fmt.Sprintf("Tokens are now stored in the %s store", name))
+	return nil
+}
+
+// warnEnvTokenRotated points out that SPOTIFYCLI_REFRESH_TOKEN no longer
+// works. It goes to stderr so it doesn't end up in output such as JSON.
+func warnEnvTokenRotated(profile string) {
+	fmt.Fprintln(os.Stderr, ui.WarningColor.Sprintf("⚠ Spotify replaced the refresh token from SPOTIFYCLI_REFRESH_TOKEN. The new one is saved in profile %s and used from now on; update the variable where this config isn't kept between runs.", profile))
+}
+
+// promptPassphrase reads a passphrase from the terminal without echoing it.
+// Ctrl-C gives up on it, turning echo back on.
+func promptPassphrase(prompt string) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", fmt.Errorf("stdin is not a terminal")
+	}
+
+	state, err := term.GetState(fd)
+	if err != nil {
+		return "", err
+	}
+
+	fmt.Fprint(os.Stderr, prompt)
+	passphrase, err := interruptible(interruptCtx, func() (string, error) {
+		passphrase, err := term.ReadPassword(fd)
+		return string(passphrase), err
+	})
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		term.Restore(fd, state)
+		return "", err
+	}
+
+	return passphrase, nil
+}
@@ -0,0 +1,130 @@
+package cmd
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/AustinMusiku/spotifycli/internal/config"
+)
+
+func TestConfigSetGetUnset(t *testing.T) {
+	newServer(t)
+
+	// Unset settings show their default
+	assertOutput(t, mustRun(t, "config", "get", "timeout"), "30s")
+
+	assertOutput(t, mustRun(t, "config", "set", "timeout", "45s"), "Set timeout to 45s for profile default")
+	assertOutput(t, mustRun(t, "config", "get", "timeout"), "45s")
+	assertOutput(t, mustRun(t, "config", "list"), "timeout         45s (file)", "port            8080 (default)")
+
+	if data := readConfigFile(t); !strings.Contains(data, `"timeout": "45s"`) {
+		t.Errorf("config file does not hold the timeout:\n%s", data)
+	}
+
+	assertOutput(t, mustRun(t, "config", "unset", "timeout"), "Unset timeout for profile default")
+	assertOutput(t, mustRun(t, "config", "get", "timeout"), "30s")
+	if data := readConfigFile(t); strings.Contains(data, "timeout") {
+		t.Errorf("timeout still saved after unset:\n%s", data)
+	}
+}
+
+func TestConfigSecret(t *testing.T) {
+	newServer(t)
+
+	out := mustRun(t, "config", "set", "client_secret", "the-secret")
+	assertOutput(t, out, "Set client_secret to ******** for profile default")
+	if strings.Contains(out, "the-secret") {
+		t.Errorf("secret shown when set:\n%s", out)
+	}
+
+	assertOutput(t, mustRun(t, "config", "get", "client_secret"), "********")
+	assertOutput(t, mustRun(t, "config", "get", "client_secret", "--show-secrets"), "the-secret")
+}
+
+func TestConfigProfiles(t *testing.T) {
+	newServer(t)
+	mustRun(t, "profile", "add", "work")
+
+	assertOutput(t, mustRun(t, "config", "set", "default_device", "Kitchen Speaker", "--profile", "work"), "for profile work")
+
+	// Each profile keeps its own settings
+	if out := mustRun(t, "config", "get", "default_device"); strings.TrimSpace(out) != "" {
+		t.Errorf("default profile's device is %q, want it unset", out)
+	}
+	assertOutput(t, mustRun(t, "config", "get", "default_device", "--profile", "work"), "Kitchen Speaker")
+}
+
+func TestConfigEnvOverride(t *testing.T) {
+	newServer(t)
+	t.Setenv("SPOTIFYCLI_TIMEOUT", "10s")
+
+	// The setting is saved, with a warning that it has no effect for now
+	out := mustRun(t, "config", "set", "timeout", "45s")
+	assertOutput(t, out, "SPOTIFYCLI_TIMEOUT is set and overrides this setting")
+	assertOutput(t, mustRun(t, "config", "get", "timeout"), "10s")
+
+	t.Setenv("SPOTIFYCLI_TIMEOUT", "")
+	assertOutput(t, mustRun(t, "config", "get", "timeout"), "45s")
+}
+
+func TestConfigSetInvalid(t *testing.T) {
+	newServer(t)
+	before := readConfigFile(t)
+
+	tests := []struct {
+		args    []string
+		code    int
+		wantErr string
+	}{
+		{[]string{"timeout", "soon"}, exitError, "invalid value for timeout"},
+		{[]string{"timeout", "--", "-1s"}, exitError, "invalid value for timeout"},
+		{[]string{"timeout", "0s"}, exitError, "invalid value for timeout"},
+		{[]string{"max_retries", "many"}, exitError, "invalid value for max_retries"},
+		{[]string{"max_retries", "--", "-1"}, exitError, "invalid value for max_retries"},
+		{[]string{"retry_budget", "a while"}, exitError, "invalid value for retry_budget"},
+		{[]string{"cache_size", "--", "-5"}, exitError, "invalid value for cache_size"},
+		{[]string{"port", "99999"}, exitError, "invalid port: 99999"},
+		{[]string{"port", "9000-8000"}, exitError, "invalid port range"},
+		{[]string{"scopes", "user-read-private,bogus"}, exitError, "unknown scope: bogus"},
+		{[]string{"api_url", "ftp://example.com"}, exitError, "must be an http or https URL"},
+		{[]string{"redirect_path", "/"}, exitError, "invalid value for redirect_path"},
+		{[]string{"volume", "3"}, exitError, "unknown setting: volume"},
+		{[]string{"timeout"}, exitUsage, "accepts 2 arg"},
+	}
+
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.args, " "), func(t *testing.T) {
+			_, err := runFailing(t, tt.code, append([]string{"config", "set"}, tt.args...)...)
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error %v, want %q in it", err, tt.wantErr)
+			}
+		})
+	}
+
+	for _, args := range [][]string{{"get", "volume"}, {"unset", "volume"}} {
+		if _, err := runFailing(t, exitError, append([]string{"config"}, args...)...); !strings.Contains(err.Error(), "unknown setting") {
+			t.Errorf("config %s: error %v, want the setting refused", strings.Join(args, " "), err)
+		}
+	}
+
+	// Nothing invalid was saved
+	if after := readConfigFile(t); after != before {
+		t.Errorf("config file changed by invalid settings:\n%s", after)
+	}
+}
+
+// readConfigFile returns the contents of the config file
+func readConfigFile(t *testing.T) string {
+	t.Helper()
+
+	path, err := config.Path()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read config file: %v", err)
+	}
+	return string(data)
+}
@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// Commands run with a context that is cancelled on Ctrl-C or SIGTERM, or
+// once --timeout runs out. Cancelling it aborts the requests in flight and
+// any prompt waiting for input, and the command reports why it stopped.
+
+var (
+	// commandTimeout is the time limit given with --timeout
+	commandTimeout time.Duration
+	// stopTimeout releases the --timeout timer
+	stopTimeout = func() {}
+	// interruptCtx is cancelled on Ctrl-C or SIGTERM. The passphrase prompt
+	// runs while loading the config, with no context of its own, and waits on it.
+	interruptCtx = context.Background()
+)
+
+func init() {
+	rootCmd.PersistentFlags().DurationVar(&commandTimeout, "timeout", 0, "Give up on the command after this long, e.g. 30s (default no limit)")
+}
+
+// applyTimeout limits the command's context to --timeout, if given
+func applyTimeout(cmd *cobra.Command) error {
+	if commandTimeout < 0 {
+		return usageError{fmt.Errorf("timeout must not be negative")}
+	}
+	if commandTimeout == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(cmd.Context(), commandTimeout)
+	stopTimeout = cancel
+	cmd.SetContext(ctx)
+	return nil
+}
+
+// reportCancellation makes commands of cmd and its subcommands that fail
+// because their context ended say they were interrupted or timed out,
+// instead of reporting the aborted request
+func reportCancellation(cmd *cobra.Command) {
+	if run := cmd.RunE; run != nil {
+		cmd.RunE = func(cmd *cobra.Command, args []string) error {
+			err := run(cmd, args)
+			if err == nil {
+				return nil
+			}
+
+			switch cmd.Context().Err() {
+			case context.DeadlineExceeded:
+				err = fmt.Errorf("%w after %s", errTimedOut, commandTimeout)
+			case context.Canceled:
+				err = errInterrupted
+			default:
+				return err
+			}
+
+			cmd.SilenceUsage = true
+			return err
+		}
+	}
+
+	for _, sub := range cmd.Commands() {
+		reportCancellation(sub)
+	}
+}
+
+// interruptible runs a blocking read, such as a prompt, giving up when ctx is
+// done. An abandoned read is left to finish when the process exits.
+func interruptible(ctx context.Context, read func() (string, error)) (string, error) {
+	type result struct {
+		value string
+		err   error
+	}
+
+	done := make(chan result, 1)
+	go func() {
+		value, err := read()
+		done <- result{value, err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.value, r.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/AustinMusiku/spotifycli/spotifytest"
+)
+
+// rateLimitDevices makes listing devices wait for a long Retry-After
+func rateLimitDevices(srv *spotifytest.Server) {
+	srv.Fail(spotifytest.Failure{Method: "GET", Path: "/v1/me/player/devices", Status: http.StatusTooManyRequests, RetryAfter: 30 * time.Second, Times: -1})
+}
+
+// waitForCall waits until the server has received a request
+func waitForCall(t *testing.T, srv *spotifytest.Server, method, path string) {
+	t.Helper()
+
+	deadline := time.Now().Add(10 * time.Second)
+	for len(srv.CallsTo(method, path)) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("no %s %s received", method, path)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestTimeoutWhileRetrying(t *testing.T) {
+	srv := newLoggedInServer(t)
+	rateLimitDevices(srv)
+
+	began := time.Now()
+	_, err := runFailing(t, exitTimedOut, "devices", "--timeout", "300ms", "--set", "retry_budget=1m")
+	assertErrorIs(t, err, errTimedOut)
+	if elapsed := time.Since(began); elapsed > 5*time.Second {
+		t.Errorf("timed out after %s, want the wait for Retry-After cut short", elapsed)
+	}
+}
+
+func TestTimeoutAtPrompt(t *testing.T) {
+	newServer(t)
+	useStdin(t)
+
+	// Nothing is ever pasted
+	_, err := runFailing(t, exitTimedOut, "login", "--no-browser", "--timeout", "300ms")
+	assertErrorIs(t, err, errTimedOut)
+}
+
+func TestInterruptWhileRetrying(t *testing.T) {
+	srv := newLoggedInServer(t)
+	rateLimitDevices(srv)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	devices := startContext(t, ctx, "devices", "--set", "retry_budget=1m")
+
+	waitForCall(t, srv, "GET", "/v1/me/player/devices")
+	cancel()
+
+	out, err := devices.wait(t)
+	if code := exitCode(err); code != exitInterrupted {
+		t.Errorf("exit code %d, want %d (%v)\n%s", code, exitInterrupted, err, out)
+	}
+	assertErrorIs(t, err, errInterrupted)
+}
+
+func TestInterruptAtPrompt(t *testing.T) {
+	newServer(t)
+	useStdin(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	login := startContext(t, ctx, "login", "--no-browser")
+
+	waitForLine(t, login, "Paste the full URL")
+	cancel()
+
+	out, err := login.wait(t)
+	if code := exitCode(err); code != exitInterrupted {
+		t.Errorf("exit code %d, want %d (%v)\n%s", code, exitInterrupted, err, out)
+	}
+	assertErrorIs(t, err, errInterrupted)
+}
+
+func TestNegativeTimeout(t *testing.T) {
+	newLoggedInServer(t)
+
+	runFailing(t, exitUsage, "devices", "--timeout=-1s")
+}
@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+)
+
+var (
+	debug       bool
+	debugFile   string
+	debugBodies bool
+
+	// debugOut is opened on first use
+	debugOut io.Writer
+)
+
+func init() {
+	rootCmd.PersistentFlags().BoolVar(&debug, "debug", false, "Trace HTTP requests to stderr, with tokens redacted (or set SPOTIFYCLI_DEBUG)")
+	rootCmd.PersistentFlags().StringVar(&debugFile, "debug-file", "", "Write the debug trace to a file instead of stderr (or set SPOTIFYCLI_DEBUG_FILE)")
+	rootCmd.PersistentFlags().BoolVar(&debugBodies, "debug-bodies", false, "Include headers and bodies in the debug trace (or set SPOTIFYCLI_DEBUG=bodies)")
+}
+
+// debugOutput returns where the debug trace goes, nil when debugging is off.
+// Any of the debug flags, or SPOTIFYCLI_DEBUG, turns it on.
+func debugOutput() (io.Writer, error) {
+	if debugOut != nil {
+		return debugOut, nil
+	}
+
+	file := debugFile
+	if file == "" {
+		file = os.Getenv("SPOTIFYCLI_DEBUG_FILE")
+	}
+
+	envDebug, _, err := debugEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	if !debug && !debugBodies && file == "" && !envDebug {
+		return nil, nil
+	}
+
+	if file == "" {
+		debugOut = os.Stderr
+		return debugOut, nil
+	}
+
+	f, err := os.OpenFile(file, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open debug file: %w", err)
+	}
+
+	debugOut = f
+	return debugOut, nil
+}
+
+// traceBodies reports whether the debug trace includes headers and bodies
+func traceBodies() bool {
+	_, envBodies, _ := debugEnv()
+	return debugBodies || envBodies
+}
+
+// debugEnv reads SPOTIFYCLI_DEBUG: a boolean such as 1 or false, or "bodies"
+// to trace headers and bodies too
+func debugEnv() (on, bodies bool, err error) {
+	value := os.Getenv("SPOTIFYCLI_DEBUG")
+	switch value {
+	case "":
+		return false, false, nil
+	case "bodies":
+		return true, true, nil
+	}
+
+	on, err = strconv.ParseBool(value)
+	if err != nil {
+		return false, false, fmt.Errorf("invalid SPOTIFYCLI_DEBUG %q, use 1, 0 or bodies", value)
+	}
+	return on, false, nil
+}
@@ -17,18 +17,18 @@ var devicesCmd = &cobra.Command{
 	Short: "List available devices",
 	Long:  `List all available Spotify devices.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		return runDevices()
+		return runDevices(cmd.Context())
 	},
 }
 
 // deviceCmd represents the device command
 var deviceCmd = &cobra.Command{
-	Use:   "device <name>",
+	Use:   "device <name|id>",
 	Short: "Switch active device",
-	Long:  `Switch playback to a specific device by name.`,
+	Long:  `Switch playback to a specific device by name or ID.`,
 	Args:  cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		return runDevice(args[0])
+		return runDevice(cmd.Context(), args[0])
 	},
 }
 
@@ -37,14 +37,13 @@ func init() {
 	rootCmd.AddCommand(deviceCmd)
 }
 
-func runDevices() error {
-	_, client, err := getAuthenticatedClient()
+func runDevices(ctx context.Context) error {
+	_, client, err := getAuthenticatedClient(ctx)
 	if err != nil {
 		return err
 	}
 
 	deviceService := api.NewDeviceService(client)
-	ctx := context.Background()
 
 	devices, err := deviceService.GetDevices(ctx)
 	if err != nil {
@@ -64,34 +63,22 @@ func runDevices() error {
 	return nil
 }
 
-func runDevice(deviceName string) error {
-	_, client, err := getAuthenticatedClient()
+func runDevice(ctx context.Context, deviceName string) error {
+	_, client, err := getAuthenticatedClient(ctx)
 	if err != nil {
 		return err
 	}
 
 	deviceService := api.NewDeviceService(client)
-	ctx := context.Background()
 
 	devices, err := deviceService.GetDevices(ctx)
 	if err != nil {
 		return err
 	}
 
-	// Find device by name (case-insensitive)
-	var targetDevice spotify.PlayerDevice
-	found := false
-
-	for _, device := range devices {
-		if strings.EqualFold(device.Name, deviceName) {
-			targetDevice = device
-			found = true
-			break
-		}
-	}
-
+	targetDevice, found := findDevice(devices, deviceName)
 	if !found {
-		return fmt.Errorf("device not found: %s", deviceName)
+		return fmt.Errorf("%w: no device named %s", api.ErrNotFound, deviceName)
 	}
 
 	// Transfer playback to the device
@@ -103,3 +90,13 @@ func runDevice(deviceName string) error {
 	ui.PrintSuccess(fmt.Sprintf("Switched to device: %s", targetDevice.Name))
 	return nil
 }
+
+// findDevice finds a device by ID or by name, ignoring case
+func findDevice(devices []spotify.PlayerDevice, nameOrID string) (spotify.PlayerDevice, bool) {
+	for _, device := range devices {
+		if device.ID.String() == nameOrID || strings.EqualFold(device.Name, nameOrID) {
+			return device, true
+		}
+	}
+	return spotify.PlayerDevice{}, false
+}
@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"errors"
+
+	"github.com/AustinMusiku/spotifycli/internal/api"
+	"github.com/AustinMusiku/spotifycli/internal/config"
+	"github.com/spf13/cobra"
+)
+
+// Exit codes, documented in the README for scripts to branch on
+const (
+	exitError       = 1
+	exitUsage       = 2
+	exitAuth        = 3
+	exitPermission  = 4
+	exitPremium     = 5
+	exitNoDevice    = 6
+	exitNotFound    = 7
+	exitRateLimited = 8
+	exitUnavailable = 9
+	exitTimedOut    = 124
+	exitInterrupted = 130
+)
+
+var (
+	// errTimedOut reports a command that ran past --timeout
+	errTimedOut = errors.New("timed out")
+	// errInterrupted reports a command stopped with Ctrl-C or SIGTERM
+	errInterrupted = errors.New("interrupted")
+)
+
+// usageError marks an error in the command line rather than the command
+type usageError struct {
+	err error
+}
+
+func (e usageError) Error() string {
+	return e.err.Error()
+}
+
+func (e usageError) Unwrap() error {
+	return e.err
+}
+
+// exitCode returns the process exit code for an error
+func exitCode(err error) int {
+	var usage usageError
+
+	switch {
+	case errors.As(err, &usage):
+		return exitUsage
+	case errors.Is(err, errInterrupted):
+		return exitInterrupted
+	case errors.Is(err, errTimedOut):
+		return exitTimedOut
+	case errors.Is(err, api.ErrNotAuthenticated),
+		errors.Is(err, api.ErrTokenExpired),
+		errors.Is(err, config.ErrTokenDecrypt):
+		return exitAuth
+	case errors.Is(err, api.ErrMissingScope):
+		return exitPermission
+	case errors.Is(err, api.ErrPremiumRequired):
+		return exitPremium
+	case errors.Is(err, api.ErrNoActiveDevice):
+		return exitNoDevice
+	case errors.Is(err, api.ErrNotFound):
+		return exitNotFound
+	case errors.Is(err, api.ErrRateLimited):
+		return exitRateLimited
+	case errors.Is(err, api.ErrUnavailable):
+		return exitUnavailable
+	}
+
+	return exitError
+}
+
+// markUsageErrors makes argument errors of cmd and its subcommands usage errors
+func markUsageErrors(cmd *cobra.Command) {
+	if validate := cmd.Args; validate != nil {
+		cmd.Args = func(cmd *cobra.Command, args []string) error {
+			if err := validate(cmd, args); err != nil {
+				return usageError{err}
+			}
+			return nil
+		}
+	}
+
+	for _, sub := range cmd.Commands() {
+		markUsageErrors(sub)
+	}
+}
@@ -0,0 +1,179 @@
+package cmd
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/AustinMusiku/spotifycli/internal/api"
+	"github.com/AustinMusiku/spotifycli/internal/auth"
+	"github.com/AustinMusiku/spotifycli/spotifytest"
+)
+
+func TestUnauthorizedRefreshesAndRetries(t *testing.T) {
+	srv := newLoggedInServer(t)
+	srv.Fail(spotifytest.Failure{Method: "GET", Path: "/v1/me/player/devices", Status: http.StatusUnauthorized})
+
+	out := mustRun(t, "devices")
+	assertOutput(t, out, "Laptop", "Kitchen Speaker")
+
+	assertCalls(t, srv,
+		"200 GET /v1/me",
+		"401 GET /v1/me/player/devices",
+		"200 POST /api/token",
+		"200 GET /v1/me/player/devices",
+	)
+}
+
+func TestUnauthorizedAfterRefresh(t *testing.T) {
+	srv := newLoggedInServer(t)
+	srv.Fail(spotifytest.Failure{Method: "GET", Path: "/v1/me/player/devices", Status: http.StatusUnauthorized, Times: -1})
+
+	_, err := runFailing(t, exitAuth, "devices")
+	assertErrorIs(t, err, api.ErrNotAuthenticated)
+
+	// The token is refreshed once, not for every rejection
+	srv.AssertCalled(t, "POST", "/api/token", 1)
+	srv.AssertCalled(t, "GET", "/v1/me/player/devices", 2)
+}
+
+func TestForbiddenMissingScope(t *testing.T) {
+	srv := newServer(t)
+
+	// Logged in without permission to control playback
+	profile := loadProfile(t)
+	token := srv.IssueToken("user-read-private", "user-read-playback-state")
+	profile.SetTokens(token.AccessToken, token.RefreshToken, token.TokenType, token.ExpiresIn)
+	profile.Scope = token.Scope
+	if err := profile.Save(); err != nil {
+		t.Fatalf("failed to save tokens: %v", err)
+	}
+
+	_, err := runFailing(t, exitPermission, "pause")
+	var scopeErr *api.MissingScopeError
+	if !errors.As(err, &scopeErr) || scopeErr.Scope != auth.ScopeModifyPlaybackState {
+		t.Fatalf("error %v, want %s named as missing", err, auth.ScopeModifyPlaybackState)
+	}
+
+	// A 403 is final
+	srv.AssertCalled(t, "PUT", "/v1/me/player/pause", 1)
+	srv.AssertNotCalled(t, "POST", "/api/token")
+}
+
+func TestForbiddenScopeNotGranted(t *testing.T) {
+	srv := newServer(t)
+
+	// Log in without the library scopes; the fake then refuses library calls
+	profile := loadProfile(t)
+	token := srv.IssueToken("user-read-private", "user-read-playback-state")
+	profile.SetTokens(token.AccessToken, token.RefreshToken, token.TokenType, token.ExpiresIn)
+	profile.Scope = token.Scope
+	if err := profile.Save(); err != nil {
+		t.Fatalf("failed to save tokens: %v", err)
+	}
+
+	_, err := runFailing(t, exitPermission, "library", "tracks")
+	var scopeErr *api.MissingScopeError
+	if !errors.As(err, &scopeErr) || scopeErr.Scope != auth.ScopeLibraryRead {
+		t.Fatalf("error %v, want %s named as missing", err, auth.ScopeLibraryRead)
+	}
+}
+
+func TestForbiddenScopesGranted(t *testing.T) {
+	srv := newLoggedInServer(t)
+	srv.Fail(spotifytest.Failure{Method: "PUT", Path: "/v1/me/player/pause", Status: http.StatusForbidden, Message: "Insufficient client scope"})
+
+	// Every scope pause needs was granted, so none is blamed
+	_, err := runFailing(t, exitError, "pause")
+	if errors.Is(err, api.ErrMissingScope) {
+		t.Fatalf("error %v, want a plain 403", err)
+	}
+	var apiErr *api.Error
+	if !errors.As(err, &apiErr) || apiErr.Status != http.StatusForbidden {
+		t.Errorf("error %v, want Spotify's 403", err)
+	}
+}
+
+func TestRateLimitedRetriesAfter(t *testing.T) {
+	srv := newLoggedInServer(t)
+	srv.Fail(spotifytest.Failure{Method: "GET", Path: "/v1/me/player/devices", Status: http.StatusTooManyRequests, RetryAfter: time.Second})
+
+	began := time.Now()
+	mustRun(t, "devices")
+	if elapsed := time.Since(began); elapsed < time.Second {
+		t.Errorf("retried after %s, want Retry-After's 1s", elapsed)
+	}
+
+	assertCalls(t, srv,
+		"200 GET /v1/me",
+		"429 GET /v1/me/player/devices",
+		"200 GET /v1/me/player/devices",
+	)
+}
+
+func TestRateLimitedOverBudget(t *testing.T) {
+	srv := newLoggedInServer(t)
+	srv.Fail(spotifytest.Failure{Method: "GET", Path: "/v1/me/player/devices", Status: http.StatusTooManyRequests, RetryAfter: time.Minute, Times: -1})
+
+	// Waiting longer than the retry budget is not worth it, so it fails at once
+	began := time.Now()
+	_, err := runFailing(t, exitRateLimited, "devices", "--set", "retry_budget=2s")
+	assertErrorIs(t, err, api.ErrRateLimited)
+	if elapsed := time.Since(began); elapsed > 10*time.Second {
+		t.Errorf("gave up after %s, want no waiting", elapsed)
+	}
+
+	srv.AssertCalled(t, "GET", "/v1/me/player/devices", 1)
+}
+
+func TestRateLimitedPost(t *testing.T) {
+	srv := newLoggedInServer(t)
+	srv.Fail(spotifytest.Failure{Method: "POST", Path: "/v1/me/player/next", Status: http.StatusTooManyRequests, RetryAfter: time.Second})
+
+	// A rate limited request was not processed, so even a skip is retried
+	mustRun(t, "next")
+
+	calls := srv.AssertCalled(t, "POST", "/v1/me/player/next", 2)
+	if len(calls) == 2 && (calls[0].Status != http.StatusTooManyRequests || calls[1].Status != http.StatusNoContent) {
+		t.Errorf("skip answered %d then %d, want 429 then 204", calls[0].Status, calls[1].Status)
+	}
+	if player := playerState(srv); player.Item != "spotify:track:digitallove" {
+		t.Errorf("player on %s, want one skip to Digital Love", player.Item)
+	}
+}
+
+func TestServerErrorRetried(t *testing.T) {
+	srv := newLoggedInServer(t)
+	srv.Fail(spotifytest.Failure{Method: "GET", Path: "/v1/me/player/devices", Status: http.StatusServiceUnavailable, Times: 2})
+
+	mustRun(t, "devices")
+
+	assertCalls(t, srv,
+		"200 GET /v1/me",
+		"503 GET /v1/me/player/devices",
+		"503 GET /v1/me/player/devices",
+		"200 GET /v1/me/player/devices",
+	)
+}
+
+func TestServerErrorGivesUp(t *testing.T) {
+	srv := newLoggedInServer(t)
+	srv.Fail(spotifytest.Failure{Method: "GET", Path: "/v1/me/player/devices", Status: http.StatusInternalServerError, Times: -1})
+
+	_, err := runFailing(t, exitUnavailable, "devices", "--set", "max_retries=1")
+	assertErrorIs(t, err, api.ErrUnavailable)
+
+	srv.AssertCalled(t, "GET", "/v1/me/player/devices", 2)
+}
+
+func TestServerErrorPostNotRetried(t *testing.T) {
+	srv := newLoggedInServer(t)
+	srv.Fail(spotifytest.Failure{Method: "POST", Path: "/v1/me/player/next", Status: http.StatusBadGateway})
+
+	// The skip may have happened, so it isn't sent twice
+	_, err := runFailing(t, exitUnavailable, "next")
+	assertErrorIs(t, err, api.ErrUnavailable)
+
+	srv.AssertCalled(t, "POST", "/v1/me/player/next", 1)
+}
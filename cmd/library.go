@@ -3,11 +3,13 @@ package cmd
 import (
 	"context"
 	"fmt"
+	"iter"
 	"strings"
 
 	"github.com/AustinMusiku/spotifycli/internal/api"
 	"github.com/AustinMusiku/spotifycli/internal/ui"
 	"github.com/spf13/cobra"
+	"github.com/zmb3/spotify/v2"
 )
 
 // libraryCmd represents the library commands group
@@ -22,8 +24,11 @@ var libraryPlaylistsCmd = &cobra.Command{
 	Short: "List your playlists",
 	Long:  `List all your saved playlists.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		limit, _ := cmd.Flags().GetInt("limit")
-		return runLibraryPlaylists(limit)
+		opts, err := pageOptions(cmd)
+		if err != nil {
+			return err
+		}
+		return runLibraryPlaylists(cmd.Context(), opts)
 	},
 }
 
@@ -32,8 +37,11 @@ var libraryAlbumsCmd = &cobra.Command{
 	Short: "List your saved albums",
 	Long:  `List all your saved albums.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		limit, _ := cmd.Flags().GetInt("limit")
-		return runLibraryAlbums(limit)
+		opts, err := pageOptions(cmd)
+		if err != nil {
+			return err
+		}
+		return runLibraryAlbums(cmd.Context(), opts)
 	},
 }
 
@@ -42,8 +50,11 @@ var libraryTracksCmd = &cobra.Command{
 	Short: "List your saved tracks",
 	Long:  `List all your saved tracks.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		limit, _ := cmd.Flags().GetInt("limit")
-		return runLibraryTracks(limit)
+		opts, err := pageOptions(cmd)
+		if err != nil {
+			return err
+		}
+		return runLibraryTracks(cmd.Context(), opts)
 	},
 }
 
@@ -52,8 +63,11 @@ var libraryShowsCmd = &cobra.Command{
 	Short: "List your saved shows",
 	Long:  `List all your saved shows (podcasts).`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		limit, _ := cmd.Flags().GetInt("limit")
-		return runLibraryShows(limit)
+		opts, err := pageOptions(cmd)
+		if err != nil {
+			return err
+		}
+		return runLibraryShows(cmd.Context(), opts)
 	},
 }
 
@@ -63,7 +77,7 @@ var librarySaveCmd = &cobra.Command{
 	Long:  `Save a track, album, or show to your library.`,
 	Args:  cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		return runLibrarySave(args[0])
+		return runLibrarySave(cmd.Context(), args[0])
 	},
 }
 
@@ -75,129 +89,139 @@ func init() {
 	libraryCmd.AddCommand(libraryShowsCmd)
 	libraryCmd.AddCommand(librarySaveCmd)
 
-	// Add limit flags
-	libraryPlaylistsCmd.Flags().IntP("limit", "l", 50, "Number of results to return")
-	libraryAlbumsCmd.Flags().IntP("limit", "l", 50, "Number of results to return")
-	libraryTracksCmd.Flags().IntP("limit", "l", 50, "Number of results to return")
-	libraryShowsCmd.Flags().IntP("limit", "l", 50, "Number of results to return")
-}
-
-func runLibraryPlaylists(limit int) error {
-	_, client, err := getAuthenticatedClient()
-	if err != nil {
-		return err
+	// Add paging flags
+	for _, cmd := range []*cobra.Command{libraryPlaylistsCmd, libraryAlbumsCmd, libraryTracksCmd, libraryShowsCmd} {
+		cmd.Flags().IntP("limit", "l", 50, "Number of results to return")
+		cmd.Flags().Int("offset", 0, "Index of the first result to return")
+		cmd.Flags().Bool("all", false, "Return every result from the offset on, ignoring --limit")
 	}
+}
 
-	libraryService := api.NewLibraryService(client)
-	ctx := context.Background()
-
-	playlists, err := libraryService.GetUserPlaylists(ctx, limit)
-	if err != nil {
-		return err
-	}
+// pageOptions reads the paging flags of a library listing
+func pageOptions(cmd *cobra.Command) (api.PageOptions, error) {
+	limit, _ := cmd.Flags().GetInt("limit")
+	offset, _ := cmd.Flags().GetInt("offset")
+	all, _ := cmd.Flags().GetBool("all")
 
-	if len(playlists.Playlists) == 0 {
-		ui.PrintInfo("No playlists found")
-		return nil
+	if offset < 0 {
+		return api.PageOptions{}, usageError{fmt.Errorf("offset must not be negative")}
 	}
 
-	fmt.Println("📋 Your Playlists:")
-	for i, playlist := range playlists.Playlists {
-		fmt.Printf("  %d. %s\n", i+1, ui.FormatPlaylist(playlist))
+	if all {
+		limit = 0
+	} else if limit < 1 {
+		return api.PageOptions{}, usageError{fmt.Errorf("limit must be at least 1, use --all for every result")}
 	}
 
-	return nil
+	return api.PageOptions{Offset: offset, Limit: limit}, nil
 }
 
-func runLibraryAlbums(limit int) error {
-	_, client, err := getAuthenticatedClient()
-	if err != nil {
-		return err
+// withProgress shows progress on stderr while a listing of more than a page
+// is fetched. The returned function clears it.
+func withProgress(opts api.PageOptions, label string) (api.PageOptions, func()) {
+	if opts.Limit > 0 && opts.Limit <= 50 {
+		return opts, func() {}
 	}
 
-	libraryService := api.NewLibraryService(client)
-	ctx := context.Background()
+	progress := ui.NewProgress(label)
+	opts.Progress = progress.Update
+	return opts, progress.Done
+}
 
-	albums, err := libraryService.GetSavedAlbums(ctx, limit)
-	if err != nil {
-		return err
-	}
+// printListing prints a numbered list as the items stream in. clearProgress
+// is called before each item, as the terminal may show both stdout and the
+// progress line on stderr.
+func printListing[T any](items iter.Seq2[T, error], offset int, clearProgress func(), label, title string, format func(T) string) error {
+	count := 0
+	for item, err := range items {
+		if err != nil {
+			return err
+		}
 
-	if len(albums.Albums) == 0 {
-		ui.PrintInfo("No saved albums found")
-		return nil
+		clearProgress()
+		if count == 0 {
+			fmt.Println(title)
+		}
+		count++
+		fmt.Printf("  %d. %s\n", offset+count, format(item))
 	}
 
-	fmt.Println("💿 Your Saved Albums:")
-	for i, album := range albums.Albums {
-		fmt.Printf("  %d. %s\n", i+1, ui.FormatAlbum(album.SimpleAlbum))
+	if count == 0 {
+		ui.PrintInfo(fmt.Sprintf("No %s found", label))
 	}
 
 	return nil
 }
 
-func runLibraryTracks(limit int) error {
-	_, client, err := getAuthenticatedClient()
+func runLibraryPlaylists(ctx context.Context, opts api.PageOptions) error {
+	_, client, err := getAuthenticatedClient(ctx)
 	if err != nil {
 		return err
 	}
 
 	libraryService := api.NewLibraryService(client)
-	ctx := context.Background()
 
-	tracks, err := libraryService.GetSavedTracks(ctx, limit)
+	opts, done := withProgress(opts, "playlists")
+	defer done()
+
+	return printListing(libraryService.Playlists(ctx, opts), opts.Offset, done, "playlists", "📋 Your Playlists:", ui.FormatPlaylist)
+}
+
+func runLibraryAlbums(ctx context.Context, opts api.PageOptions) error {
+	_, client, err := getAuthenticatedClient(ctx)
 	if err != nil {
 		return err
 	}
 
-	if len(tracks.Tracks) == 0 {
-		ui.PrintInfo("No saved tracks found")
-		return nil
-	}
+	libraryService := api.NewLibraryService(client)
 
-	fmt.Println("🎵 Your Saved Tracks:")
-	for i, track := range tracks.Tracks {
-		fmt.Printf("  %d. %s\n", i+1, ui.FormatTrack(track.FullTrack))
-	}
+	opts, done := withProgress(opts, "saved albums")
+	defer done()
 
-	return nil
+	return printListing(libraryService.SavedAlbums(ctx, opts), opts.Offset, done, "saved albums", "💿 Your Saved Albums:", func(album spotify.SavedAlbum) string {
+		return ui.FormatAlbum(album.SimpleAlbum)
+	})
 }
 
-func runLibraryShows(limit int) error {
-	_, client, err := getAuthenticatedClient()
+func runLibraryTracks(ctx context.Context, opts api.PageOptions) error {
+	_, client, err := getAuthenticatedClient(ctx)
 	if err != nil {
 		return err
 	}
 
 	libraryService := api.NewLibraryService(client)
-	ctx := context.Background()
 
-	shows, err := libraryService.GetSavedShows(ctx, limit)
+	opts, done := withProgress(opts, "saved tracks")
+	defer done()
+
+	return printListing(libraryService.SavedTracks(ctx, opts), opts.Offset, done, "saved tracks", "🎵 Your Saved Tracks:", func(track spotify.SavedTrack) string {
+		return ui.FormatTrack(track.FullTrack)
+	})
+}
+
+func runLibraryShows(ctx context.Context, opts api.PageOptions) error {
+	_, client, err := getAuthenticatedClient(ctx)
 	if err != nil {
 		return err
 	}
 
-	if len(shows.Shows) == 0 {
-		ui.PrintInfo("No saved shows found")
-		return nil
-	}
+	libraryService := api.NewLibraryService(client)
 
-	fmt.Println("🎙️ Your Saved Shows:")
-	for i, show := range shows.Shows {
-		fmt.Printf("  %d. %s\n", i+1, ui.FormatShow(show.SimpleShow))
-	}
+	opts, done := withProgress(opts, "saved shows")
+	defer done()
 
-	return nil
+	return printListing(libraryService.SavedShows(ctx, opts), opts.Offset, done, "saved shows", "🎙️ Your Saved Shows:", func(show spotify.SavedShow) string {
+		return ui.FormatShow(show.SimpleShow)
+	})
 }
 
-func runLibrarySave(uri string) error {
-	_, client, err := getAuthenticatedClient()
+func runLibrarySave(ctx context.Context, uri string) error {
+	_, client, err := getAuthenticatedClient(ctx)
 	if err != nil {
 		return err
 	}
 
 	libraryService := api.NewLibraryService(client)
-	ctx := context.Background()
 
 	// Parse URI
 	id, _, err := api.ParseURI(uri)
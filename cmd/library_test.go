@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AustinMusiku/spotifycli/spotifytest"
+	"github.com/zmb3/spotify/v2"
+)
+
+// saveTracks replaces the saved tracks with n new ones, Track 1 to Track n
+func saveTracks(srv *spotifytest.Server, n int) {
+	srv.Update(func(state *spotifytest.State) {
+		album := spotifytest.NewAlbum("bigalbum", "Big Album", "2020-01-01", spotifytest.NewArtist("prolific", "Prolific Artist"))
+
+		state.Library.Tracks = nil
+		for i := 1; i <= n; i++ {
+			track := spotifytest.NewTrack(fmt.Sprintf("track%03d", i), fmt.Sprintf("Track %d", i), album, 180000)
+			state.Catalog.Tracks = append(state.Catalog.Tracks, track)
+			state.Library.Tracks = append(state.Library.Tracks, spotifytest.Saved{ID: track.ID, AddedAt: time.Now()})
+		}
+	})
+}
+
+// pages returns the offset and limit of each request for a library listing
+func pages(srv *spotifytest.Server, path string) []string {
+	var pages []string
+	for _, call := range srv.CallsTo("GET", path) {
+		pages = append(pages, call.Query.Get("offset")+"+"+call.Query.Get("limit"))
+	}
+	return pages
+}
+
+func TestLibraryTracksPage(t *testing.T) {
+	srv := newLoggedInServer(t)
+	saveTracks(srv, 120)
+
+	out := mustRun(t, "library", "tracks", "--limit", "5", "--offset", "10")
+	assertOutput(t, out, "11. Prolific Artist - Track 11 (", "15. Prolific Artist - Track 15 (")
+	if strings.Contains(out, "Track 16") {
+		t.Errorf("listing went past --limit:\n%s", out)
+	}
+
+	if got := pages(srv, "/v1/me/tracks"); strings.Join(got, " ") != "10+5" {
+		t.Errorf("requested pages %v, want 10+5", got)
+	}
+}
+
+func TestLibraryTracksLimitOverPageSize(t *testing.T) {
+	srv := newLoggedInServer(t)
+	saveTracks(srv, 120)
+
+	out := mustRun(t, "library", "tracks", "--limit", "60", "--offset", "30")
+	assertOutput(t, out, "31. Prolific Artist - Track 31 (", "90. Prolific Artist - Track 90 (")
+	if strings.Contains(out, "Track 91") {
+		t.Errorf("listing went past --limit:\n%s", out)
+	}
+
+	// Spotify gives at most 50 items a page
+	if got := pages(srv, "/v1/me/tracks"); strings.Join(got, " ") != "30+50 80+10" {
+		t.Errorf("requested pages %v, want 30+50 80+10", got)
+	}
+}
+
+func TestLibraryTracksAll(t *testing.T) {
+	srv := newLoggedInServer(t)
+	saveTracks(srv, 120)
+
+	out := mustRun(t, "library", "tracks", "--all", "--limit", "1")
+	assertOutput(t, out, "1. Prolific Artist - Track 1 (", "120. Prolific Artist - Track 120 (")
+
+	if got := pages(srv, "/v1/me/tracks"); strings.Join(got, " ") != "0+50 50+50 100+50" {
+		t.Errorf("requested pages %v, want 0+50 50+50 100+50", got)
+	}
+}
+
+func TestLibraryEmpty(t *testing.T) {
+	srv := newLoggedInServer(t)
+	saveTracks(srv, 0)
+
+	out := mustRun(t, "library", "tracks")
+	assertOutput(t, out, "No saved tracks found")
+}
+
+func TestLibraryOtherListings(t *testing.T) {
+	newLoggedInServer(t)
+
+	assertOutput(t, mustRun(t, "library", "playlists"), "1. Road Trip")
+	assertOutput(t, mustRun(t, "library", "albums"), "1. Daft Punk - Discovery")
+	assertOutput(t, mustRun(t, "library", "shows"), "1. The Daily Fake")
+}
+
+func TestLibraryPagingFlags(t *testing.T) {
+	srv := newLoggedInServer(t)
+
+	runFailing(t, exitUsage, "library", "tracks", "--limit", "0")
+	runFailing(t, exitUsage, "library", "tracks", "--offset", "-1")
+
+	assertCalls(t, srv)
+}
+
+func TestLibrarySave(t *testing.T) {
+	srv := newLoggedInServer(t)
+
+	out := mustRun(t, "library", "save", "spotify:track:thechain")
+	assertOutput(t, out, "Track saved to library")
+
+	call := srv.AssertCalled(t, "PUT", "/v1/me/tracks", 1)[0]
+	if got := call.Query.Get("ids"); got != "thechain" {
+		t.Errorf("saved %q, want thechain", got)
+	}
+
+	var saved []spotify.ID
+	srv.Update(func(state *spotifytest.State) {
+		for _, s := range state.Library.Tracks {
+			saved = append(saved, s.ID)
+		}
+	})
+	if len(saved) == 0 || saved[0] != "thechain" {
+		t.Errorf("library tracks are %v, want thechain first", saved)
+	}
+}
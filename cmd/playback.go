@@ -1,13 +1,18 @@
 package cmd
 
 import (
+	"cmp"
 	"context"
 	"fmt"
+	"net/http"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/AustinMusiku/spotifycli/internal/api"
 	"github.com/AustinMusiku/spotifycli/internal/config"
+	"github.com/AustinMusiku/spotifycli/internal/httpclient"
 	"github.com/AustinMusiku/spotifycli/internal/ui"
 	"github.com/spf13/cobra"
 	"github.com/zmb3/spotify/v2"
@@ -15,11 +20,25 @@ import (
 
 // playCmd represents the play command
 var playCmd = &cobra.Command{
-	Use:   "play [track/album/playlist URI or search query]",
+	Use:   "play [URIs or search query]",
 	Short: "Start or resume playback",
-	Long:  `Start or resume playback. If no argument is provided, resumes current playback. If a URI or search query is provided, plays that content.`,
+	Long: `Start or resume playback. If no argument is provided, resumes current playback.
+
+An album, playlist, artist or show URI plays it from the start, or from the
+track given with --offset. One or more track or episode URIs play in order.
+Anything else is searched for, a track unless --type says otherwise, and the
+result best matching the query is played. --explain shows how the results
+were ranked.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		return runPlay(args)
+		offset, _ := cmd.Flags().GetString("offset")
+		position, _ := cmd.Flags().GetString("position")
+		shuffle, _ := cmd.Flags().GetBool("shuffle")
+		contentType, _ := cmd.Flags().GetString("type")
+		explain, _ := cmd.Flags().GetBool("explain")
+		if !cmd.Flags().Changed("type") {
+			contentType = ""
+		}
+		return runPlay(cmd.Context(), args, playFlags{offset, position, contentType, shuffle, explain})
 	},
 }
 
@@ -29,7 +48,7 @@ var pauseCmd = &cobra.Command{
 	Short: "Pause playback",
 	Long:  `Pause the current playback.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		return runPause()
+		return runPause(cmd.Context())
 	},
 }
 
@@ -39,7 +58,7 @@ var nextCmd = &cobra.Command{
 	Short: "Skip to next track",
 	Long:  `Skip to the next track in the current queue.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		return runNext()
+		return runNext(cmd.Context())
 	},
 }
 
@@ -49,7 +68,7 @@ var previousCmd = &cobra.Command{
 	Short: "Go to previous track",
 	Long:  `Go to the previous track in the current queue.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		return runPrevious()
+		return runPrevious(cmd.Context())
 	},
 }
 
@@ -57,10 +76,10 @@ var previousCmd = &cobra.Command{
 var volumeCmd = &cobra.Command{
 	Use:   "volume <0-100>",
 	Short: "Set volume",
-	Long:  `Set the volume for the active device (0-100).`,
+	Long:  `Set the volume for the active device, or the one given with --device (0-100).`,
 	Args:  cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		return runVolume(args[0])
+		return runVolume(cmd.Context(), args[0])
 	},
 }
 
@@ -71,7 +90,7 @@ var shuffleCmd = &cobra.Command{
 	Long:  `Toggle shuffle mode on or off.`,
 	Args:  cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		return runShuffle(args[0])
+		return runShuffle(cmd.Context(), args[0])
 	},
 }
 
@@ -82,10 +101,13 @@ var repeatCmd = &cobra.Command{
 	Long:  `Set repeat mode: off (no repeat), track (repeat current track), or context (repeat current playlist/album).`,
 	Args:  cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		return runRepeat(args[0])
+		return runRepeat(cmd.Context(), args[0])
 	},
 }
 
+// deviceFlag is the device given with --device
+var deviceFlag string
+
 func init() {
 	rootCmd.AddCommand(playCmd)
 	rootCmd.AddCommand(pauseCmd)
@@ -100,77 +122,135 @@ func init() {
 	pauseCmd.Aliases = []string{"pa"}
 	nextCmd.Aliases = []string{"n"}
 	previousCmd.Aliases = []string{"prev", "b"}
+
+	playCmd.Flags().String("offset", "", "Track to start at: its index from 0, or its URI")
+	playCmd.Flags().String("position", "", "Where to start in the first track, e.g. 1:30 or 90s")
+	playCmd.Flags().Bool("shuffle", false, "Turn shuffle on before playing")
+	playCmd.Flags().StringP("type", "t", "track", "Content type to search for (track, album, artist, playlist, show, episode)")
+	playCmd.Flags().Bool("explain", false, "Show how search results were ranked")
+
+	for _, cmd := range []*cobra.Command{playCmd, pauseCmd, nextCmd, previousCmd, volumeCmd, shuffleCmd, repeatCmd} {
+		addDeviceFlag(cmd)
+	}
+}
+
+// addDeviceFlag lets a command target a device other than the active one
+func addDeviceFlag(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&deviceFlag, "device", "", "Device name or ID to control instead of the active device")
+}
+
+// playFlags are the flags of the play command
+type playFlags struct {
+	offset      string
+	position    string
+	contentType string
+	shuffle     bool
+	explain     bool
 }
 
-func runPlay(args []string) error {
-	_, client, err := getAuthenticatedClient()
+func runPlay(ctx context.Context, args []string, flags playFlags) error {
+	opts, err := parsePlayArgs(args, flags)
+	if err != nil {
+		return err
+	}
+
+	cfg, client, err := getAuthenticatedClient(ctx)
 	if err != nil {
 		return err
 	}
 
 	playbackService := api.NewPlaybackService(client)
-	ctx := context.Background()
 
 	// Get active device
-	deviceID, err := getActiveDevice(ctx, client)
+	deviceID, err := getActiveDevice(ctx, client, cfg.DefaultDevice)
 	if err != nil {
 		return err
 	}
 
-	if len(args) == 0 {
-		// Resume playback
-		err = playbackService.Play(ctx, deviceID, "")
+	var message string
+	switch {
+	case len(args) == 0:
+		message = "Resumed playback"
+	case opts.Context != "":
+		message = fmt.Sprintf("Playing %s", opts.Context)
+	case len(opts.Items) == 1:
+		message = fmt.Sprintf("Playing %s", opts.Items[0])
+	case len(opts.Items) > 1:
+		message = fmt.Sprintf("Playing %d items", len(opts.Items))
+	default:
+		// Search and play the best match
+		query := strings.Join(args, " ")
+		contentType := cmp.Or(flags.contentType, "track")
+		searchService := api.NewSearchService(client)
+
+		matches, err := searchService.BestMatches(ctx, query, contentType)
 		if err != nil {
 			return err
 		}
-		ui.PrintSuccess("Resumed playback")
-	} else {
-		// Play specific content
-		query := strings.Join(args, " ")
 
-		// Check if it's a URI
-		if strings.HasPrefix(query, "spotify:") {
-			// Direct URI
-			err = playbackService.Play(ctx, deviceID, spotify.URI(query))
-			if err != nil {
-				return err
-			}
-			ui.PrintSuccess(fmt.Sprintf("Playing %s", query))
+		if len(matches) == 0 {
+			return fmt.Errorf("%w: no %ss found for query: %s", api.ErrNotFound, contentType, query)
+		}
+
+		if flags.explain {
+			explainMatches(query, matches)
+		}
+
+		best := matches[0]
+		if api.IsContextType(contentType) {
+			opts.Context = best.URI
 		} else {
-			// Search and play first result
-			searchService := api.NewSearchService(client)
-			results, err := searchService.SearchTracks(ctx, query, 1)
-			if err != nil {
-				return err
-			}
+			opts.Items = []spotify.URI{best.URI}
+		}
+		message = fmt.Sprintf("Playing: %s", formatMatch(best))
+	}
 
-			if len(results.Tracks.Tracks) == 0 {
-				return fmt.Errorf("no tracks found for query: %s", query)
-			}
+	// Shuffle first so the context doesn't start from its first track, and
+	// turn it off again should playing fail
+	restoreShuffle := func() {}
+	if flags.shuffle {
+		current, err := playbackService.GetCurrentPlayback(ctx)
+		if err != nil {
+			return err
+		}
 
-			track := results.Tracks.Tracks[0]
-			err = playbackService.Play(ctx, deviceID, track.URI)
-			if err != nil {
+		// Spotify only reports the state of the active device, so whether
+		// another device had shuffle on isn't known and it's left on
+		known := current != nil && current.Device.ID == deviceID
+		if !known || !current.Shuffle {
+			if err := playbackService.SetShuffle(ctx, deviceID, true); err != nil {
 				return err
 			}
-
-			ui.PrintSuccess(fmt.Sprintf("Playing: %s", ui.FormatTrack(track)))
 		}
+		if known && !current.Shuffle {
+			restoreShuffle = func() {
+				// Also after Ctrl-C, which is a likely reason for playing to fail
+				if err := playbackService.SetShuffle(context.WithoutCancel(ctx), deviceID, false); err != nil {
+					ui.PrintWarning(fmt.Sprintf("Failed to turn shuffle off again: %v", err))
+				}
+			}
+		}
+	}
+
+	err = playbackService.Play(ctx, deviceID, opts)
+	if err != nil {
+		restoreShuffle()
+		return err
 	}
 
+	ui.PrintSuccess(message)
 	return nil
 }
 
-func runPause() error {
-	_, client, err := getAuthenticatedClient()
+func runPause(ctx context.Context) error {
+	cfg, client, err := getAuthenticatedClient(ctx)
 	if err != nil {
 		return err
 	}
 
 	playbackService := api.NewPlaybackService(client)
-	ctx := context.Background()
 
-	deviceID, err := getActiveDevice(ctx, client)
+	deviceID, err := getActiveDevice(ctx, client, cfg.DefaultDevice)
 	if err != nil {
 		return err
 	}
@@ -184,16 +264,15 @@ func runPause() error {
 	return nil
 }
 
-func runNext() error {
-	_, client, err := getAuthenticatedClient()
+func runNext(ctx context.Context) error {
+	cfg, client, err := getAuthenticatedClient(ctx)
 	if err != nil {
 		return err
 	}
 
 	playbackService := api.NewPlaybackService(client)
-	ctx := context.Background()
 
-	deviceID, err := getActiveDevice(ctx, client)
+	deviceID, err := getActiveDevice(ctx, client, cfg.DefaultDevice)
 	if err != nil {
 		return err
 	}
@@ -207,16 +286,15 @@ func runNext() error {
 	return nil
 }
 
-func runPrevious() error {
-	_, client, err := getAuthenticatedClient()
+func runPrevious(ctx context.Context) error {
+	cfg, client, err := getAuthenticatedClient(ctx)
 	if err != nil {
 		return err
 	}
 
 	playbackService := api.NewPlaybackService(client)
-	ctx := context.Background()
 
-	deviceID, err := getActiveDevice(ctx, client)
+	deviceID, err := getActiveDevice(ctx, client, cfg.DefaultDevice)
 	if err != nil {
 		return err
 	}
@@ -230,25 +308,24 @@ func runPrevious() error {
 	return nil
 }
 
-func runVolume(volumeStr string) error {
-	_, client, err := getAuthenticatedClient()
+func runVolume(ctx context.Context, volumeStr string) error {
+	cfg, client, err := getAuthenticatedClient(ctx)
 	if err != nil {
 		return err
 	}
 
 	volume, err := strconv.Atoi(volumeStr)
 	if err != nil {
-		return fmt.Errorf("invalid volume: %s (must be a number)", volumeStr)
+		return usageError{fmt.Errorf("invalid volume: %s (must be a number)", volumeStr)}
 	}
 
 	if volume < 0 || volume > 100 {
-		return fmt.Errorf("volume must be between 0 and 100")
+		return usageError{fmt.Errorf("volume must be between 0 and 100")}
 	}
 
 	playbackService := api.NewPlaybackService(client)
-	ctx := context.Background()
 
-	deviceID, err := getActiveDevice(ctx, client)
+	deviceID, err := getActiveDevice(ctx, client, cfg.DefaultDevice)
 	if err != nil {
 		return err
 	}
@@ -262,21 +339,20 @@ func runVolume(volumeStr string) error {
 	return nil
 }
 
-func runShuffle(state string) error {
-	_, client, err := getAuthenticatedClient()
+func runShuffle(ctx context.Context, state string) error {
+	cfg, client, err := getAuthenticatedClient(ctx)
 	if err != nil {
 		return err
 	}
 
 	shuffle := state == "on"
 	if state != "on" && state != "off" {
-		return fmt.Errorf("invalid shuffle state: %s (must be 'on' or 'off')", state)
+		return usageError{fmt.Errorf("invalid shuffle state: %s (must be 'on' or 'off')", state)}
 	}
 
 	playbackService := api.NewPlaybackService(client)
-	ctx := context.Background()
 
-	deviceID, err := getActiveDevice(ctx, client)
+	deviceID, err := getActiveDevice(ctx, client, cfg.DefaultDevice)
 	if err != nil {
 		return err
 	}
@@ -290,20 +366,19 @@ func runShuffle(state string) error {
 	return nil
 }
 
-func runRepeat(state string) error {
-	_, client, err := getAuthenticatedClient()
+func runRepeat(ctx context.Context, state string) error {
+	cfg, client, err := getAuthenticatedClient(ctx)
 	if err != nil {
 		return err
 	}
 
 	if state != "off" && state != "track" && state != "context" {
-		return fmt.Errorf("invalid repeat state: %s (must be 'off', 'track', or 'context')", state)
+		return usageError{fmt.Errorf("invalid repeat state: %s (must be 'off', 'track', or 'context')", state)}
 	}
 
 	playbackService := api.NewPlaybackService(client)
-	ctx := context.Background()
 
-	deviceID, err := getActiveDevice(ctx, client)
+	deviceID, err := getActiveDevice(ctx, client, cfg.DefaultDevice)
 	if err != nil {
 		return err
 	}
@@ -317,35 +392,274 @@ func runRepeat(state string) error {
 	return nil
 }
 
+// parsePlayArgs works out what play was asked for. URIs are played directly:
+// one album, playlist, artist or show as a context, or any number of tracks
+// and episodes. Anything else is left as a search query.
+func parsePlayArgs(args []string, flags playFlags) (api.PlayOptions, error) {
+	var opts api.PlayOptions
+
+	if flags.position != "" {
+		d, err := parsePosition(flags.position)
+		if err != nil {
+			return opts, usageError{err}
+		}
+		opts.Position = d
+	}
+
+	query := len(args) > 0 && !strings.HasPrefix(args[0], "spotify:")
+	if flags.contentType != "" {
+		if !query {
+			return opts, usageError{fmt.Errorf("--type only applies to a search query")}
+		}
+		if !slices.Contains(playTypes, flags.contentType) {
+			return opts, usageError{fmt.Errorf("invalid type: %s (must be one of %s)", flags.contentType, strings.Join(playTypes, ", "))}
+		}
+	}
+
+	if len(args) > 0 && !query {
+		for _, arg := range args {
+			_, uri, err := api.ParseURI(arg)
+			if err != nil {
+				return opts, usageError{err}
+			}
+
+			if api.IsContextURI(uri) {
+				if opts.Context != "" {
+					return opts, usageError{fmt.Errorf("only one album, playlist, artist or show can be played at a time")}
+				}
+				opts.Context = uri
+			} else {
+				opts.Items = append(opts.Items, uri)
+			}
+		}
+
+		if opts.Context != "" && len(opts.Items) > 0 {
+			return opts, usageError{fmt.Errorf("an album, playlist, artist or show can't be played together with tracks")}
+		}
+	}
+
+	if offset := flags.offset; offset != "" {
+		searchingContext := query && api.IsContextType(flags.contentType)
+		if opts.Context == "" && len(opts.Items) < 2 && !searchingContext {
+			return opts, usageError{fmt.Errorf("--offset needs an album, playlist, artist or show, or several track URIs")}
+		}
+
+		if strings.HasPrefix(offset, "spotify:") {
+			_, uri, err := api.ParseURI(offset)
+			if err != nil {
+				return opts, usageError{fmt.Errorf("invalid offset: %w", err)}
+			}
+			opts.OffsetURI = uri
+		} else {
+			n, err := strconv.Atoi(offset)
+			if err != nil || n < 0 {
+				return opts, usageError{fmt.Errorf("invalid offset: %s (must be an index from 0 or a track URI)", offset)}
+			}
+			opts.Offset = n
+		}
+	}
+
+	return opts, nil
+}
+
+// playTypes are the content types play can search for
+var playTypes = []string{"track", "album", "artist", "playlist", "show", "episode"}
+
+// explainMatches shows how the top search results were ranked
+func explainMatches(query string, matches []api.Match) {
+	fmt.Printf("🔎 Best matches for %q:\n", query)
+	for i, match := range matches[:min(len(matches), 5)] {
+		fmt.Printf("  %d. %s %s\n", i+1, formatMatch(match), ui.BoldColor.Sprintf("%.1f", match.Score))
+		for _, part := range match.Parts {
+			ui.DimColor.Printf("       %+6.1f  %s\n", part.Points, part.Reason)
+		}
+	}
+	fmt.Println()
+}
+
+// formatMatch formats a search result ranked by the search service
+func formatMatch(match api.Match) string {
+	switch item := match.Item.(type) {
+	case spotify.FullTrack:
+		return ui.FormatTrack(item)
+	case spotify.SimpleAlbum:
+		return ui.FormatAlbum(item)
+	case spotify.FullArtist:
+		return ui.FormatArtist(item)
+	case spotify.SimplePlaylist:
+		return ui.FormatPlaylist(item)
+	case spotify.EpisodePage:
+		return ui.FormatEpisode(item)
+	}
+
+	if match.Artist == "" {
+		return ui.BoldColor.Sprint(match.Name)
+	}
+	return fmt.Sprintf("%s - %s", ui.BoldColor.Sprint(match.Name), match.Artist)
+}
+
+// parsePosition parses a position in a track, given as a duration such as
+// 90s, as seconds, or as minutes and seconds such as 1:30 (or 1:02:30)
+func parsePosition(value string) (time.Duration, error) {
+	if d, err := time.ParseDuration(value); err == nil && d >= 0 {
+		return d, nil
+	}
+
+	parts := strings.Split(value, ":")
+	if len(parts) > 3 {
+		return 0, fmt.Errorf("invalid position: %s (use a time such as 1:30 or 90s)", value)
+	}
+
+	var position time.Duration
+	for _, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid position: %s (use a time such as 1:30 or 90s)", value)
+		}
+		position = position*60 + time.Duration(n)*time.Second
+	}
+
+	return position, nil
+}
+
 // getAuthenticatedClient returns an authenticated API client
-func getAuthenticatedClient() (*config.Config, *api.Client, error) {
-	cfg, err := config.LoadConfig()
+func getAuthenticatedClient(ctx context.Context) (*config.Config, *api.Client, error) {
+	cfg, err := loadLogin()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	client, err := newUserClient(ctx, cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return cfg, client, nil
+}
+
+// getCatalogClient returns a client for catalog calls such as search. It uses
+// the user login when there is one, and app credentials otherwise.
+func getCatalogClient(ctx context.Context) (*api.Client, error) {
+	cfg, err := loadLogin()
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.IsAuthenticated() || !cfg.HasAppCredentials() {
+		return newUserClient(ctx, cfg)
+	}
+
+	client, err := newClient(cfg)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to load config: %w", err)
+		return nil, err
 	}
 
+	clientID, clientSecret := cfg.AppCredentials()
+	if err := client.AuthenticateApp(ctx, clientID, clientSecret); err != nil {
+		return nil, err
+	}
+
+	return client, nil
+}
+
+// newUserClient creates an API client authenticated with the profile's user login
+func newUserClient(ctx context.Context, cfg *config.Config) (*api.Client, error) {
 	if !cfg.IsAuthenticated() {
-		return nil, nil, fmt.Errorf("not authenticated, please run 'spotify login'")
+		if cfg.HasAppCredentials() {
+			return nil, api.ErrUserLoginRequired
+		}
+		return nil, fmt.Errorf("%w, please run 'spotifycli login'", api.ErrNotAuthenticated)
 	}
 
-	client := api.NewClient(cfg)
-	if err := client.Authenticate(cfg.GetAccessToken()); err != nil {
-		return nil, nil, fmt.Errorf("authentication failed: %w", err)
+	client, err := newClient(cfg)
+	if err != nil {
+		return nil, err
 	}
 
-	return cfg, client, nil
+	if err := client.Authenticate(ctx, cfg.GetAccessToken()); err != nil {
+		return nil, fmt.Errorf("authentication failed: %w", err)
+	}
+
+	return client, nil
+}
+
+// newClient creates an API client using the profile's endpoints and HTTP settings
+func newClient(cfg *config.Config) (*api.Client, error) {
+	httpClient, err := newHTTPClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := []api.ClientOption{
+		api.WithHTTPClient(httpClient),
+		api.WithAPIURL(cfg.APIURL),
+		api.WithAccountsURL(cfg.AccountsURL),
+	}
+
+	if !noCache {
+		c, err := openCache(cfg)
+		if err != nil {
+			return nil, err
+		}
+		if c != nil {
+			opts = append(opts, api.WithCache(c))
+		}
+	}
+
+	return api.NewClient(cfg, opts...), nil
 }
 
-// getActiveDevice gets the active device ID
-func getActiveDevice(ctx context.Context, client *api.Client) (spotify.ID, error) {
+// newHTTPClient creates the HTTP client configured for the profile
+func newHTTPClient(cfg *config.Config) (*http.Client, error) {
+	opts := httpclient.Options{
+		Timeout:     cfg.RequestTimeout(),
+		MaxRetries:  cfg.RetryLimit(),
+		RetryBudget: cfg.RetryWait(),
+		Proxy:       cfg.Proxy,
+		CABundle:    cfg.CABundle,
+		UserAgent:   cfg.UserAgent,
+	}
+	// Zero means the default to httpclient, but no retries here
+	if opts.MaxRetries == 0 {
+		opts.MaxRetries = -1
+	}
+
+	var err error
+	opts.Debug, err = debugOutput()
+	if err != nil {
+		return nil, err
+	}
+	opts.DebugBodies = traceBodies()
+
+	httpClient, err := httpclient.New(opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure HTTP client: %w", err)
+	}
+
+	return httpClient, nil
+}
+
+// getActiveDevice gets the ID of the device to control: the one given with
+// --device, otherwise the active device, preferring the profile's default
+// device (matched by name or ID) when nothing is playing
+func getActiveDevice(ctx context.Context, client *api.Client, defaultDevice string) (spotify.ID, error) {
 	deviceService := api.NewDeviceService(client)
+
 	devices, err := deviceService.GetDevices(ctx)
 	if err != nil {
 		return "", err
 	}
 
 	if len(devices) == 0 {
-		return "", fmt.Errorf("no devices found, please start Spotify on a device")
+		return "", fmt.Errorf("%w: no devices found, please start Spotify on a device", api.ErrNoActiveDevice)
+	}
+
+	if deviceFlag != "" {
+		device, found := findDevice(devices, deviceFlag)
+		if !found {
+			return "", fmt.Errorf("%w: no device named %s", api.ErrNotFound, deviceFlag)
+		}
+		return device.ID, nil
 	}
 
 	// Find active device
@@ -355,6 +669,13 @@ func getActiveDevice(ctx context.Context, client *api.Client) (spotify.ID, error
 		}
 	}
 
-	// If no active device, use the first one
+	// If no active device, use the default one
+	if defaultDevice != "" {
+		if device, found := findDevice(devices, defaultDevice); found {
+			return device.ID, nil
+		}
+	}
+
+	// Otherwise use the first one
 	return devices[0].ID, nil
 }
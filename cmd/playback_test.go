@@ -0,0 +1,270 @@
+package cmd
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/AustinMusiku/spotifycli/internal/api"
+	"github.com/AustinMusiku/spotifycli/spotifytest"
+	"github.com/zmb3/spotify/v2"
+)
+
+func TestPlayContext(t *testing.T) {
+	srv := newLoggedInServer(t)
+
+	out := mustRun(t, "play", "spotify:album:rumours", "--offset", "1", "--position", "1:30")
+	assertOutput(t, out, "Playing spotify:album:rumours")
+
+	assertCalls(t, srv,
+		"200 GET /v1/me",
+		"200 GET /v1/me/player/devices",
+		"204 PUT /v1/me/player/play",
+	)
+
+	call := srv.CallsTo("PUT", "/v1/me/player/play")[0]
+	if got := call.Query.Get("device_id"); got != "laptop" {
+		t.Errorf("played on device %q, want the active device laptop", got)
+	}
+
+	var body struct {
+		ContextURI string `json:"context_uri"`
+		Offset     struct {
+			Position int `json:"position"`
+		} `json:"offset"`
+		PositionMs int `json:"position_ms"`
+	}
+	if err := json.Unmarshal(call.Body, &body); err != nil {
+		t.Fatalf("invalid play body %s: %v", call.Body, err)
+	}
+	if body.ContextURI != "spotify:album:rumours" || body.Offset.Position != 1 || body.PositionMs != 90000 {
+		t.Errorf("play body %s, want rumours from track 1 at 90000ms", call.Body)
+	}
+
+	player := playerState(srv)
+	if player.Item != "spotify:track:thechain" || player.ProgressMs != 90000 || !player.Playing {
+		t.Errorf("player is on %s at %dms (playing %t), want The Chain at 90000ms", player.Item, player.ProgressMs, player.Playing)
+	}
+}
+
+func TestPlayTracks(t *testing.T) {
+	srv := newLoggedInServer(t)
+
+	out := mustRun(t, "play", "spotify:track:dreams", "spotify:track:karmapolice")
+	assertOutput(t, out, "Playing 2 items")
+
+	player := playerState(srv)
+	if player.Context != "" || !slices.Equal(player.URIs, []spotify.URI{"spotify:track:dreams", "spotify:track:karmapolice"}) {
+		t.Errorf("player has context %q and URIs %v, want the two tracks", player.Context, player.URIs)
+	}
+
+	mustRun(t, "next")
+	if player := playerState(srv); player.Item != "spotify:track:karmapolice" {
+		t.Errorf("next moved to %s, want Karma Police", player.Item)
+	}
+
+	mustRun(t, "pause")
+	if player := playerState(srv); player.Playing {
+		t.Errorf("still playing after pause")
+	}
+
+	srv.AssertCalled(t, "POST", "/v1/me/player/next", 1)
+	srv.AssertCalled(t, "PUT", "/v1/me/player/pause", 1)
+}
+
+func TestPlayRejectsMixedArguments(t *testing.T) {
+	srv := newLoggedInServer(t)
+
+	runFailing(t, exitUsage, "play", "spotify:track:dreams", "--type", "album")
+
+	// Usage errors are caught before anything is sent
+	assertCalls(t, srv)
+}
+
+func TestDeviceFlag(t *testing.T) {
+	srv := newLoggedInServer(t)
+
+	out := mustRun(t, "play", "spotify:track:dreams", "--device", "kitchen speaker")
+	assertOutput(t, out, "Playing spotify:track:dreams")
+
+	call := srv.AssertCalled(t, "PUT", "/v1/me/player/play", 1)[0]
+	if got := call.Query.Get("device_id"); got != "speaker" {
+		t.Errorf("played on device %q, want speaker", got)
+	}
+
+	// The flag matches IDs too, and isn't remembered between commands
+	srv.ResetCalls()
+	mustRun(t, "volume", "30", "--device", "laptop")
+	mustRun(t, "pause")
+
+	if got := srv.CallsTo("PUT", "/v1/me/player/volume")[0].Query.Get("device_id"); got != "laptop" {
+		t.Errorf("set the volume on device %q, want laptop", got)
+	}
+	if got := srv.CallsTo("PUT", "/v1/me/player/pause")[0].Query.Get("device_id"); got != "speaker" {
+		t.Errorf("paused device %q, want the now active speaker", got)
+	}
+}
+
+func TestDeviceFlagUnknownDevice(t *testing.T) {
+	srv := newLoggedInServer(t)
+
+	_, err := runFailing(t, exitNotFound, "pause", "--device", "Bathroom Radio")
+	assertErrorIs(t, err, api.ErrNotFound)
+
+	srv.AssertNotCalled(t, "PUT", "/v1/me/player/pause")
+}
+
+func TestDefaultDevice(t *testing.T) {
+	srv := newLoggedInServer(t)
+	deactivate := func() {
+		srv.Update(func(state *spotifytest.State) {
+			for i := range state.Devices {
+				state.Devices[i].Active = false
+			}
+		})
+	}
+
+	// Without an active device the default device is played on
+	deactivate()
+	mustRun(t, "play", "--set", "default_device=Kitchen Speaker")
+
+	// and when that isn't around either, the first device
+	deactivate()
+	mustRun(t, "pause", "--set", "default_device=Bathroom Radio")
+
+	if got := srv.CallsTo("PUT", "/v1/me/player/play")[0].Query.Get("device_id"); got != "speaker" {
+		t.Errorf("played on device %q, want the default device speaker", got)
+	}
+	if got := srv.CallsTo("PUT", "/v1/me/player/pause")[0].Query.Get("device_id"); got != "laptop" {
+		t.Errorf("paused device %q, want the first device laptop", got)
+	}
+}
+
+func TestNoDevices(t *testing.T) {
+	srv := newLoggedInServer(t)
+	srv.Update(func(state *spotifytest.State) {
+		state.Devices = nil
+	})
+
+	_, err := runFailing(t, exitNoDevice, "play")
+	assertErrorIs(t, err, api.ErrNoActiveDevice)
+
+	srv.AssertNotCalled(t, "PUT", "/v1/me/player/play")
+}
+
+func TestTransferPlayback(t *testing.T) {
+	srv := newLoggedInServer(t)
+
+	out := mustRun(t, "device", "Kitchen Speaker")
+	assertOutput(t, out, "Switched to device: Kitchen Speaker")
+
+	call := srv.AssertCalled(t, "PUT", "/v1/me/player", 1)[0]
+	var body struct {
+		DeviceIDs []string `json:"device_ids"`
+	}
+	if err := json.Unmarshal(call.Body, &body); err != nil || !slices.Equal(body.DeviceIDs, []string{"speaker"}) {
+		t.Errorf("transfer body %s, want device speaker", call.Body)
+	}
+
+	var active spotify.ID
+	srv.Update(func(state *spotifytest.State) {
+		active = state.ActiveDevice().ID
+	})
+	if active != "speaker" {
+		t.Errorf("active device is %s, want speaker", active)
+	}
+}
+
+func TestPlayShuffleRestoredOnFailure(t *testing.T) {
+	srv := newLoggedInServer(t)
+	srv.Fail(spotifytest.Failure{
+		Method:  "PUT",
+		Path:    "/v1/me/player/play",
+		Status:  http.StatusForbidden,
+		Message: "Player command failed: Premium required",
+		Reason:  api.ReasonPremiumRequired,
+	})
+
+	_, err := runFailing(t, exitPremium, "play", "spotify:album:rumours", "--shuffle")
+	assertErrorIs(t, err, api.ErrPremiumRequired)
+
+	shuffles := srv.AssertCalled(t, "PUT", "/v1/me/player/shuffle", 2)
+	if len(shuffles) == 2 && (shuffles[0].Query.Get("state") != "true" || shuffles[1].Query.Get("state") != "false") {
+		t.Errorf("shuffle set to %s then %s, want it turned on then off again", shuffles[0].Query.Get("state"), shuffles[1].Query.Get("state"))
+	}
+	if playerState(srv).Shuffle {
+		t.Errorf("shuffle left on after play failed")
+	}
+}
+
+func TestPlayShuffleRestoreFails(t *testing.T) {
+	srv := newLoggedInServer(t)
+	srv.Fail(spotifytest.Failure{
+		Method:  "PUT",
+		Path:    "/v1/me/player/play",
+		Status:  http.StatusForbidden,
+		Message: "Player command failed: Premium required",
+		Reason:  api.ReasonPremiumRequired,
+	})
+	// Turning shuffle on works, turning it off again doesn't
+	srv.Fail(spotifytest.Failure{Method: "PUT", Path: "/v1/me/player/shuffle", Status: http.StatusServiceUnavailable, After: 1, Times: -1})
+
+	// The play error is the one returned, with the restore reported
+	out, err := runFailing(t, exitPremium, "play", "spotify:album:rumours", "--shuffle", "--set", "max_retries=0")
+	assertErrorIs(t, err, api.ErrPremiumRequired)
+	assertOutput(t, out, "Failed to turn shuffle off again")
+}
+
+func TestPlayShuffleOtherDevice(t *testing.T) {
+	srv := newLoggedInServer(t)
+
+	// Shuffle is on for the active laptop, which says nothing of the speaker
+	srv.Update(func(state *spotifytest.State) {
+		state.Player.Shuffle = true
+	})
+	srv.Fail(spotifytest.Failure{
+		Method:  "PUT",
+		Path:    "/v1/me/player/play",
+		Status:  http.StatusForbidden,
+		Message: "Player command failed: Premium required",
+		Reason:  api.ReasonPremiumRequired,
+	})
+
+	runFailing(t, exitPremium, "play", "spotify:album:rumours", "--shuffle", "--device", "Kitchen Speaker")
+
+	// It's turned on for the speaker and, not knowing what it was, left on
+	shuffles := srv.AssertCalled(t, "PUT", "/v1/me/player/shuffle", 1)
+	if len(shuffles) == 1 && (shuffles[0].Query.Get("device_id") != "speaker" || shuffles[0].Query.Get("state") != "true") {
+		t.Errorf("shuffle set to %s on %s, want it on for the speaker", shuffles[0].Query.Get("state"), shuffles[0].Query.Get("device_id"))
+	}
+}
+
+// playerState returns a copy of the fake's player state
+func playerState(srv *spotifytest.Server) spotifytest.Player {
+	var player spotifytest.Player
+	srv.Update(func(state *spotifytest.State) {
+		player = state.Player
+	})
+	return player
+}
+
+func TestStatusEpisode(t *testing.T) {
+	srv := newLoggedInServer(t)
+	srv.Update(func(state *spotifytest.State) {
+		state.Player.Item = "spotify:episode:episode2"
+		state.Player.Context = "spotify:show:dailyfake"
+		state.Player.ProgressMs = 60000
+		state.Player.Playing = true
+	})
+
+	// The show stands in for the artist, and the length is the episode's
+	out := mustRun(t, "status")
+	assertOutput(t, out, "The Daily Fake - Episode 2: Offline First", "1:00", "/35:00")
+
+	call := srv.CallsTo("GET", "/v1/me/player")[0]
+	if got := call.Query.Get("additional_types"); !slices.Contains(strings.Split(got, ","), "episode") {
+		t.Errorf("asked for additional types %q, want episodes included", got)
+	}
+}
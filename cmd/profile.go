@@ -0,0 +1,191 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/AustinMusiku/spotifycli/internal/config"
+	"github.com/AustinMusiku/spotifycli/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+// profileName is the value of the global --profile flag
+var profileName string
+
+// profileCmd represents the profile commands group
+var profileCmd = &cobra.Command{
+	Use:   "profile",
+	Short: "Manage account profiles",
+	Long: `Manage named profiles, each with its own Spotify account, tokens, default device and settings.
+
+The profile used by a command is chosen by the --profile flag, then the
+SPOTIFYCLI_PROFILE environment variable, then the active profile.`,
+}
+
+var profileListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List profiles",
+	Long:  `List all profiles, marking the active one.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runProfileList()
+	},
+}
+
+var profileAddCmd = &cobra.Command{
+	Use:   "add <name>",
+	Short: "Add a profile",
+	Long:  `Add a new profile. Run 'spotifycli login --profile <name>' afterwards to authenticate it.`,
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		clientID, _ := cmd.Flags().GetString("client-id")
+		device, _ := cmd.Flags().GetString("device")
+		return runProfileAdd(args[0], clientID, device)
+	},
+}
+
+var profileUseCmd = &cobra.Command{
+	Use:   "use <name>",
+	Short: "Switch the active profile",
+	Long:  `Make a profile the active one for subsequent commands.`,
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runProfileUse(args[0])
+	},
+}
+
+var profileRemoveCmd = &cobra.Command{
+	Use:   "remove <name>",
+	Short: "Remove a profile",
+	Long:  `Remove a profile and its stored credentials. The active profile cannot be removed.`,
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runProfileRemove(args[0])
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(profileCmd)
+	profileCmd.AddCommand(profileListCmd)
+	profileCmd.AddCommand(profileAddCmd)
+	profileCmd.AddCommand(profileUseCmd)
+	profileCmd.AddCommand(profileRemoveCmd)
+
+	profileAddCmd.Flags().String("client-id", "", "Spotify Client ID for the profile")
+	profileAddCmd.Flags().String("device", "", "Device name or ID to play on when no device is active")
+
+	// Add aliases
+	profileListCmd.Aliases = []string{"ls"}
+	profileRemoveCmd.Aliases = []string{"rm"}
+}
+
+// loadConfig loads the profile selected by --profile, SPOTIFYCLI_PROFILE or the active profile
+func loadConfig() (*config.Config, error) {
+	cfg, err := config.LoadProfile(profileName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+	return cfg, nil
+}
+
+// loadLogin loads the selected profile along with its tokens, for commands
+// that act as the logged in user
+func loadLogin() (*config.Config, error) {
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cfg.LoadTokens(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func runProfileList() error {
+	file, err := config.LoadFile()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	fmt.Println("👤 Profiles:")
+	for _, name := range file.ProfileNames() {
+		profile, _ := file.Profile(name)
+
+		marker := " "
+		if name == file.ActiveProfile {
+			marker = "*"
+		}
+
+		loggedIn, err := profile.HasStoredLogin()
+		if err != nil {
+			return err
+		}
+
+		status := "not logged in"
+		if loggedIn {
+			status = "logged in"
+		}
+
+		fmt.Printf("  %s %s (%s)\n", marker, ui.BoldColor.Sprint(name), status)
+	}
+
+	return nil
+}
+
+func runProfileAdd(name, clientID, device string) error {
+	file, err := config.LoadFile()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	profile, err := file.AddProfile(name)
+	if err != nil {
+		return err
+	}
+
+	profile.ClientID = clientID
+	profile.DefaultDevice = device
+
+	if err := file.Save(); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+
+	ui.PrintSuccess(fmt.Sprintf("Added profile %s", name))
+	ui.PrintInfo(fmt.Sprintf("Run 'spotifycli login --profile %s' to authenticate it", name))
+	return nil
+}
+
+func runProfileUse(name string) error {
+	file, err := config.LoadFile()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	if err := file.UseProfile(name); err != nil {
+		return err
+	}
+
+	if err := file.Save(); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+
+	ui.PrintSuccess(fmt.Sprintf("Switched to profile %s", name))
+	return nil
+}
+
+func runProfileRemove(name string) error {
+	file, err := config.LoadFile()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	if err := file.RemoveProfile(name); err != nil {
+		return err
+	}
+
+	if err := file.Save(); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+
+	ui.PrintSuccess(fmt.Sprintf("Removed profile %s", name))
+	return nil
+}
@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/AustinMusiku/spotifycli/internal/config"
+)
+
+func TestProfiles(t *testing.T) {
+	newLoggedInServer(t)
+
+	out := mustRun(t, "profile", "add", "work", "--client-id", "work-app", "--device", "Kitchen Speaker")
+	assertOutput(t, out, "Added profile work", "spotifycli login --profile work")
+
+	out = mustRun(t, "profile", "list")
+	assertOutput(t, out, "* default (logged in)", "  work (not logged in)")
+
+	file, err := config.LoadFile()
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	work, err := file.Profile("work")
+	if err != nil {
+		t.Fatalf("work profile not saved: %v", err)
+	}
+	if work.ClientID != "work-app" || work.DefaultDevice != "Kitchen Speaker" {
+		t.Errorf("work profile has client %q and device %q, want those given", work.ClientID, work.DefaultDevice)
+	}
+
+	// Switching makes the work profile the one commands use
+	assertOutput(t, mustRun(t, "profile", "use", "work"), "Switched to profile work")
+	assertOutput(t, mustRun(t, "profile", "ls"), "  default (logged in)", "* work (not logged in)")
+	runFailing(t, exitAuth, "whoami")
+
+	// --profile and SPOTIFYCLI_PROFILE pick another for a single command
+	assertOutput(t, mustRun(t, "whoami", "--profile", "default"), "Test User")
+	t.Setenv("SPOTIFYCLI_PROFILE", "default")
+	assertOutput(t, mustRun(t, "whoami"), "Test User")
+	t.Setenv("SPOTIFYCLI_PROFILE", "")
+
+	// The active profile can't be removed
+	if _, err := runFailing(t, exitError, "profile", "remove", "work"); !strings.Contains(err.Error(), "cannot remove the active profile") {
+		t.Errorf("error %v, want the active profile kept", err)
+	}
+
+	mustRun(t, "profile", "use", "default")
+	assertOutput(t, mustRun(t, "profile", "rm", "work"), "Removed profile work")
+
+	out = mustRun(t, "profile", "list")
+	if strings.Contains(out, "work") {
+		t.Errorf("work profile still listed:\n%s", out)
+	}
+	file, err = config.LoadFile()
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	if names := file.ProfileNames(); len(names) != 1 {
+		t.Errorf("config file holds profiles %v, want only the default", names)
+	}
+
+	// The default profile kept its login throughout
+	assertOutput(t, mustRun(t, "whoami"), "Test User")
+}
+
+func TestProfileErrors(t *testing.T) {
+	newServer(t)
+	mustRun(t, "profile", "add", "work")
+
+	tests := []struct {
+		args    []string
+		code    int
+		wantErr string
+	}{
+		{[]string{"profile", "add", "work"}, exitError, `profile "work" already exists`},
+		{[]string{"profile", "use", "home"}, exitError, "home"},
+		{[]string{"profile", "remove", "home"}, exitError, "home"},
+		{[]string{"profile", "add"}, exitUsage, "accepts 1 arg"},
+		{[]string{"profile", "use", "work", "home"}, exitUsage, "accepts 1 arg"},
+		{[]string{"whoami", "--profile", "home"}, exitError, "home"},
+	}
+
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.args, " "), func(t *testing.T) {
+			_, err := runFailing(t, tt.code, tt.args...)
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error %v, want %q in it", err, tt.wantErr)
+			}
+		})
+	}
+
+	// Nothing was changed by the failed commands
+	assertOutput(t, mustRun(t, "profile", "list"), "* default (not logged in)", "  work (not logged in)")
+}
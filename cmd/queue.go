@@ -17,7 +17,7 @@ var queueCmd = &cobra.Command{
 	Short: "Show current queue",
 	Long:  `Show the current playback queue.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		return runQueue()
+		return runQueue(cmd.Context())
 	},
 }
 
@@ -28,26 +28,26 @@ var queueAddCmd = &cobra.Command{
 	Long:  `Add a track to the current queue. Can be a Spotify URI or a search query.`,
 	Args:  cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		return runQueueAdd(args[0])
+		return runQueueAdd(cmd.Context(), args[0])
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(queueCmd)
 	queueCmd.AddCommand(queueAddCmd)
+	addDeviceFlag(queueAddCmd)
 
 	// Add aliases
 	queueCmd.Aliases = []string{"q"}
 }
 
-func runQueue() error {
-	_, client, err := getAuthenticatedClient()
+func runQueue(ctx context.Context) error {
+	_, client, err := getAuthenticatedClient(ctx)
 	if err != nil {
 		return err
 	}
 
 	playbackService := api.NewPlaybackService(client)
-	ctx := context.Background()
 
 	queue, err := playbackService.GetQueue(ctx)
 	if err != nil {
@@ -78,17 +78,16 @@ func runQueue() error {
 	return nil
 }
 
-func runQueueAdd(query string) error {
-	_, client, err := getAuthenticatedClient()
+func runQueueAdd(ctx context.Context, query string) error {
+	cfg, client, err := getAuthenticatedClient(ctx)
 	if err != nil {
 		return err
 	}
 
 	playbackService := api.NewPlaybackService(client)
-	ctx := context.Background()
 
 	// Get active device
-	deviceID, err := getActiveDevice(ctx, client)
+	deviceID, err := getActiveDevice(ctx, client, cfg.DefaultDevice)
 	if err != nil {
 		return err
 	}
@@ -101,13 +100,14 @@ func runQueueAdd(query string) error {
 	} else {
 		// Search for track
 		searchService := api.NewSearchService(client)
+
 		results, err := searchService.SearchTracks(ctx, query, 1)
 		if err != nil {
 			return err
 		}
 
 		if len(results.Tracks.Tracks) == 0 {
-			return fmt.Errorf("no tracks found for query: %s", query)
+			return fmt.Errorf("%w: no tracks found for query: %s", api.ErrNotFound, query)
 		}
 
 		uri = results.Tracks.Tracks[0].URI
@@ -1,8 +1,12 @@
 package cmd
 
 import (
+	"context"
 	"os"
+	"os/signal"
+	"syscall"
 
+	"github.com/AustinMusiku/spotifycli/internal/config"
 	"github.com/spf13/cobra"
 )
 
@@ -19,18 +23,55 @@ A comprehensive command-line interface for Spotify that supports:
 - Device management (list and switch between devices)
 - Queue management (view and add to queue)
 - Modern OAuth2 PKCE authentication
+- Multiple account profiles
 
 Get started by running 'spotifycli login' to authenticate with Spotify.`,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := applyTimeout(cmd); err != nil {
+			return err
+		}
+
+		config.SetPath(configPath)
+		return config.SetFlagValues(settingValues)
+	},
 }
 
+var (
+	configPath    string
+	settingValues []string
+)
+
 // Adds all child commands to the root command and sets flags appropriately.
+// The exit code tells scripts what kind of error occurred, see exitCode.
 func Execute() {
-	err := rootCmd.Execute()
+	markUsageErrors(rootCmd)
+	reportCancellation(rootCmd)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	interruptCtx = ctx
+
+	// Once interrupted, a second Ctrl-C kills a command that is slow to stop
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	err := rootCmd.ExecuteContext(ctx)
+	stopTimeout()
+	stop()
 	if err != nil {
-		os.Exit(1)
+		os.Exit(exitCode(err))
 	}
 }
 
 func init() {
 	rootCmd.Version = "1.0.0"
+
+	rootCmd.SetFlagErrorFunc(func(cmd *cobra.Command, err error) error {
+		return usageError{err}
+	})
+
+	rootCmd.PersistentFlags().StringVar(&profileName, "profile", "", "Profile to use (overrides SPOTIFYCLI_PROFILE)")
+	rootCmd.PersistentFlags().StringVar(&configPath, "config", "", "Config file to use (overrides SPOTIFYCLI_CONFIG)")
+	rootCmd.PersistentFlags().StringArrayVar(&settingValues, "set", nil, "Override a setting for this run, as key=value (repeatable)")
 }
@@ -19,7 +19,7 @@ var searchCmd = &cobra.Command{
 	RunE: func(cmd *cobra.Command, args []string) error {
 		limit, _ := cmd.Flags().GetInt("limit")
 		contentType, _ := cmd.Flags().GetString("type")
-		return runSearch(strings.Join(args, " "), limit, contentType)
+		return runSearch(cmd.Context(), strings.Join(args, " "), limit, contentType)
 	},
 }
 
@@ -30,14 +30,13 @@ func init() {
 	searchCmd.Flags().StringP("type", "t", "all", "Content type to search (track, album, artist, playlist, show, episode, all)")
 }
 
-func runSearch(query string, limit int, contentType string) error {
-	_, client, err := getAuthenticatedClient()
+func runSearch(ctx context.Context, query string, limit int, contentType string) error {
+	client, err := getCatalogClient(ctx)
 	if err != nil {
 		return err
 	}
 
 	searchService := api.NewSearchService(client)
-	ctx := context.Background()
 
 	if contentType == "all" {
 		// Search all content types
@@ -93,7 +92,7 @@ func runSearch(query string, limit int, contentType string) error {
 			displayEpisodes(results.Episodes.Episodes)
 
 		default:
-			return fmt.Errorf("invalid content type: %s", contentType)
+			return usageError{fmt.Errorf("invalid content type: %s", contentType)}
 		}
 	}
 
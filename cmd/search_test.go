@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/AustinMusiku/spotifycli/internal/api"
+	"github.com/AustinMusiku/spotifycli/spotifytest"
+	"github.com/zmb3/spotify/v2"
+)
+
+func TestSearch(t *testing.T) {
+	srv := newLoggedInServer(t)
+
+	out := mustRun(t, "search", "radiohead", "--type", "track", "--limit", "5")
+	assertOutput(t, out, "Paranoid Android", "Karma Police")
+	if strings.Contains(out, "Dreams") {
+		t.Errorf("search listed a track not matching the query:\n%s", out)
+	}
+
+	call := srv.AssertCalled(t, "GET", "/v1/search", 1)[0]
+	if q := call.Query; q.Get("q") != "radiohead" || q.Get("type") != "track" || q.Get("limit") != "5" {
+		t.Errorf("searched with %v, want q=radiohead type=track limit=5", q)
+	}
+}
+
+func TestSearchAllTypes(t *testing.T) {
+	srv := newLoggedInServer(t)
+
+	out := mustRun(t, "search", "daily")
+	assertOutput(t, out, "🎙️ Shows:", "The Daily Fake", "🎧 Episodes:", "Episode 1: Hello World")
+
+	call := srv.AssertCalled(t, "GET", "/v1/search", 1)[0]
+	types := strings.Split(call.Query.Get("type"), ",")
+	slices.Sort(types)
+	if want := []string{"album", "artist", "episode", "playlist", "show", "track"}; !slices.Equal(types, want) {
+		t.Errorf("searched types %v, want %v", types, want)
+	}
+}
+
+func TestSearchWithAppCredentials(t *testing.T) {
+	srv := newServer(t, spotifytest.WithClient("spotifytest", "secret"))
+
+	out := mustRun(t, "search", "rumours", "--type", "album", "--set", "client_secret=secret")
+	assertOutput(t, out, "Rumours")
+
+	// Without a login the client credentials grant is used instead
+	assertCalls(t, srv,
+		"200 POST /api/token",
+		"200 GET /v1/search",
+	)
+	if body := string(srv.CallsTo("POST", "/api/token")[0].Body); !strings.Contains(body, "grant_type=client_credentials") {
+		t.Errorf("token request %s, want the client credentials grant", body)
+	}
+
+	// Playback needs a user
+	_, err := runFailing(t, exitAuth, "pause", "--set", "client_secret=secret")
+	assertErrorIs(t, err, api.ErrUserLoginRequired)
+}
+
+func TestPlaySearchRanking(t *testing.T) {
+	srv := newLoggedInServer(t)
+
+	// Spotify's first result is a karaoke version, the track asked for is second
+	srv.Update(func(state *spotifytest.State) {
+		album := spotifytest.NewAlbum("karaokehits", "Karaoke Hits", "2015-06-01", spotifytest.NewArtist("singalong", "Sing Along Stars"))
+		karaoke := spotifytest.NewTrack("dreamskaraoke", "Dreams (Karaoke Version)", album, 250000)
+		state.Catalog.Tracks = append([]spotify.FullTrack{karaoke}, state.Catalog.Tracks...)
+	})
+
+	out := mustRun(t, "play", "dreams", "--explain")
+	assertOutput(t, out,
+		`Best matches for "dreams"`,
+		"1. Fleetwood Mac - Dreams (Rumours)",
+		"exact title match",
+		"#2 in Spotify's results",
+		"2. Sing Along Stars - Dreams (Karaoke Version)",
+		"Playing: Fleetwood Mac - Dreams (Rumours)",
+	)
+
+	// The search fetches enough results to rank
+	search := srv.AssertCalled(t, "GET", "/v1/search", 1)[0]
+	if q := search.Query; q.Get("q") != "dreams" || q.Get("type") != "track" || q.Get("limit") != "20" {
+		t.Errorf("searched with %v, want q=dreams type=track limit=20", q)
+	}
+
+	var body struct {
+		URIs []string `json:"uris"`
+	}
+	call := srv.AssertCalled(t, "PUT", "/v1/me/player/play", 1)[0]
+	if err := json.Unmarshal(call.Body, &body); err != nil || !slices.Equal(body.URIs, []string{"spotify:track:dreams"}) {
+		t.Errorf("play body %s, want the original Dreams", call.Body)
+	}
+}
+
+func TestPlaySearchType(t *testing.T) {
+	srv := newLoggedInServer(t)
+
+	out := mustRun(t, "play", "ok", "computer", "--type", "album")
+	assertOutput(t, out, "Playing: Radiohead - OK Computer")
+
+	if got := srv.CallsTo("GET", "/v1/search")[0].Query.Get("type"); got != "album" {
+		t.Errorf("searched for %q, want album", got)
+	}
+
+	// An album plays as a context, from its first track
+	player := playerState(srv)
+	if player.Context != "spotify:album:okcomputer" || player.Item != "spotify:track:paranoidandroid" {
+		t.Errorf("player on %s in %s, want Paranoid Android in OK Computer", player.Item, player.Context)
+	}
+}
+
+func TestPlaySearchAlbumOverTrack(t *testing.T) {
+	srv := newLoggedInServer(t)
+
+	// A track shares the album's name
+	srv.Update(func(state *spotifytest.State) {
+		tribute := spotifytest.NewAlbum("tributes", "Tributes", "2010-01-01", spotifytest.NewArtist("tributeband", "Tribute Band"))
+		state.Catalog.Tracks = append(state.Catalog.Tracks, spotifytest.NewTrack("rumourstrack", "Rumours", tribute, 200000))
+	})
+
+	out := mustRun(t, "play", "rumours", "--type", "album")
+	assertOutput(t, out, "Playing: Fleetwood Mac - Rumours")
+
+	player := playerState(srv)
+	if player.Context != "spotify:album:rumours" || len(player.URIs) != 0 {
+		t.Errorf("player on %s in %s (URIs %v), want the album Rumours as the context", player.Item, player.Context, player.URIs)
+	}
+}
+
+func TestPlaySearchNoResults(t *testing.T) {
+	srv := newLoggedInServer(t)
+
+	_, err := runFailing(t, exitNotFound, "play", "nothing", "like", "this")
+	assertErrorIs(t, err, api.ErrNotFound)
+
+	srv.AssertCalled(t, "GET", "/v1/search", 1)
+	srv.AssertNotCalled(t, "PUT", "/v1/me/player/play")
+}
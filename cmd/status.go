@@ -15,7 +15,7 @@ var statusCmd = &cobra.Command{
 	Short: "Show current playback status",
 	Long:  `Show the current playback status including track, progress, and playback state.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		return runStatus()
+		return runStatus(cmd.Context())
 	},
 }
 
@@ -26,16 +26,15 @@ func init() {
 	statusCmd.Aliases = []string{"s", "now"}
 }
 
-func runStatus() error {
-	_, client, err := getAuthenticatedClient()
+func runStatus(ctx context.Context) error {
+	_, client, err := getAuthenticatedClient(ctx)
 	if err != nil {
 		return err
 	}
 
 	playbackService := api.NewPlaybackService(client)
-	ctx := context.Background()
 
-	playback, playerState, err := playbackService.GetCurrentPlayback(ctx)
+	playback, err := playbackService.GetCurrentPlayback(ctx)
 	if err != nil {
 		return err
 	}
@@ -45,7 +44,14 @@ func runStatus() error {
 		return nil
 	}
 
-	status := ui.FormatPlaybackState(playerState)
+	status := ui.FormatPlaybackState(&ui.PlaybackState{
+		Track:    playback.Track,
+		Episode:  playback.Episode,
+		Ad:       playback.Type == api.PlayingAd,
+		Progress: playback.Progress,
+		Shuffle:  playback.Shuffle,
+		Repeat:   playback.Repeat,
+	})
 	fmt.Println(status)
 
 	return nil
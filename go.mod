@@ -4,16 +4,18 @@ go 1.25.3
 
 require (
 	github.com/fatih/color v1.18.0
+	github.com/godbus/dbus/v5 v5.1.0
 	github.com/spf13/cobra v1.10.1
+	github.com/spf13/pflag v1.0.10
 	github.com/zmb3/spotify/v2 v2.4.3
 	golang.org/x/oauth2 v0.32.0
+	golang.org/x/sys v0.29.0
+	golang.org/x/term v0.28.0
 )
 
 require (
 	github.com/inconshreveable/mousetrap v1.1.0 // indirect
 	github.com/mattn/go-colorable v0.1.13 // indirect
 	github.com/mattn/go-isatty v0.0.20 // indirect
-	github.com/spf13/pflag v1.0.10 // indirect
 	github.com/stretchr/testify v1.11.1 // indirect
-	golang.org/x/sys v0.29.0 // indirect
 )
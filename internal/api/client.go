@@ -2,10 +2,19 @@ package api
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/AustinMusiku/spotifycli/internal/auth"
+	"github.com/AustinMusiku/spotifycli/internal/cache"
 	"github.com/zmb3/spotify/v2"
 	"golang.org/x/oauth2"
 )
@@ -15,69 +24,330 @@ type Client struct {
 	spotifyClient *spotify.Client
 	config        ConfigProvider
 	httpClient    *http.Client
+	// apiClient is the HTTP client behind spotifyClient, for the calls it
+	// can't decode
+	apiClient *http.Client
+
+	mu          sync.Mutex
+	accessToken string
+	refreshMu   sync.Mutex
+
+	user *spotify.PrivateUser
+	// appOnly is set when the client uses app credentials instead of a user login
+	appOnly bool
+
+	apiURL      string
+	accountsURL string
+
+	// cache serves catalog lookups and searches when set
+	cache *cache.Cache
+}
+
+// defaultAPIURL is the Web API used unless WithAPIURL says otherwise
+const defaultAPIURL = "https://api.spotify.com/v1/"
+
+// ClientOption configures a Client
+type ClientOption func(*Client)
+
+// WithHTTPClient sets the HTTP client used for both the Web API and token refreshes
+func WithHTTPClient(httpClient *http.Client) ClientOption {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithAPIURL points the client at an alternative Web API, such as a local mock.
+// An empty URL keeps the default.
+func WithAPIURL(url string) ClientOption {
+	return func(c *Client) {
+		if url != "" {
+			c.apiURL = strings.TrimSuffix(url, "/") + "/"
+		}
+	}
+}
+
+// WithCache serves catalog lookups and searches from the cache. Player and
+// library calls always go to Spotify.
+func WithCache(c *cache.Cache) ClientOption {
+	return func(client *Client) {
+		client.cache = c
+	}
+}
+
+// WithAccountsURL points token refreshes at an alternative accounts service.
+// An empty URL keeps the default.
+func WithAccountsURL(url string) ClientOption {
+	return func(c *Client) {
+		c.accountsURL = url
+	}
 }
 
 // ConfigProvider interface for accessing configuration
 type ConfigProvider interface {
+	GetClientID() string
 	GetAccessToken() string
 	GetRefreshToken() string
 	GetTokenType() string
 	IsTokenExpired() bool
 	SetTokens(accessToken, refreshToken, tokenType string, expiresIn int64)
 	Save() error
+	// LockTokens keeps other processes from refreshing until unlocked
+	LockTokens() (unlock func(), err error)
+	// ReloadTokens re-reads the tokens, which another process may have refreshed
+	ReloadTokens() error
+	// GrantedScopes returns the scopes the tokens were granted
+	GrantedScopes() []string
 }
 
-func NewClient(config ConfigProvider) *Client {
+func NewClient(config ConfigProvider, opts ...ClientOption) *Client {
 	httpClient := &http.Client{
 		Timeout: 30 * time.Second,
 	}
 
-	return &Client{
+	c := &Client{
 		config:     config,
 		httpClient: httpClient,
 	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
 }
 
-func (c *Client) Authenticate(accessToken string) error {
-	ctx := context.Background()
+func (c *Client) Authenticate(ctx context.Context, accessToken string) error {
+	c.setAccessToken(accessToken)
 
-	token := &oauth2.Token{
-		AccessToken: accessToken,
-		TokenType:   "Bearer",
+	var spotifyOpts []spotify.ClientOption
+	if c.apiURL != "" {
+		spotifyOpts = append(spotifyOpts, spotify.WithBaseURL(c.apiURL))
 	}
 
-	client := oauth2.NewClient(ctx, oauth2.StaticTokenSource(token))
+	refresh := &refreshTransport{
+		client: c,
+		base:   c.httpClient.Transport,
+	}
 
-	c.spotifyClient = spotify.New(client)
+	// Nothing is cached until the user is known
+	c.apiClient = &http.Client{
+		Timeout:   c.httpClient.Timeout,
+		Transport: c.transport(refresh, ""),
+	}
+	c.spotifyClient = spotify.New(c.apiClient, spotifyOpts...)
+
+	// Refresh up front rather than waiting for the first call to be rejected
+	if err := c.RefreshToken(ctx); err != nil {
+		return err
+	}
 
 	// Test authentication by getting user profile
-	_, err := c.spotifyClient.CurrentUser(ctx)
+	user, err := c.spotifyClient.CurrentUser(ctx)
 	if err != nil {
-		return fmt.Errorf("authentication failed: %w", err)
+		return HandleAPIError(err)
+	}
+
+	c.user = user
+	c.apiClient.Transport = c.transport(refresh, "user:"+user.ID)
+	return nil
+}
+
+// AuthenticateApp sets the client up for app-only access with the client
+// credentials grant. Catalog calls such as search work without a user login,
+// while user-scoped calls fail with ErrUserLoginRequired.
+func (c *Client) AuthenticateApp(ctx context.Context, clientID, clientSecret string) error {
+	endpoint := auth.Endpoint{
+		AccountsURL: c.accountsURL,
+		HTTPClient:  c.httpClient,
+	}
+	source := auth.AppTokenSource(ctx, endpoint, clientID, clientSecret)
+
+	// Fetch a token up front so bad credentials are reported straight away
+	if _, err := source.Token(); err != nil {
+		return fmt.Errorf("app authentication failed, check the client ID and secret: %w", err)
+	}
+
+	var spotifyOpts []spotify.ClientOption
+	if c.apiURL != "" {
+		spotifyOpts = append(spotifyOpts, spotify.WithBaseURL(c.apiURL))
 	}
 
+	c.apiClient = &http.Client{
+		Timeout: c.httpClient.Timeout,
+		Transport: c.transport(&oauth2.Transport{
+			Source: source,
+			Base:   c.httpClient.Transport,
+		}, "app"),
+	}
+	c.spotifyClient = spotify.New(c.apiClient, spotifyOpts...)
+	c.appOnly = true
+
 	return nil
 }
 
+// transport layers error reasons and the cache over the authorizing transport.
+// Cached responses are served without needing a valid token, so they are only
+// shared with the same owner: the logged in user, or "app" for app-only
+// access. With no owner nothing is cached.
+func (c *Client) transport(authorized http.RoundTripper, owner string) http.RoundTripper {
+	if c.cache != nil && owner != "" {
+		authorized = c.cache.Transport(authorized, owner)
+	}
+	return &reasonTransport{base: authorized}
+}
+
+// IsAppOnly reports whether the client uses app credentials rather than a user login
+func (c *Client) IsAppOnly() bool {
+	return c.appOnly
+}
+
+// CurrentUser returns the profile of the authenticated user
+func (c *Client) CurrentUser() *spotify.PrivateUser {
+	return c.user
+}
+
 // GetSpotifyClient returns the underlying Spotify client
 func (c *Client) GetSpotifyClient() *spotify.Client {
 	return c.spotifyClient
 }
 
+// getJSON fetches a Web API endpoint whose response the spotify package can't
+// decode, such as one that may describe an episode, and decodes it into v. It
+// reports false when Spotify answers with no content.
+func (c *Client) getJSON(ctx context.Context, path string, query url.Values, v any) (bool, error) {
+	endpoint := c.apiURL
+	if endpoint == "" {
+		endpoint = defaultAPIURL
+	}
+	endpoint += path
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return false, err
+	}
+
+	resp, err := c.apiClient.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNoContent {
+		return false, nil
+	}
+
+	// Errors are returned as spotify.Error, like the spotify package does
+	if resp.StatusCode >= http.StatusBadRequest {
+		var body struct {
+			Error spotify.Error `json:"error"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil || body.Error.Message == "" {
+			body.Error.Message = http.StatusText(resp.StatusCode)
+		}
+		body.Error.Status = resp.StatusCode
+		return false, body.Error
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return false, fmt.Errorf("failed to decode %s: %w", path, err)
+	}
+
+	return true, nil
+}
+
 // RefreshToken refreshes the access token if needed
 func (c *Client) RefreshToken(ctx context.Context) error {
 	if !c.config.IsTokenExpired() {
 		return nil
 	}
 
-	// TODO: For now, we'll require re-authentication but later it will use the refresh token
-	return fmt.Errorf("token expired, please run 'spotify login' to re-authenticate")
+	return c.refreshIfStale(ctx, c.currentAccessToken())
+}
+
+// refreshIfStale exchanges the stored refresh token for a new access token,
+// unless another request has already replaced the stale token in the meantime
+func (c *Client) refreshIfStale(ctx context.Context, stale string) error {
+	c.refreshMu.Lock()
+	defer c.refreshMu.Unlock()
+
+	if c.currentAccessToken() != stale {
+		return nil
+	}
+
+	// Other spotifycli processes may be refreshing too, and Spotify can rotate
+	// the refresh token, so only the first refreshes and the rest use its token
+	unlock, err := c.config.LockTokens()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := c.config.ReloadTokens(); err != nil {
+		return fmt.Errorf("failed to reload tokens: %w", err)
+	}
+
+	if access := c.config.GetAccessToken(); access != stale && !c.config.IsTokenExpired() {
+		c.setAccessToken(access)
+		return nil
+	}
+
+	refreshToken := c.config.GetRefreshToken()
+	if refreshToken == "" {
+		return fmt.Errorf("%w, %s", ErrTokenExpired, hints[ErrTokenExpired])
+	}
+
+	endpoint := auth.Endpoint{
+		AccountsURL: c.accountsURL,
+		HTTPClient:  c.httpClient,
+	}
+
+	token, err := auth.RefreshAccessToken(ctx, endpoint, c.config.GetClientID(), refreshToken)
+	if err != nil {
+		// Spotify rejects a refresh token that was revoked or has expired
+		var retrieveErr *oauth2.RetrieveError
+		if errors.As(err, &retrieveErr) && retrieveErr.ErrorCode == "invalid_grant" {
+			return fmt.Errorf("%w, %s: %v", ErrTokenExpired, hints[ErrTokenExpired], err)
+		}
+		return fmt.Errorf("token refresh failed: %w", err)
+	}
+
+	// Spotify does not always rotate the refresh token
+	if token.RefreshToken != "" {
+		refreshToken = token.RefreshToken
+	}
+
+	c.config.SetTokens(token.AccessToken, refreshToken, token.TokenType, auth.ExpiresIn(token))
+	if err := c.config.Save(); err != nil {
+		return fmt.Errorf("failed to save refreshed token: %w", err)
+	}
+
+	c.setAccessToken(token.AccessToken)
+	return nil
+}
+
+func (c *Client) currentAccessToken() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.accessToken
+}
+
+func (c *Client) setAccessToken(token string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.accessToken = token
 }
 
 // EnsureAuthenticated ensures the client is authenticated AND token is valid
 func (c *Client) EnsureAuthenticated(ctx context.Context) error {
 	if c.spotifyClient == nil {
-		return fmt.Errorf("not authenticated, please run 'spotify login'")
+		return fmt.Errorf("%w, %s", ErrNotAuthenticated, hints[ErrNotAuthenticated])
+	}
+
+	if c.appOnly {
+		return ErrUserLoginRequired
 	}
 
 	// Check if token needs refresh
@@ -88,29 +358,67 @@ func (c *Client) EnsureAuthenticated(ctx context.Context) error {
 	return nil
 }
 
-// HandleAPIError just wraps Spotify API errors and provides user-friendly messages
-func HandleAPIError(err error) error {
-	if err == nil {
+// EnsureCatalogAccess ensures the client can reach catalog endpoints, which
+// need no user, with either a user login or app credentials
+func (c *Client) EnsureCatalogAccess(ctx context.Context) error {
+	if c.appOnly && c.spotifyClient != nil {
 		return nil
 	}
 
-	// Check for specific Spotify API errors
-	if spotifyErr, ok := err.(spotify.Error); ok {
-		switch spotifyErr.Status {
-		case 401:
-			return fmt.Errorf("authentication failed, please run 'spotify login'")
-		case 403:
-			return fmt.Errorf("insufficient permissions, please check your Spotify app settings")
-		case 404:
-			return fmt.Errorf("resource not found")
-		case 429:
-			return fmt.Errorf("rate limit exceeded, please wait a moment and try again")
-		case 500, 502, 503:
-			return fmt.Errorf("spotify service is temporarily unavailable")
-		default:
-			return fmt.Errorf("spotify API error: %s", spotifyErr.Message)
+	return c.EnsureAuthenticated(ctx)
+}
+
+// apiError handles the error of a call that needs the given scopes, naming
+// the first one the login was not granted if Spotify refused it for a scope.
+// When the granted scopes aren't known every scope is taken to be missing.
+func (c *Client) apiError(err error, scopes ...string) error {
+	granted := c.config.GrantedScopes()
+	if len(granted) == 0 {
+		return HandleAPIError(err, scopes...)
+	}
+
+	var missing []string
+	for _, scope := range scopes {
+		if !slices.Contains(granted, scope) {
+			missing = append(missing, scope)
 		}
 	}
+	return HandleAPIError(err, missing...)
+}
+
+// HandleAPIError turns Spotify API errors into an *Error or MissingScopeError.
+// missing are the scopes the failed call needs that the login was not
+// granted, used to name the one at fault.
+func HandleAPIError(err error, missing ...string) error {
+	if err == nil {
+		return nil
+	}
+
+	var reasonErr *reasonError
+	if errors.As(err, &reasonErr) {
+		return newError(reasonErr.err, reasonErr.reason, missing)
+	}
+
+	var spotifyErr spotify.Error
+	if errors.As(err, &spotifyErr) {
+		return newError(spotifyErr, "", missing)
+	}
+
+	if errors.Is(err, context.Canceled) {
+		return err
+	}
+
+	// The transport's own errors, such as an expired login, come wrapped in the URL
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) && errors.Is(urlErr.Err, ErrTokenExpired) {
+		return urlErr.Err
+	}
+
+	// Retries are already used up by the time a network error gets here
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return fmt.Errorf("%w (%v), %s", ErrUnavailable, err, hints[ErrUnavailable])
+	}
 
 	return fmt.Errorf("API error: %w", err)
 }
@@ -3,6 +3,7 @@ package api
 import (
 	"context"
 
+	"github.com/AustinMusiku/spotifycli/internal/auth"
 	"github.com/zmb3/spotify/v2"
 )
 
@@ -23,7 +24,7 @@ func (d *DeviceService) GetDevices(ctx context.Context) ([]spotify.PlayerDevice,
 
 	devices, err := d.client.GetSpotifyClient().PlayerDevices(ctx)
 	if err != nil {
-		return nil, HandleAPIError(err)
+		return nil, d.client.apiError(err, auth.ScopeReadPlaybackState)
 	}
 
 	return devices, nil
@@ -37,7 +38,7 @@ func (d *DeviceService) TransferPlayback(ctx context.Context, deviceID spotify.I
 
 	err := d.client.GetSpotifyClient().TransferPlayback(ctx, deviceID, play)
 	if err != nil {
-		return HandleAPIError(err)
+		return d.client.apiError(err, auth.ScopeModifyPlaybackState)
 	}
 
 	return nil
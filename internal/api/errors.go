@@ -0,0 +1,165 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/zmb3/spotify/v2"
+)
+
+// Errors returned by the client, to be checked with errors.Is. Failed API
+// calls are returned as *Error, which wraps the one that applies.
+var (
+	// ErrNotAuthenticated means there is no usable login
+	ErrNotAuthenticated = errors.New("not authenticated")
+	// ErrTokenExpired means the login has expired and could not be refreshed
+	ErrTokenExpired = errors.New("login expired")
+	// ErrNoActiveDevice means there is no device to control
+	ErrNoActiveDevice = errors.New("no active device")
+	// ErrPremiumRequired means the call needs a Spotify Premium account
+	ErrPremiumRequired = errors.New("Spotify Premium required")
+	// ErrMissingScope means the login was not granted a scope the call needs,
+	// see MissingScopeError
+	ErrMissingScope = errors.New("missing permission")
+	// ErrNotFound means the requested item does not exist
+	ErrNotFound = errors.New("not found")
+	// ErrRateLimited means Spotify kept rate limiting the call after retrying
+	ErrRateLimited = errors.New("rate limited by Spotify")
+	// ErrUnavailable means Spotify kept failing or could not be reached
+	ErrUnavailable = errors.New("spotify service is temporarily unavailable")
+)
+
+// ErrUserLoginRequired is returned by user-scoped calls, such as playback and
+// library commands, when only app credentials are configured
+var ErrUserLoginRequired = fmt.Errorf("%w: this command needs a Spotify user login, only app credentials are configured; run 'spotifycli login'", ErrNotAuthenticated)
+
+// Reasons Spotify gives for failed player commands
+const (
+	ReasonNoActiveDevice  = "NO_ACTIVE_DEVICE"
+	ReasonPremiumRequired = "PREMIUM_REQUIRED"
+	ReasonRateLimited     = "RATE_LIMITED"
+)
+
+// hints tell the user what to do about each kind of error
+var hints = map[error]string{
+	ErrNotAuthenticated: "please run 'spotifycli login'",
+	ErrTokenExpired:     "please run 'spotifycli login' to re-authenticate",
+	ErrNoActiveDevice:   "start Spotify on a device or pick one with 'spotifycli device <name>'",
+	ErrPremiumRequired:  "playback can only be controlled on a Premium account",
+	ErrRateLimited:      "please wait a while and try again",
+	ErrUnavailable:      "please try again later",
+}
+
+// Error is a failed Spotify API call
+type Error struct {
+	// Status is the HTTP status code
+	Status int
+	// Reason is Spotify's reason code for failed player commands, such as
+	// NO_ACTIVE_DEVICE, when it gives one
+	Reason string
+	// Message is Spotify's description of the error
+	Message string
+
+	kind error
+}
+
+func (e *Error) Error() string {
+	switch e.kind {
+	case nil:
+		return "spotify API error: " + e.Message
+	case ErrNotFound:
+		return "not found: " + e.Message
+	}
+	return e.kind.Error() + ", " + hints[e.kind]
+}
+
+// Unwrap returns the sentinel error for the kind of failure, if any
+func (e *Error) Unwrap() error {
+	return e.kind
+}
+
+// MissingScopeError is returned when Spotify rejects a call because the
+// token was not granted a scope the endpoint needs
+type MissingScopeError struct {
+	Scope string
+}
+
+func (e *MissingScopeError) Error() string {
+	return fmt.Sprintf("missing permission %q, run 'spotifycli login --add-scope %s' to grant it", e.Scope, e.Scope)
+}
+
+// Is makes a MissingScopeError match ErrMissingScope
+func (e *MissingScopeError) Is(target error) bool {
+	return target == ErrMissingScope
+}
+
+// newError classifies a Spotify error and the reason given with it, if any.
+// missing are the scopes the failed call needs that the login was not
+// granted; a 403 without one is left as a plain API error.
+func newError(spotifyErr spotify.Error, reason string, missing []string) error {
+	if reason == "" {
+		reason = reasonFromMessage(spotifyErr.Message)
+	}
+
+	e := &Error{
+		Status:  spotifyErr.Status,
+		Reason:  reason,
+		Message: spotifyErr.Message,
+	}
+
+	// Player errors carry a reason whatever the status
+	switch e.Reason {
+	case ReasonNoActiveDevice:
+		e.kind = ErrNoActiveDevice
+		return e
+	case ReasonPremiumRequired:
+		e.kind = ErrPremiumRequired
+		return e
+	case ReasonRateLimited:
+		e.kind = ErrRateLimited
+		return e
+	}
+
+	switch {
+	case e.Status == 401:
+		e.kind = ErrNotAuthenticated
+	case e.Status == 403:
+		if len(missing) > 0 && strings.Contains(strings.ToLower(e.Message), "scope") {
+			return &MissingScopeError{Scope: missing[0]}
+		}
+	case e.Status == 404:
+		e.kind = ErrNotFound
+	case e.Status == 429:
+		e.kind = ErrRateLimited
+	case e.Status >= 500:
+		e.kind = ErrUnavailable
+	}
+
+	return e
+}
+
+// reasonError is a failed call for which Spotify gave a reason, such as
+// NO_ACTIVE_DEVICE. spotify.Error has no field for the reason, so the
+// transport fails the request with a reasonError instead.
+type reasonError struct {
+	err    spotify.Error
+	reason string
+}
+
+func (e *reasonError) Error() string {
+	return e.err.Error()
+}
+
+// reasonFromMessage guesses the reason for a Spotify error that came without
+// one from its message
+func reasonFromMessage(message string) string {
+	message = strings.ToLower(message)
+	switch {
+	case strings.Contains(message, "no active device"):
+		return ReasonNoActiveDevice
+	case strings.Contains(message, "premium required"):
+		return ReasonPremiumRequired
+	}
+	return ""
+}
@@ -0,0 +1,91 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/zmb3/spotify/v2"
+)
+
+// failingAPI answers every request with the given status and error body
+func failingAPI(t *testing.T, status int, body string) *spotify.Client {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		fmt.Fprint(w, body)
+	}))
+	t.Cleanup(srv.Close)
+
+	httpClient := &http.Client{Transport: &reasonTransport{base: http.DefaultTransport}}
+	return spotify.New(httpClient, spotify.WithBaseURL(srv.URL+"/"))
+}
+
+func TestHandleAPIErrorReasons(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		body       string
+		want       error
+		wantReason string
+	}{
+		{"no active device", 404, `{"error": {"status": 404, "message": "Player command failed: No active device found", "reason": "NO_ACTIVE_DEVICE"}}`, ErrNoActiveDevice, ReasonNoActiveDevice},
+		{"premium required", 403, `{"error": {"status": 403, "message": "Player command failed: Premium required", "reason": "PREMIUM_REQUIRED"}}`, ErrPremiumRequired, ReasonPremiumRequired},
+		{"rate limited reason", 403, `{"error": {"status": 403, "message": "Player command failed", "reason": "RATE_LIMITED"}}`, ErrRateLimited, ReasonRateLimited},
+		{"reason from message", 404, `{"error": {"status": 404, "message": "Player command failed: No active device found"}}`, ErrNoActiveDevice, ReasonNoActiveDevice},
+		{"not found", 404, `{"error": {"status": 404, "message": "Non existing id"}}`, ErrNotFound, ""},
+		{"unauthorized", 401, `{"error": {"status": 401, "message": "The access token expired"}}`, ErrNotAuthenticated, ""},
+		{"server error", 502, `{"error": {"status": 502, "message": "Bad gateway"}}`, ErrUnavailable, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := failingAPI(t, tt.status, tt.body)
+
+			_, err := client.PlayerDevices(context.Background())
+			err = HandleAPIError(err)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("error %v, want %v", err, tt.want)
+			}
+
+			var apiErr *Error
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("error %T, want *Error", err)
+			}
+			if apiErr.Status != tt.status || apiErr.Reason != tt.wantReason {
+				t.Errorf("status %d and reason %q, want %d and %q", apiErr.Status, apiErr.Reason, tt.status, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestHandleAPIErrorReasonsNotShared(t *testing.T) {
+	// The same status and message with different reasons, as from two
+	// accounts in one process, each keep their own
+	premium := failingAPI(t, 403, `{"error": {"status": 403, "message": "Player command failed", "reason": "PREMIUM_REQUIRED"}}`)
+	limited := failingAPI(t, 403, `{"error": {"status": 403, "message": "Player command failed", "reason": "RATE_LIMITED"}}`)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if err := HandleAPIError(premium.Pause(context.Background())); !errors.Is(err, ErrPremiumRequired) {
+				t.Errorf("error %v, want %v", err, ErrPremiumRequired)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			if err := HandleAPIError(limited.Pause(context.Background())); !errors.Is(err, ErrRateLimited) {
+				t.Errorf("error %v, want %v", err, ErrRateLimited)
+			}
+		}()
+	}
+	wg.Wait()
+}
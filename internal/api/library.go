@@ -3,7 +3,9 @@ package api
 import (
 	"context"
 	"fmt"
+	"iter"
 
+	"github.com/AustinMusiku/spotifycli/internal/auth"
 	"github.com/zmb3/spotify/v2"
 )
 
@@ -16,60 +18,126 @@ func NewLibraryService(client *Client) *LibraryService {
 	return &LibraryService{client: client}
 }
 
-// GetUserPlaylists gets the user's playlists
-func (l *LibraryService) GetUserPlaylists(ctx context.Context, limit int) (*spotify.SimplePlaylistPage, error) {
-	if err := l.client.EnsureAuthenticated(ctx); err != nil {
-		return nil, err
-	}
+// maxPageSize is the most items Spotify returns in one page
+const maxPageSize = 50
+
+// PageOptions selects the items a listing streams
+type PageOptions struct {
+	// Offset is the index of the first item
+	Offset int
+	// Limit is the most items to stream, every item from Offset on when zero
+	Limit int
+	// Progress, when set, is called after each page is fetched with the
+	// number of items fetched so far and the total available from Offset
+	Progress func(fetched, total int)
+}
 
-	playlists, err := l.client.GetSpotifyClient().CurrentUsersPlaylists(ctx, spotify.Limit(limit))
-	if err != nil {
-		return nil, HandleAPIError(err)
+// paginate streams items a page at a time. fetch gets one page and returns
+// its items along with the total number of items.
+func paginate[T any](ctx context.Context, opts PageOptions, fetch func(ctx context.Context, opts ...spotify.RequestOption) ([]T, int, error)) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		offset := max(opts.Offset, 0)
+		fetched := 0
+
+		for opts.Limit <= 0 || fetched < opts.Limit {
+			size := maxPageSize
+			if opts.Limit > 0 {
+				size = min(size, opts.Limit-fetched)
+			}
+
+			items, total, err := fetch(ctx, spotify.Limit(size), spotify.Offset(offset))
+			if err != nil {
+				var zero T
+				yield(zero, err)
+				return
+			}
+
+			fetched += len(items)
+			offset += len(items)
+
+			if opts.Progress != nil {
+				available := max(total-max(opts.Offset, 0), 0)
+				if opts.Limit > 0 {
+					available = min(available, opts.Limit)
+				}
+				opts.Progress(fetched, available)
+			}
+
+			for _, item := range items {
+				if !yield(item, nil) {
+					return
+				}
+			}
+
+			if len(items) < size || offset >= total {
+				return
+			}
+		}
 	}
+}
 
-	return playlists, nil
+// Playlists streams the user's playlists
+func (l *LibraryService) Playlists(ctx context.Context, opts PageOptions) iter.Seq2[spotify.SimplePlaylist, error] {
+	return paginate(ctx, opts, func(ctx context.Context, reqOpts ...spotify.RequestOption) ([]spotify.SimplePlaylist, int, error) {
+		if err := l.client.EnsureAuthenticated(ctx); err != nil {
+			return nil, 0, err
+		}
+
+		page, err := l.client.GetSpotifyClient().CurrentUsersPlaylists(ctx, reqOpts...)
+		if err != nil {
+			return nil, 0, l.client.apiError(err, auth.ScopePlaylistReadPrivate)
+		}
+
+		return page.Playlists, int(page.Total), nil
+	})
 }
 
-// GetSavedAlbums gets the user's saved albums
-func (l *LibraryService) GetSavedAlbums(ctx context.Context, limit int) (*spotify.SavedAlbumPage, error) {
-	if err := l.client.EnsureAuthenticated(ctx); err != nil {
-		return nil, err
-	}
+// SavedAlbums streams the user's saved albums
+func (l *LibraryService) SavedAlbums(ctx context.Context, opts PageOptions) iter.Seq2[spotify.SavedAlbum, error] {
+	return paginate(ctx, opts, func(ctx context.Context, reqOpts ...spotify.RequestOption) ([]spotify.SavedAlbum, int, error) {
+		if err := l.client.EnsureAuthenticated(ctx); err != nil {
+			return nil, 0, err
+		}
 
-	albums, err := l.client.GetSpotifyClient().CurrentUsersAlbums(ctx, spotify.Limit(limit))
-	if err != nil {
-		return nil, HandleAPIError(err)
-	}
+		page, err := l.client.GetSpotifyClient().CurrentUsersAlbums(ctx, reqOpts...)
+		if err != nil {
+			return nil, 0, l.client.apiError(err, auth.ScopeLibraryRead)
+		}
 
-	return albums, nil
+		return page.Albums, int(page.Total), nil
+	})
 }
 
-// GetSavedTracks gets the user's saved tracks
-func (l *LibraryService) GetSavedTracks(ctx context.Context, limit int) (*spotify.SavedTrackPage, error) {
-	if err := l.client.EnsureAuthenticated(ctx); err != nil {
-		return nil, err
-	}
+// SavedTracks streams the user's saved tracks
+func (l *LibraryService) SavedTracks(ctx context.Context, opts PageOptions) iter.Seq2[spotify.SavedTrack, error] {
+	return paginate(ctx, opts, func(ctx context.Context, reqOpts ...spotify.RequestOption) ([]spotify.SavedTrack, int, error) {
+		if err := l.client.EnsureAuthenticated(ctx); err != nil {
+			return nil, 0, err
+		}
 
-	tracks, err := l.client.GetSpotifyClient().CurrentUsersTracks(ctx, spotify.Limit(limit))
-	if err != nil {
-		return nil, HandleAPIError(err)
-	}
+		page, err := l.client.GetSpotifyClient().CurrentUsersTracks(ctx, reqOpts...)
+		if err != nil {
+			return nil, 0, l.client.apiError(err, auth.ScopeLibraryRead)
+		}
 
-	return tracks, nil
+		return page.Tracks, int(page.Total), nil
+	})
 }
 
-// GetSavedShows gets the user's saved shows (podcasts)
-func (l *LibraryService) GetSavedShows(ctx context.Context, limit int) (*spotify.SavedShowPage, error) {
-	if err := l.client.EnsureAuthenticated(ctx); err != nil {
-		return nil, err
-	}
+// SavedShows streams the user's saved shows (podcasts)
+func (l *LibraryService) SavedShows(ctx context.Context, opts PageOptions) iter.Seq2[spotify.SavedShow, error] {
+	return paginate(ctx, opts, func(ctx context.Context, reqOpts ...spotify.RequestOption) ([]spotify.SavedShow, int, error) {
+		if err := l.client.EnsureAuthenticated(ctx); err != nil {
+			return nil, 0, err
+		}
 
-	shows, err := l.client.GetSpotifyClient().CurrentUsersShows(ctx, spotify.Limit(limit))
-	if err != nil {
-		return nil, HandleAPIError(err)
-	}
+		page, err := l.client.GetSpotifyClient().CurrentUsersShows(ctx, reqOpts...)
+		if err != nil {
+			return nil, 0, l.client.apiError(err, auth.ScopeLibraryRead)
+		}
 
-	return shows, nil
+		return page.Shows, int(page.Total), nil
+	})
 }
 
 // SaveTrack saves a track to the user's library
@@ -80,7 +148,7 @@ func (l *LibraryService) SaveTrack(ctx context.Context, trackID spotify.ID) erro
 
 	err := l.client.GetSpotifyClient().AddTracksToLibrary(ctx, trackID)
 	if err != nil {
-		return HandleAPIError(err)
+		return l.client.apiError(err, auth.ScopeLibraryModify)
 	}
 
 	return nil
@@ -94,7 +162,7 @@ func (l *LibraryService) RemoveTrack(ctx context.Context, trackID spotify.ID) er
 
 	err := l.client.GetSpotifyClient().RemoveTracksFromLibrary(ctx, trackID)
 	if err != nil {
-		return HandleAPIError(err)
+		return l.client.apiError(err, auth.ScopeLibraryModify)
 	}
 
 	return nil
@@ -108,7 +176,7 @@ func (l *LibraryService) SaveAlbum(ctx context.Context, albumID spotify.ID) erro
 
 	err := l.client.GetSpotifyClient().AddAlbumsToLibrary(ctx, albumID)
 	if err != nil {
-		return HandleAPIError(err)
+		return l.client.apiError(err, auth.ScopeLibraryModify)
 	}
 
 	return nil
@@ -122,7 +190,7 @@ func (l *LibraryService) RemoveAlbum(ctx context.Context, albumID spotify.ID) er
 
 	err := l.client.GetSpotifyClient().RemoveAlbumsFromLibrary(ctx, albumID)
 	if err != nil {
-		return HandleAPIError(err)
+		return l.client.apiError(err, auth.ScopeLibraryModify)
 	}
 
 	return nil
@@ -0,0 +1,85 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/zmb3/spotify/v2"
+)
+
+// What the player is playing, as Spotify reports it in currently_playing_type
+const (
+	PlayingTrack   = "track"
+	PlayingEpisode = "episode"
+	PlayingAd      = "ad"
+	PlayingUnknown = "unknown"
+)
+
+// NowPlaying is the state of the player and the item it is playing, which can
+// be a track, a podcast episode or an ad
+type NowPlaying struct {
+	// Type is one of PlayingTrack, PlayingEpisode, PlayingAd or PlayingUnknown
+	Type string
+	// Track is set when a track is playing
+	Track *spotify.FullTrack
+	// Episode is set when a podcast episode is playing
+	Episode *spotify.EpisodePage
+
+	Playing bool
+	// Progress is the position in the item in milliseconds
+	Progress int
+	Context  spotify.PlaybackContext
+	Device   spotify.PlayerDevice
+	Shuffle  bool
+	Repeat   string
+}
+
+// playerState is the body of GET /me/player. Unlike spotify.PlayerState its
+// item is decoded according to currently_playing_type, so it can be an episode.
+type playerState struct {
+	Device               spotify.PlayerDevice     `json:"device"`
+	ShuffleState         bool                     `json:"shuffle_state"`
+	RepeatState          string                   `json:"repeat_state"`
+	Context              *spotify.PlaybackContext `json:"context"`
+	Progress             spotify.Numeric          `json:"progress_ms"`
+	Playing              bool                     `json:"is_playing"`
+	CurrentlyPlayingType string                   `json:"currently_playing_type"`
+	Item                 json.RawMessage          `json:"item"`
+}
+
+func (s *playerState) nowPlaying() (*NowPlaying, error) {
+	n := &NowPlaying{
+		Type:     s.CurrentlyPlayingType,
+		Playing:  s.Playing,
+		Progress: int(s.Progress),
+		Device:   s.Device,
+		Shuffle:  s.ShuffleState,
+		Repeat:   s.RepeatState,
+	}
+	if n.Type == "" {
+		n.Type = PlayingUnknown
+	}
+	if s.Context != nil {
+		n.Context = *s.Context
+	}
+
+	// Ads come without an item
+	if len(s.Item) == 0 || string(s.Item) == "null" {
+		return n, nil
+	}
+
+	var err error
+	switch n.Type {
+	case PlayingTrack:
+		n.Track = new(spotify.FullTrack)
+		err = json.Unmarshal(s.Item, n.Track)
+	case PlayingEpisode:
+		n.Episode = new(spotify.EpisodePage)
+		err = json.Unmarshal(s.Item, n.Episode)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode the %s playing: %w", n.Type, err)
+	}
+
+	return n, nil
+}
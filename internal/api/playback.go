@@ -3,7 +3,10 @@ package api
 import (
 	"context"
 	"fmt"
+	"net/url"
+	"time"
 
+	"github.com/AustinMusiku/spotifycli/internal/auth"
 	"github.com/zmb3/spotify/v2"
 )
 
@@ -17,42 +20,83 @@ func NewPlaybackService(client *Client) *PlaybackService {
 	return &PlaybackService{client: client}
 }
 
-// GetCurrentPlayback gets the current playback state
-func (p *PlaybackService) GetCurrentPlayback(ctx context.Context) (*spotify.CurrentlyPlaying, *spotify.PlayerState, error) {
+// playOptions targets a command at deviceID, or at the active device when it
+// is empty
+func playOptions(deviceID spotify.ID) *spotify.PlayOptions {
+	opts := &spotify.PlayOptions{}
+	if deviceID != "" {
+		opts.DeviceID = &deviceID
+	}
+	return opts
+}
+
+// GetCurrentPlayback gets the player's state and the track, episode or ad it
+// is playing in a single call. It returns nil when no device is active.
+func (p *PlaybackService) GetCurrentPlayback(ctx context.Context) (*NowPlaying, error) {
 	if err := p.client.EnsureAuthenticated(ctx); err != nil {
-		return nil, nil, err
+		return nil, err
 	}
 
-	playback, err := p.client.GetSpotifyClient().PlayerCurrentlyPlaying(ctx)
+	// Without additional_types Spotify leaves out episodes
+	query := url.Values{"additional_types": {"track,episode"}}
+
+	var state playerState
+	found, err := p.client.getJSON(ctx, "me/player", query, &state)
 	if err != nil {
-		return nil, nil, HandleAPIError(err)
+		return nil, p.client.apiError(err, auth.ScopeReadPlaybackState)
 	}
-
-	playerState, error := p.client.GetSpotifyClient().PlayerState(ctx)
-	if error != nil {
-		return nil, nil, HandleAPIError(error)
+	if !found {
+		return nil, nil
 	}
 
-	return playback, playerState, nil
+	return state.nowPlaying()
+}
+
+// PlayOptions says what to play. With neither a context nor items, playback
+// resumes.
+type PlayOptions struct {
+	// Context is an album, playlist, artist or show to play
+	Context spotify.URI
+	// Items are tracks or episodes to play in order
+	Items []spotify.URI
+	// Offset is the zero based index of the item to start at, unless
+	// OffsetURI names it instead
+	Offset    int
+	OffsetURI spotify.URI
+	// Position is where to start in the first item
+	Position time.Duration
 }
 
 // Play starts or resumes playback
-func (p *PlaybackService) Play(ctx context.Context, deviceID spotify.ID, uri spotify.URI) error {
+func (p *PlaybackService) Play(ctx context.Context, deviceID spotify.ID, opts PlayOptions) error {
 	if err := p.client.EnsureAuthenticated(ctx); err != nil {
 		return err
 	}
 
-	opts := &spotify.PlayOptions{
-		DeviceID: &deviceID,
+	if opts.Context != "" && len(opts.Items) > 0 {
+		return fmt.Errorf("can't play a context and items together")
+	}
+
+	playOpts := playOptions(deviceID)
+
+	// Albums, playlists, artists and shows are sent as a context, which
+	// Spotify rejects in the list of items
+	if opts.Context != "" {
+		playOpts.PlaybackContext = &opts.Context
 	}
+	playOpts.URIs = opts.Items
 
-	if uri != "" {
-		opts.URIs = []spotify.URI{uri}
+	if opts.OffsetURI != "" {
+		playOpts.PlaybackOffset = &spotify.PlaybackOffset{URI: opts.OffsetURI}
+	} else if opts.Offset > 0 {
+		playOpts.PlaybackOffset = &spotify.PlaybackOffset{Position: &opts.Offset}
 	}
 
-	err := p.client.GetSpotifyClient().PlayOpt(ctx, opts)
+	playOpts.PositionMs = spotify.Numeric(opts.Position.Milliseconds())
+
+	err := p.client.GetSpotifyClient().PlayOpt(ctx, playOpts)
 	if err != nil {
-		return HandleAPIError(err)
+		return p.client.apiError(err, auth.ScopeModifyPlaybackState)
 	}
 
 	return nil
@@ -64,11 +108,9 @@ func (p *PlaybackService) Pause(ctx context.Context, deviceID spotify.ID) error
 		return err
 	}
 
-	err := p.client.GetSpotifyClient().PauseOpt(ctx, &spotify.PlayOptions{
-		DeviceID: &deviceID,
-	})
+	err := p.client.GetSpotifyClient().PauseOpt(ctx, playOptions(deviceID))
 	if err != nil {
-		return HandleAPIError(err)
+		return p.client.apiError(err, auth.ScopeModifyPlaybackState)
 	}
 
 	return nil
@@ -80,11 +122,9 @@ func (p *PlaybackService) Next(ctx context.Context, deviceID spotify.ID) error {
 		return err
 	}
 
-	err := p.client.GetSpotifyClient().NextOpt(ctx, &spotify.PlayOptions{
-		DeviceID: &deviceID,
-	})
+	err := p.client.GetSpotifyClient().NextOpt(ctx, playOptions(deviceID))
 	if err != nil {
-		return HandleAPIError(err)
+		return p.client.apiError(err, auth.ScopeModifyPlaybackState)
 	}
 
 	return nil
@@ -96,11 +136,9 @@ func (p *PlaybackService) Previous(ctx context.Context, deviceID spotify.ID) err
 		return err
 	}
 
-	err := p.client.GetSpotifyClient().PreviousOpt(ctx, &spotify.PlayOptions{
-		DeviceID: &deviceID,
-	})
+	err := p.client.GetSpotifyClient().PreviousOpt(ctx, playOptions(deviceID))
 	if err != nil {
-		return HandleAPIError(err)
+		return p.client.apiError(err, auth.ScopeModifyPlaybackState)
 	}
 
 	return nil
@@ -116,9 +154,9 @@ func (p *PlaybackService) SetVolume(ctx context.Context, deviceID spotify.ID, vo
 		return fmt.Errorf("volume must be between 0 and 100")
 	}
 
-	err := p.client.GetSpotifyClient().Volume(ctx, volume)
+	err := p.client.GetSpotifyClient().VolumeOpt(ctx, volume, playOptions(deviceID))
 	if err != nil {
-		return HandleAPIError(err)
+		return p.client.apiError(err, auth.ScopeModifyPlaybackState)
 	}
 
 	return nil
@@ -130,9 +168,9 @@ func (p *PlaybackService) SetShuffle(ctx context.Context, deviceID spotify.ID, s
 		return err
 	}
 
-	err := p.client.GetSpotifyClient().Shuffle(ctx, shuffle)
+	err := p.client.GetSpotifyClient().ShuffleOpt(ctx, shuffle, playOptions(deviceID))
 	if err != nil {
-		return HandleAPIError(err)
+		return p.client.apiError(err, auth.ScopeModifyPlaybackState)
 	}
 
 	return nil
@@ -148,9 +186,9 @@ func (p *PlaybackService) SetRepeat(ctx context.Context, deviceID spotify.ID, st
 		return fmt.Errorf("invalid repeat state: %s (must be 'off', 'track', or 'context')", state)
 	}
 
-	err := p.client.GetSpotifyClient().Repeat(ctx, state)
+	err := p.client.GetSpotifyClient().RepeatOpt(ctx, state, playOptions(deviceID))
 	if err != nil {
-		return HandleAPIError(err)
+		return p.client.apiError(err, auth.ScopeModifyPlaybackState)
 	}
 
 	return nil
@@ -164,7 +202,7 @@ func (p *PlaybackService) GetQueue(ctx context.Context) (*spotify.Queue, error)
 
 	queue, err := p.client.GetSpotifyClient().GetQueue(ctx)
 	if err != nil {
-		return nil, HandleAPIError(err)
+		return nil, p.client.apiError(err, auth.ScopeReadPlaybackState)
 	}
 
 	return queue, nil
@@ -176,9 +214,9 @@ func (p *PlaybackService) AddToQueue(ctx context.Context, id spotify.ID, deviceI
 		return err
 	}
 
-	err := p.client.GetSpotifyClient().QueueSong(ctx, id)
+	err := p.client.GetSpotifyClient().QueueSongOpt(ctx, id, playOptions(deviceID))
 	if err != nil {
-		return HandleAPIError(err)
+		return p.client.apiError(err, auth.ScopeModifyPlaybackState)
 	}
 
 	return nil
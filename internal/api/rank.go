@@ -0,0 +1,235 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/zmb3/spotify/v2"
+)
+
+// Spotify orders search results by its own relevance, which often puts a
+// cover or karaoke version first. Results are ranked again here by how well
+// their title and artist match the query, with Spotify's order and the
+// result's popularity breaking ties.
+
+// rankLimit is how many results are fetched to rank
+const rankLimit = 20
+
+// Weights of the parts of a match's score
+const (
+	weightCoverage   = 40.0
+	weightPrecision  = 30.0
+	weightExact      = 20.0
+	weightArtist     = 10.0
+	weightPopularity = 10.0
+	weightOrder      = 5.0
+	penaltyVariant   = -15.0
+)
+
+// variantWords mark versions of a song people rarely mean unless they ask for them
+var variantWords = []string{"karaoke", "cover", "tribute", "instrumental", "remix", "acoustic", "lullaby", "originally", "backing"}
+
+// Match is a search result scored against the query it was found with
+type Match struct {
+	// Type is track, album, artist, playlist, show or episode
+	Type string
+	URI  spotify.URI
+	// Name is the title of the result
+	Name string
+	// Artist is who made it: the artists of a track or album, the owner of
+	// a playlist or the publisher of a show. It is empty for an artist.
+	Artist string
+	// Popularity is between 0 and 100, or -1 when Spotify doesn't give one
+	Popularity int
+	// Order is the result's position in Spotify's results, from 0
+	Order int
+	// Item is the spotify type of the result, such as spotify.FullTrack
+	Item any
+
+	Score float64
+	// Parts add up to the score and say where it came from
+	Parts []ScorePart
+}
+
+// ScorePart is one reason for a match's score
+type ScorePart struct {
+	Points float64
+	Reason string
+}
+
+// BestMatches searches for one type of content and returns the results best
+// matching the query first
+func (s *SearchService) BestMatches(ctx context.Context, query, contentType string) ([]Match, error) {
+	var matches []Match
+
+	switch contentType {
+	case "track":
+		results, err := s.SearchTracks(ctx, query, rankLimit)
+		if err != nil {
+			return nil, err
+		}
+		for _, track := range results.Tracks.Tracks {
+			matches = append(matches, Match{Name: track.Name, URI: track.URI, Artist: artistNames(track.Artists), Popularity: int(track.Popularity), Item: track})
+		}
+	case "album":
+		results, err := s.SearchAlbums(ctx, query, rankLimit)
+		if err != nil {
+			return nil, err
+		}
+		for _, album := range results.Albums.Albums {
+			matches = append(matches, Match{Name: album.Name, URI: album.URI, Artist: artistNames(album.Artists), Popularity: -1, Item: album})
+		}
+	case "artist":
+		results, err := s.SearchArtists(ctx, query, rankLimit)
+		if err != nil {
+			return nil, err
+		}
+		for _, artist := range results.Artists.Artists {
+			matches = append(matches, Match{Name: artist.Name, URI: artist.URI, Popularity: int(artist.Popularity), Item: artist})
+		}
+	case "playlist":
+		results, err := s.SearchPlaylists(ctx, query, rankLimit)
+		if err != nil {
+			return nil, err
+		}
+		for _, playlist := range results.Playlists.Playlists {
+			matches = append(matches, Match{Name: playlist.Name, URI: playlist.URI, Artist: playlist.Owner.DisplayName, Popularity: -1, Item: playlist})
+		}
+	case "show":
+		results, err := s.SearchShows(ctx, query, rankLimit)
+		if err != nil {
+			return nil, err
+		}
+		for _, show := range results.Shows.Shows {
+			matches = append(matches, Match{Name: show.Name, URI: show.URI, Artist: show.Publisher, Popularity: -1, Item: show.SimpleShow})
+		}
+	case "episode":
+		results, err := s.SearchEpisodes(ctx, query, rankLimit)
+		if err != nil {
+			return nil, err
+		}
+		for _, episode := range results.Episodes.Episodes {
+			matches = append(matches, Match{Name: episode.Name, URI: episode.URI, Artist: episode.Show.Name, Popularity: -1, Item: episode})
+		}
+	default:
+		return nil, fmt.Errorf("unsupported content type: %s", contentType)
+	}
+
+	for i := range matches {
+		matches[i].Type = contentType
+	}
+	rank(query, matches)
+
+	return matches, nil
+}
+
+// rank scores matches in Spotify's order against the query and sorts them
+// best first
+func rank(query string, matches []Match) {
+	for i := range matches {
+		matches[i].Order = i
+		matches[i].score(query, len(matches))
+	}
+
+	// Stable, so equal scores keep Spotify's order
+	slices.SortStableFunc(matches, func(a, b Match) int {
+		switch {
+		case a.Score > b.Score:
+			return -1
+		case a.Score < b.Score:
+			return 1
+		}
+		return 0
+	})
+}
+
+// score rates the match against the query, out of about 100
+func (m *Match) score(query string, results int) {
+	queryWords := words(query)
+	titleWords := words(m.Name)
+	artistWords := words(m.Artist)
+
+	add := func(points float64, reason string) {
+		if points != 0 {
+			m.Parts = append(m.Parts, ScorePart{Points: points, Reason: reason})
+			m.Score += points
+		}
+	}
+
+	// How much of the query the title and artist account for
+	found := 0
+	for _, word := range queryWords {
+		if slices.Contains(titleWords, word) || slices.Contains(artistWords, word) {
+			found++
+		}
+	}
+	if len(queryWords) > 0 {
+		add(weightCoverage*float64(found)/float64(len(queryWords)),
+			fmt.Sprintf("%d of %d query words in the title or artist", found, len(queryWords)))
+	}
+
+	// How much of the title was asked for, so extra words like "live" count against it
+	asked := 0
+	for _, word := range titleWords {
+		if slices.Contains(queryWords, word) {
+			asked++
+		}
+	}
+	if len(titleWords) > 0 {
+		add(weightPrecision*float64(asked)/float64(len(titleWords)),
+			fmt.Sprintf("%d of %d title words in the query", asked, len(titleWords)))
+	}
+
+	q, title, artist := strings.Join(queryWords, " "), strings.Join(titleWords, " "), strings.Join(artistWords, " ")
+	switch {
+	case q == title:
+		add(weightExact, "exact title match")
+	case artist != "" && (q == title+" "+artist || q == artist+" "+title):
+		add(weightExact, "exact title and artist match")
+	}
+
+	for _, name := range strings.Split(m.Artist, ", ") {
+		if name := strings.Join(words(name), " "); name != "" && containsPhrase(q, name) {
+			add(weightArtist, "artist named in the query")
+			break
+		}
+	}
+
+	if m.Popularity >= 0 {
+		add(weightPopularity*float64(m.Popularity)/100, fmt.Sprintf("popularity %d", m.Popularity))
+	}
+
+	add(weightOrder*float64(results-m.Order)/float64(results),
+		fmt.Sprintf("#%d in Spotify's results", m.Order+1))
+
+	for _, word := range variantWords {
+		if (slices.Contains(titleWords, word) || slices.Contains(artistWords, word)) && !slices.Contains(queryWords, word) {
+			add(penaltyVariant, fmt.Sprintf("looks like a %s version", word))
+			break
+		}
+	}
+}
+
+// words normalizes text to lowercase words, ignoring punctuation
+func words(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// containsPhrase reports whether the words of phrase appear together in text
+func containsPhrase(text, phrase string) bool {
+	return strings.Contains(" "+text+" ", " "+phrase+" ")
+}
+
+// artistNames lists the names of artists, separated by commas
+func artistNames(artists []spotify.SimpleArtist) string {
+	names := make([]string, len(artists))
+	for i, artist := range artists {
+		names[i] = artist.Name
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,131 @@
+package api
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/zmb3/spotify/v2"
+)
+
+func TestRank(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		// results are in Spotify's order
+		results []Match
+		want    spotify.URI
+		// reason must be among the winner's score parts
+		reason string
+	}{
+		{
+			name:  "exact title over a variant and a longer title",
+			query: "dreams",
+			results: []Match{
+				{URI: "spotify:track:karaoke", Name: "Dreams (Karaoke Version)", Artist: "Sing Along Stars", Popularity: 60},
+				{URI: "spotify:track:sweetdreams", Name: "Sweet Dreams", Artist: "Eurythmics", Popularity: 80},
+				{URI: "spotify:track:dreams", Name: "Dreams", Artist: "Fleetwood Mac", Popularity: 70},
+			},
+			want:   "spotify:track:dreams",
+			reason: "exact title match",
+		},
+		{
+			name:  "artist named in the query",
+			query: "creep radiohead",
+			results: []Match{
+				{URI: "spotify:track:stp", Name: "Creep", Artist: "Stone Temple Pilots", Popularity: 60},
+				{URI: "spotify:track:radiohead", Name: "Creep", Artist: "Radiohead", Popularity: 50},
+			},
+			want:   "spotify:track:radiohead",
+			reason: "artist named in the query",
+		},
+		{
+			name:  "one of several artists named",
+			query: "get lucky pharrell williams",
+			results: []Match{
+				{URI: "spotify:track:cover", Name: "Get Lucky", Artist: "The Lucky Band", Popularity: 40},
+				{URI: "spotify:track:daftpunk", Name: "Get Lucky", Artist: "Daft Punk, Pharrell Williams", Popularity: 30},
+			},
+			want:   "spotify:track:daftpunk",
+			reason: "artist named in the query",
+		},
+		{
+			name:  "popularity breaks a tie",
+			query: "karma police",
+			results: []Match{
+				{URI: "spotify:track:compilation", Name: "Karma Police", Artist: "Radiohead", Popularity: 40},
+				{URI: "spotify:track:original", Name: "Karma Police", Artist: "Radiohead", Popularity: 90},
+			},
+			want:   "spotify:track:original",
+			reason: "popularity 90",
+		},
+		{
+			name:  "Spotify's order breaks a full tie",
+			query: "karma police",
+			results: []Match{
+				{URI: "spotify:track:first", Name: "Karma Police", Artist: "Radiohead", Popularity: 70},
+				{URI: "spotify:track:second", Name: "Karma Police", Artist: "Radiohead", Popularity: 70},
+			},
+			want:   "spotify:track:first",
+			reason: "#1 in Spotify's results",
+		},
+		{
+			name:  "variant asked for",
+			query: "creep acoustic",
+			results: []Match{
+				{URI: "spotify:track:studio", Name: "Creep", Artist: "Radiohead", Popularity: 80},
+				{URI: "spotify:track:acoustic", Name: "Creep - Acoustic", Artist: "Radiohead", Popularity: 50},
+			},
+			want:   "spotify:track:acoustic",
+			reason: "2 of 2 query words in the title or artist",
+		},
+		{
+			name:  "album without popularity",
+			query: "ok computer",
+			results: []Match{
+				{URI: "spotify:album:oknotok", Name: "OK Computer OKNOTOK 1997 2017", Artist: "Radiohead", Popularity: -1},
+				{URI: "spotify:album:okcomputer", Name: "OK Computer", Artist: "Radiohead", Popularity: -1},
+			},
+			want:   "spotify:album:okcomputer",
+			reason: "exact title match",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matches := append([]Match(nil), tt.results...)
+			rank(tt.query, matches)
+
+			best := matches[0]
+			if best.URI != tt.want {
+				var scores []string
+				for _, m := range matches {
+					scores = append(scores, fmt.Sprintf("%s %.1f", m.URI, m.Score))
+				}
+				t.Fatalf("best match %s, want %s (%s)", best.URI, tt.want, strings.Join(scores, ", "))
+			}
+
+			found := false
+			total := 0.0
+			for _, part := range best.Parts {
+				found = found || part.Reason == tt.reason
+				total += part.Points
+			}
+			if !found {
+				t.Errorf("score parts %+v, want %q among them", best.Parts, tt.reason)
+			}
+			if total != best.Score {
+				t.Errorf("score parts add up to %.2f, score is %.2f", total, best.Score)
+			}
+		})
+	}
+}
+
+func TestWords(t *testing.T) {
+	if got := strings.Join(words("Harder, Better, Faster, Stronger!"), " "); got != "harder better faster stronger" {
+		t.Errorf("words = %q", got)
+	}
+	if got := strings.Join(words("Beyoncé – Halo (Live)"), " "); got != "beyoncé halo live" {
+		t.Errorf("words = %q", got)
+	}
+}
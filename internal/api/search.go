@@ -30,7 +30,7 @@ type SearchResult struct {
 
 // Search performs a search across all content types
 func (s *SearchService) Search(ctx context.Context, query string, limit int) (*SearchResult, error) {
-	if err := s.client.EnsureAuthenticated(ctx); err != nil {
+	if err := s.client.EnsureCatalogAccess(ctx); err != nil {
 		return nil, err
 	}
 
@@ -56,7 +56,7 @@ func (s *SearchService) Search(ctx context.Context, query string, limit int) (*S
 
 // SearchTracks searches for tracks only
 func (s *SearchService) SearchTracks(ctx context.Context, query string, limit int) (*spotify.SearchResult, error) {
-	if err := s.client.EnsureAuthenticated(ctx); err != nil {
+	if err := s.client.EnsureCatalogAccess(ctx); err != nil {
 		return nil, err
 	}
 
@@ -74,7 +74,7 @@ func (s *SearchService) SearchTracks(ctx context.Context, query string, limit in
 
 // SearchAlbums searches for albums only
 func (s *SearchService) SearchAlbums(ctx context.Context, query string, limit int) (*spotify.SearchResult, error) {
-	if err := s.client.EnsureAuthenticated(ctx); err != nil {
+	if err := s.client.EnsureCatalogAccess(ctx); err != nil {
 		return nil, err
 	}
 
@@ -92,7 +92,7 @@ func (s *SearchService) SearchAlbums(ctx context.Context, query string, limit in
 
 // SearchArtists searches for artists only
 func (s *SearchService) SearchArtists(ctx context.Context, query string, limit int) (*spotify.SearchResult, error) {
-	if err := s.client.EnsureAuthenticated(ctx); err != nil {
+	if err := s.client.EnsureCatalogAccess(ctx); err != nil {
 		return nil, err
 	}
 
@@ -110,7 +110,7 @@ func (s *SearchService) SearchArtists(ctx context.Context, query string, limit i
 
 // SearchPlaylists searches for playlists only
 func (s *SearchService) SearchPlaylists(ctx context.Context, query string, limit int) (*spotify.SearchResult, error) {
-	if err := s.client.EnsureAuthenticated(ctx); err != nil {
+	if err := s.client.EnsureCatalogAccess(ctx); err != nil {
 		return nil, err
 	}
 
@@ -128,7 +128,7 @@ func (s *SearchService) SearchPlaylists(ctx context.Context, query string, limit
 
 // SearchShows searches for shows (podcasts) only
 func (s *SearchService) SearchShows(ctx context.Context, query string, limit int) (*spotify.SearchResult, error) {
-	if err := s.client.EnsureAuthenticated(ctx); err != nil {
+	if err := s.client.EnsureCatalogAccess(ctx); err != nil {
 		return nil, err
 	}
 
@@ -146,7 +146,7 @@ func (s *SearchService) SearchShows(ctx context.Context, query string, limit int
 
 // SearchEpisodes searches for episodes only
 func (s *SearchService) SearchEpisodes(ctx context.Context, query string, limit int) (*spotify.SearchResult, error) {
-	if err := s.client.EnsureAuthenticated(ctx); err != nil {
+	if err := s.client.EnsureCatalogAccess(ctx); err != nil {
 		return nil, err
 	}
 
@@ -186,3 +186,19 @@ func ParseURI(uri string) (spotify.ID, spotify.URI, error) {
 
 	return "", "", fmt.Errorf("unsupported content type: %s", contentType)
 }
+
+// IsContextURI reports whether uri is an album, playlist, artist or show,
+// which are played as a context rather than as a list of items
+func IsContextURI(uri spotify.URI) bool {
+	kind, _, _ := strings.Cut(strings.TrimPrefix(string(uri), "spotify:"), ":")
+	return IsContextType(kind)
+}
+
+// IsContextType reports whether a content type is played as a context
+func IsContextType(contentType string) bool {
+	switch contentType {
+	case "album", "playlist", "artist", "show":
+		return true
+	}
+	return false
+}
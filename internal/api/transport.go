@@ -0,0 +1,108 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/zmb3/spotify/v2"
+)
+
+// refreshTransport authorizes requests with the client's current access token.
+// When Spotify rejects a token with a 401 the token is refreshed and the
+// request is retried once, so long running commands survive token expiry.
+type refreshTransport struct {
+	client *Client
+	base   http.RoundTripper
+}
+
+func (t *refreshTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token := t.client.currentAccessToken()
+
+	resp, err := t.roundTrip(req, token)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+
+	// A request body that cannot be replayed cannot be retried
+	if req.Body != nil && req.GetBody == nil {
+		return resp, nil
+	}
+
+	if err := t.client.refreshIfStale(req.Context(), token); err != nil {
+		// A login that can no longer be refreshed is reported as such
+		if errors.Is(err, ErrTokenExpired) {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+			return nil, err
+		}
+
+		// Surface the original 401 so the caller reports it as an auth failure
+		return resp, nil
+	}
+
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	retry := req.Clone(req.Context())
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		retry.Body = body
+	}
+
+	return t.roundTrip(retry, t.client.currentAccessToken())
+}
+
+func (t *refreshTransport) roundTrip(req *http.Request, token string) (*http.Response, error) {
+	// RoundTrippers must not modify the caller's request
+	authed := req.Clone(req.Context())
+	authed.Header.Set("Authorization", "Bearer "+token)
+
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	return base.RoundTrip(authed)
+}
+
+// reasonTransport fails requests for which Spotify gives a reason, such as
+// NO_ACTIVE_DEVICE, with a reasonError, as spotify.Error has no field for it
+type reasonTransport struct {
+	base http.RoundTripper
+}
+
+func (t *reasonTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil || resp.StatusCode < http.StatusBadRequest {
+		return resp, err
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	var body struct {
+		Error struct {
+			Message string `json:"message"`
+			Reason  string `json:"reason"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(data, &body) == nil && body.Error.Reason != "" {
+		return nil, &reasonError{
+			err:    spotify.Error{Status: resp.StatusCode, Message: body.Error.Message},
+			reason: body.Error.Reason,
+		}
+	}
+
+	// Put the body back for the spotify client to decode
+	resp.Body = io.NopCloser(bytes.NewReader(data))
+	return resp, nil
+}
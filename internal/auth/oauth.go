@@ -2,58 +2,149 @@ package auth
 
 import (
 	"context"
-	"crypto/sha256"
-	"encoding/base64"
+	"crypto/rand"
 	"fmt"
-	mathrand "math/rand"
 	"net/http"
 	"net/url"
+	"slices"
 	"strings"
 	"time"
 
 	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
 )
 
+// DefaultAccountsURL is the base URL of the Spotify accounts service
+const DefaultAccountsURL = "https://accounts.spotify.com"
+
+// Endpoint is the Spotify accounts service and the HTTP client used to reach
+// it. The zero value means the real service with a default client.
+type Endpoint struct {
+	AccountsURL string
+	HTTPClient  *http.Client
+}
+
+func (e Endpoint) baseURL() string {
+	if e.AccountsURL == "" {
+		return DefaultAccountsURL
+	}
+	return strings.TrimSuffix(e.AccountsURL, "/")
+}
+
+func (e Endpoint) authURL() string {
+	return e.baseURL() + "/authorize"
+}
+
+func (e Endpoint) tokenURL() string {
+	return e.baseURL() + "/api/token"
+}
+
+func (e Endpoint) httpClient() *http.Client {
+	if e.HTTPClient != nil {
+		return e.HTTPClient
+	}
+
+	return &http.Client{
+		Timeout: 30 * time.Second,
+	}
+}
+
+// Scopes used by spotifycli commands
 const (
-	authURL  = "https://accounts.spotify.com/authorize"
-	tokenURL = "https://accounts.spotify.com/api/token"
+	ScopeReadPlaybackState    = "user-read-playback-state"
+	ScopeModifyPlaybackState  = "user-modify-playback-state"
+	ScopeReadCurrentlyPlaying = "user-read-currently-playing"
+	ScopeLibraryRead          = "user-library-read"
+	ScopeLibraryModify        = "user-library-modify"
+	ScopePlaylistReadPrivate  = "playlist-read-private"
+	ScopeReadPrivate          = "user-read-private"
 )
 
-// Required scopes for the Spotify CLI
-var scopes = []string{
+// DefaultScopes are the scopes requested when none are configured. They cover
+// every spotifycli command and nothing more.
+var DefaultScopes = []string{
+	ScopeReadPlaybackState,
+	ScopeModifyPlaybackState,
+	ScopeReadCurrentlyPlaying,
+	ScopeLibraryRead,
+	ScopeLibraryModify,
+	ScopePlaylistReadPrivate,
+	ScopeReadPrivate,
+}
+
+// validScopes are all the scopes Spotify accepts
+var validScopes = []string{
+	"ugc-image-upload",
 	"user-read-playback-state",
 	"user-modify-playback-state",
 	"user-read-currently-playing",
-	"user-library-read",
-	"user-library-modify",
+	"app-remote-control",
+	"streaming",
 	"playlist-read-private",
-	"playlist-modify-public",
+	"playlist-read-collaborative",
 	"playlist-modify-private",
+	"playlist-modify-public",
+	"user-follow-modify",
+	"user-follow-read",
+	"user-read-playback-position",
+	"user-top-read",
+	"user-read-recently-played",
+	"user-library-modify",
+	"user-library-read",
 	"user-read-email",
 	"user-read-private",
-	"user-read-recently-played",
-	"user-top-read",
-	"user-follow-read",
-	"user-follow-modify",
-	"streaming",
+}
+
+// ValidateScopes checks that every scope is one Spotify knows about
+func ValidateScopes(scopes []string) error {
+	for _, scope := range scopes {
+		if !slices.Contains(validScopes, scope) {
+			return fmt.Errorf("unknown scope: %s", scope)
+		}
+	}
+	return nil
+}
+
+// MergeScopes returns the union of the given scope lists, in order of first appearance
+func MergeScopes(lists ...[]string) []string {
+	var merged []string
+	for _, list := range lists {
+		for _, scope := range list {
+			if !slices.Contains(merged, scope) {
+				merged = append(merged, scope)
+			}
+		}
+	}
+	return merged
+}
+
+// GrantedScope returns the space separated scopes the token was granted
+func GrantedScope(token *oauth2.Token) string {
+	scope, _ := token.Extra("scope").(string)
+	return scope
 }
 
 type PKCEAuth struct {
+	Endpoint
 	ClientID      string
 	RedirectURI   string
+	Scopes        []string
 	State         string
 	CodeVerifier  string
 	CodeChallenge string
 }
 
 func NewPKCEAuth(clientID, redirectURI string) *PKCEAuth {
-	state := generateRandomString(32)
-	codeVerifier := generateCodeVerifier()
-	codeChallenge := generateCodeChallenge(codeVerifier)
+	// The state ties the callback to this login and the verifier proves the
+	// code exchange comes from whoever started it, so both must be unguessable
+	state := rand.Text()
+	codeVerifier := oauth2.GenerateVerifier()
+	codeChallenge := oauth2.S256ChallengeFromVerifier(codeVerifier)
 
 	return &PKCEAuth{
 		ClientID:      clientID,
 		RedirectURI:   redirectURI,
+		Scopes:        DefaultScopes,
 		State:         state,
 		CodeVerifier:  codeVerifier,
 		CodeChallenge: codeChallenge,
@@ -65,34 +156,62 @@ func (a *PKCEAuth) GetAuthURL() string {
 	params := url.Values{}
 	params.Add("response_type", "code")
 	params.Add("client_id", a.ClientID)
-	params.Add("scope", strings.Join(scopes, " "))
+	params.Add("scope", strings.Join(a.Scopes, " "))
 	params.Add("redirect_uri", a.RedirectURI)
 	params.Add("state", a.State)
 	params.Add("code_challenge_method", "S256")
 	params.Add("code_challenge", a.CodeChallenge)
 
-	return fmt.Sprintf("%s?%s", authURL, params.Encode())
+	return fmt.Sprintf("%s?%s", a.authURL(), params.Encode())
 }
 
-// ExchangeCode exchanges the authorization code for tokens
-func (a *PKCEAuth) ExchangeCode(ctx context.Context, code string) (*oauth2.Token, error) {
-	config := &oauth2.Config{
-		ClientID: a.ClientID,
-		Endpoint: oauth2.Endpoint{
-			AuthURL:  authURL,
-			TokenURL: tokenURL,
-		},
-		RedirectURL: a.RedirectURI,
+// ParseRedirect extracts the authorization code from the URL the user was
+// redirected to, verifying the state parameter. A bare code is also accepted
+// for when only the code can be copied, in which case there is no state to check.
+func (a *PKCEAuth) ParseRedirect(input string) (string, error) {
+	input = strings.TrimSpace(input)
+	if input == "" {
+		return "", fmt.Errorf("no redirect URL or code provided")
 	}
 
-	// Create a custom HTTP client for PKCE
-	client := &http.Client{
-		Timeout: 30 * time.Second,
+	if !strings.Contains(input, "?") && !strings.Contains(input, "=") {
+		return input, nil
+	}
+
+	// Accept either a full URL or just its query string
+	query := input
+	if i := strings.Index(input, "?"); i >= 0 {
+		query = input[i+1:]
 	}
 
+	params, err := url.ParseQuery(query)
+	if err != nil {
+		return "", fmt.Errorf("invalid redirect URL: %w", err)
+	}
+
+	if oauthErr := params.Get("error"); oauthErr != "" {
+		return "", fmt.Errorf("OAuth error: %s", oauthErr)
+	}
+
+	if params.Get("state") != a.State {
+		return "", fmt.Errorf("invalid state parameter")
+	}
+
+	code := params.Get("code")
+	if code == "" {
+		return "", fmt.Errorf("no authorization code in redirect URL")
+	}
+
+	return code, nil
+}
+
+// ExchangeCode exchanges the authorization code for tokens
+func (a *PKCEAuth) ExchangeCode(ctx context.Context, code string) (*oauth2.Token, error) {
+	config := a.oauthConfig(a.ClientID, a.RedirectURI)
+
 	// Exchange code for token with PKCE
 	token, err := config.Exchange(
-		context.WithValue(ctx, oauth2.HTTPClient, client),
+		context.WithValue(ctx, oauth2.HTTPClient, a.httpClient()),
 		code,
 		oauth2.SetAuthURLParam("code_verifier", a.CodeVerifier),
 	)
@@ -103,22 +222,61 @@ func (a *PKCEAuth) ExchangeCode(ctx context.Context, code string) (*oauth2.Token
 	return token, nil
 }
 
-func generateRandomString(length int) string {
-	const charset = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789-._~"
-	b := make([]byte, length)
-	for i := range b {
-		b[i] = charset[mathrand.Intn(len(charset))]
+// RefreshAccessToken exchanges a refresh token for a new access token.
+// Spotify may or may not rotate the refresh token, so callers should keep
+// the old one when the returned token has none.
+func RefreshAccessToken(ctx context.Context, endpoint Endpoint, clientID, refreshToken string) (*oauth2.Token, error) {
+	config := endpoint.oauthConfig(clientID, "")
+
+	token, err := config.TokenSource(
+		context.WithValue(ctx, oauth2.HTTPClient, endpoint.httpClient()),
+		&oauth2.Token{RefreshToken: refreshToken},
+	).Token()
+	if err != nil {
+		return nil, fmt.Errorf("failed to refresh access token: %w", err)
 	}
-	return string(b)
+
+	return token, nil
 }
 
-// generateCodeVerifier generates a code verifier for PKCE
-func generateCodeVerifier() string {
-	return generateRandomString(128)
+// AppTokenSource returns tokens from the client credentials grant. They
+// carry no user, so they only reach catalog endpoints such as search, and
+// the source fetches a new one when the current token expires.
+func AppTokenSource(ctx context.Context, endpoint Endpoint, clientID, clientSecret string) oauth2.TokenSource {
+	config := &clientcredentials.Config{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		TokenURL:     endpoint.tokenURL(),
+		AuthStyle:    oauth2.AuthStyleInHeader,
+	}
+
+	return config.TokenSource(context.WithValue(ctx, oauth2.HTTPClient, endpoint.httpClient()))
 }
 
-// generateCodeChallenge generates a code challenge from the verifier
-func generateCodeChallenge(verifier string) string {
-	hash := sha256.Sum256([]byte(verifier))
-	return base64.RawURLEncoding.EncodeToString(hash[:])
+// ExpiresIn returns the token lifetime in seconds as reported by the token endpoint
+func ExpiresIn(token *oauth2.Token) int64 {
+	if token.ExpiresIn > 0 {
+		return token.ExpiresIn
+	}
+
+	if !token.Expiry.IsZero() {
+		return int64(time.Until(token.Expiry).Seconds())
+	}
+
+	// Spotify access tokens are valid for an hour
+	return 3600
+}
+
+// oauthConfig builds the oauth2 config for a public (PKCE) client.
+// Public clients have no secret so the client ID must go in the request body.
+func (e Endpoint) oauthConfig(clientID, redirectURI string) *oauth2.Config {
+	return &oauth2.Config{
+		ClientID: clientID,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:   e.authURL(),
+			TokenURL:  e.tokenURL(),
+			AuthStyle: oauth2.AuthStyleInParams,
+		},
+		RedirectURL: redirectURI,
+	}
 }
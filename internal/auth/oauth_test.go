@@ -0,0 +1,63 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"net/url"
+	"regexp"
+	"testing"
+)
+
+func TestNewPKCEAuth(t *testing.T) {
+	// RFC 7636 allows 43 to 128 unreserved characters
+	verifierPattern := regexp.MustCompile(`^[A-Za-z0-9._~-]{43,128}$`)
+
+	seen := make(map[string]bool)
+	for range 50 {
+		a := NewPKCEAuth("client", "http://127.0.0.1:8888/callback")
+
+		if len(a.State) < 16 || seen[a.State] {
+			t.Fatalf("state %q is short or repeated", a.State)
+		}
+		if !verifierPattern.MatchString(a.CodeVerifier) || seen[a.CodeVerifier] {
+			t.Fatalf("code verifier %q is malformed or repeated", a.CodeVerifier)
+		}
+		seen[a.State] = true
+		seen[a.CodeVerifier] = true
+
+		hash := sha256.Sum256([]byte(a.CodeVerifier))
+		if want := base64.RawURLEncoding.EncodeToString(hash[:]); a.CodeChallenge != want {
+			t.Fatalf("code challenge %q, want %q", a.CodeChallenge, want)
+		}
+	}
+}
+
+func TestGetAuthURL(t *testing.T) {
+	a := NewPKCEAuth("client", "http://127.0.0.1:8888/callback")
+	a.Endpoint = Endpoint{AccountsURL: "http://accounts.test"}
+
+	u, err := url.Parse(a.GetAuthURL())
+	if err != nil {
+		t.Fatalf("invalid authorization URL: %v", err)
+	}
+	if u.Host != "accounts.test" || u.Path != "/authorize" {
+		t.Errorf("authorization URL %s, want the accounts service's /authorize", u)
+	}
+
+	q := u.Query()
+	for key, want := range map[string]string{
+		"response_type":         "code",
+		"client_id":             "client",
+		"redirect_uri":          "http://127.0.0.1:8888/callback",
+		"state":                 a.State,
+		"code_challenge_method": "S256",
+		"code_challenge":        a.CodeChallenge,
+	} {
+		if got := q.Get(key); got != want {
+			t.Errorf("%s is %q, want %q", key, got, want)
+		}
+	}
+	if q.Has("code_verifier") {
+		t.Errorf("the code verifier was sent with the authorization request")
+	}
+}
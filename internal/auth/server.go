@@ -3,46 +3,108 @@ package auth
 import (
 	"context"
 	"fmt"
+	"html/template"
+	"net"
 	"net/http"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 )
 
+// callbackResult is the outcome of the OAuth redirect
+type callbackResult struct {
+	code string
+	err  error
+}
+
 type CallbackServer struct {
-	server    *http.Server
-	addr      string
-	codeChan  chan string
-	stateChan chan string
-	errChan   chan error
+	server   *http.Server
+	listener net.Listener
+	ports    string
+	path     string
+	state    string
+	result   chan callbackResult
+	once     sync.Once
 }
 
-func NewCallbackServer(port string) *CallbackServer {
+// NewCallbackServer creates a callback server for the given port or port range
+// (e.g. "8080" or "8080-8089") that serves the redirect at path and only
+// accepts callbacks carrying the expected state
+func NewCallbackServer(ports, path, state string) *CallbackServer {
 	return &CallbackServer{
-		// I'm binding explicitly to 127.0.0.1 since spotify does not accept http://localhost URIs
-		addr:      fmt.Sprintf("127.0.0.1:%s", port),
-		codeChan:  make(chan string, 1),
-		stateChan: make(chan string, 1),
-		errChan:   make(chan error, 1),
+		ports:  ports,
+		path:   "/" + strings.TrimPrefix(path, "/"),
+		state:  state,
+		result: make(chan callbackResult, 1),
+	}
+}
+
+// ParsePortRange parses a port ("8080") or an inclusive port range ("8080-8089")
+func ParsePortRange(ports string) (int, int, error) {
+	first, last, isRange := strings.Cut(ports, "-")
+
+	from, err := strconv.Atoi(strings.TrimSpace(first))
+	if err != nil || from < 1 || from > 65535 {
+		return 0, 0, fmt.Errorf("invalid port: %s", first)
+	}
+
+	if !isRange {
+		return from, from, nil
+	}
+
+	to, err := strconv.Atoi(strings.TrimSpace(last))
+	if err != nil || to < from || to > 65535 {
+		return 0, 0, fmt.Errorf("invalid port range: %s", ports)
 	}
+
+	return from, to, nil
 }
 
+// Start binds the first free port in the range and starts serving
 func (s *CallbackServer) Start() error {
+	// Served at the root, the callback would catch every request the browser makes
+	if s.path == "/" {
+		return fmt.Errorf("redirect path cannot be empty")
+	}
+
+	from, to, err := ParsePortRange(s.ports)
+	if err != nil {
+		return err
+	}
+
+	for port := from; port <= to; port++ {
+		// I'm binding explicitly to 127.0.0.1 since spotify does not accept http://localhost URIs
+		s.listener, err = net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+		if err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("no free port in %s: %w", s.ports, err)
+	}
+
 	mux := http.NewServeMux()
-	mux.HandleFunc("/callback", s.handleCallback)
+	mux.HandleFunc(s.path, s.handleCallback)
 
 	s.server = &http.Server{
-		Addr:    s.addr,
 		Handler: mux,
 	}
 
 	go func() {
-		if err := s.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			s.errChan <- err
+		if err := s.server.Serve(s.listener); err != nil && err != http.ErrServerClosed {
+			s.finish("", err)
 		}
 	}()
 
 	return nil
 }
 
+// RedirectURI returns the redirect URI for the port the server is bound to
+func (s *CallbackServer) RedirectURI() string {
+	return fmt.Sprintf("http://%s%s", s.listener.Addr().String(), s.path)
+}
+
 // Stop stops the callback server
 func (s *CallbackServer) Stop() error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -50,60 +112,129 @@ func (s *CallbackServer) Stop() error {
 	return s.server.Shutdown(ctx)
 }
 
-// WaitForCallback waits for the OAuth callback and returns the code and state
-func (s *CallbackServer) WaitForCallback(timeout time.Duration) (string, string, error) {
+// WaitForCallback waits for the OAuth callback and returns the authorization
+// code. It gives up after timeout, or when ctx is done.
+func (s *CallbackServer) WaitForCallback(ctx context.Context, timeout time.Duration) (string, error) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
 	select {
-	case code := <-s.codeChan:
-		state := <-s.stateChan
-		return code, state, nil
-	case err := <-s.errChan:
-		return "", "", err
-	case <-time.After(timeout):
-		return "", "", fmt.Errorf("callback timeout after %v", timeout)
+	case result := <-s.result:
+		return result.code, result.err
+	case <-timer.C:
+		return "", fmt.Errorf("callback timeout after %v", timeout)
+	case <-ctx.Done():
+		return "", ctx.Err()
 	}
 }
 
-// handleCallback handles the OAuth callback
+// finish records the outcome of the first callback; later ones are ignored
+func (s *CallbackServer) finish(code string, err error) {
+	s.once.Do(func() {
+		s.result <- callbackResult{code: code, err: err}
+	})
+}
+
+// handleCallback handles the OAuth callback. Requests for any other path,
+// such as the browser asking for a favicon, never reach it. Requests without
+// the expected state didn't come from this login, so they are turned away
+// without ending it.
 func (s *CallbackServer) handleCallback(w http.ResponseWriter, r *http.Request) {
-	code := r.URL.Query().Get("code")
-	state := r.URL.Query().Get("state")
-	error := r.URL.Query().Get("error")
+	query := r.URL.Query()
 
-	if error != "" {
-		http.Error(w, fmt.Sprintf("OAuth error: %s", error), http.StatusBadRequest)
-		s.errChan <- fmt.Errorf("OAuth error: %s", error)
+	if query.Get("state") != s.state {
+		renderCallbackPage(w, http.StatusBadRequest, callbackPage{
+			Title:   "Authorization Failed",
+			Message: "This request does not belong to the login waiting in the terminal, which keeps waiting.",
+		})
 		return
 	}
 
-	if code == "" {
-		http.Error(w, "No authorization code received", http.StatusBadRequest)
-		s.errChan <- fmt.Errorf("no authorization code received")
+	if oauthErr := query.Get("error"); oauthErr != "" {
+		s.fail(w, fmt.Errorf("OAuth error: %s", oauthErr))
 		return
 	}
 
-	// Send success response
-	w.Header().Set("Content-Type", "text/html")
-	w.WriteHeader(http.StatusOK)
-
-	// TODO: add some styling
-	fmt.Fprintf(w, `
-		<html>
-		<head><title>Spotify CLI Authorization</title></head>
-		<body>
-			<h1>Authorization Successful!</h1>
-			<p>You can now close this window and return to the terminal.</p>
-		</body>
-		</html>
-	`)
-
-	// Send the code and state to the channel
-	select {
-	case s.codeChan <- code:
-	case <-time.After(1 * time.Second):
+	code := query.Get("code")
+	if code == "" {
+		s.fail(w, fmt.Errorf("no authorization code received"))
+		return
 	}
 
-	select {
-	case s.stateChan <- state:
-	case <-time.After(1 * time.Second):
-	}
+	renderCallbackPage(w, http.StatusOK, callbackPage{
+		Success: true,
+		Title:   "Authorization Successful!",
+		Message: "You can now close this window and return to the terminal.",
+	})
+
+	s.finish(code, nil)
+}
+
+func (s *CallbackServer) fail(w http.ResponseWriter, err error) {
+	renderCallbackPage(w, http.StatusBadRequest, callbackPage{
+		Title:   "Authorization Failed",
+		Message: fmt.Sprintf("%s. Return to the terminal and run 'spotifycli login' again.", err),
+	})
+
+	s.finish("", err)
+}
+
+type callbackPage struct {
+	Success bool
+	Title   string
+	Message string
+}
+
+var callbackTemplate = template.Must(template.New("callback").Parse(`<!DOCTYPE html>
+<html>
+<head>
+	<meta charset="utf-8">
+	<title>Spotify CLI Authorization</title>
+	<style>
+		body {
+			margin: 0;
+			min-height: 100vh;
+			display: flex;
+			align-items: center;
+			justify-content: center;
+			background: #121212;
+			color: #ffffff;
+			font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Helvetica, Arial, sans-serif;
+		}
+		.card {
+			max-width: 420px;
+			padding: 40px;
+			border-radius: 12px;
+			background: #181818;
+			text-align: center;
+		}
+		.icon {
+			width: 64px;
+			height: 64px;
+			margin: 0 auto 24px;
+			border-radius: 50%;
+			line-height: 64px;
+			font-size: 32px;
+			color: #121212;
+		}
+		.success { background: #1db954; }
+		.failure { background: #e22134; }
+		h1 { margin: 0 0 12px; font-size: 24px; }
+		p { margin: 0; color: #b3b3b3; line-height: 1.5; }
+	</style>
+</head>
+<body>
+	<div class="card">
+		{{if .Success}}<div class="icon success">&#10003;</div>{{else}}<div class="icon failure">&#10007;</div>{{end}}
+		<h1>{{.Title}}</h1>
+		<p>{{.Message}}</p>
+	</div>
+</body>
+</html>
+`))
+
+func renderCallbackPage(w http.ResponseWriter, status int, page callbackPage) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	callbackTemplate.Execute(w, page)
 }
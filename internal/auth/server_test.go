@@ -0,0 +1,187 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// listen takes a free port on 127.0.0.1, released when the test ends
+func listen(t *testing.T) (net.Listener, int) {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() { l.Close() })
+	return l, l.Addr().(*net.TCPAddr).Port
+}
+
+// startCallbackServer starts a callback server on a free port expecting the state "expected"
+func startCallbackServer(t *testing.T) *CallbackServer {
+	t.Helper()
+
+	l, port := listen(t)
+	l.Close()
+
+	s := NewCallbackServer(fmt.Sprint(port), "callback", "expected")
+	if err := s.Start(); err != nil {
+		t.Fatalf("failed to start callback server: %v", err)
+	}
+	t.Cleanup(func() { s.Stop() })
+	return s
+}
+
+// visit requests the URL as the browser would and returns the status
+func visit(t *testing.T, url string) int {
+	t.Helper()
+
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("failed to visit %s: %v", url, err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+// assertWaiting checks the server is still waiting for the callback
+func assertWaiting(t *testing.T, s *CallbackServer) {
+	t.Helper()
+
+	if code, err := s.WaitForCallback(context.Background(), 100*time.Millisecond); err == nil || !strings.Contains(err.Error(), "timeout") {
+		t.Fatalf("login ended with code %q and error %v, want it still waiting", code, err)
+	}
+}
+
+func TestCallbackServer(t *testing.T) {
+	s := startCallbackServer(t)
+
+	if status := visit(t, s.RedirectURI()+"?code=the-code&state=expected"); status != http.StatusOK {
+		t.Errorf("callback answered %d, want 200", status)
+	}
+
+	code, err := s.WaitForCallback(context.Background(), time.Second)
+	if err != nil || code != "the-code" {
+		t.Errorf("WaitForCallback = %q, %v, want the code", code, err)
+	}
+}
+
+func TestCallbackStateMismatch(t *testing.T) {
+	s := startCallbackServer(t)
+
+	for _, query := range []string{"?code=forged&state=forged", "?code=forged", "?error=access_denied&state=forged"} {
+		if status := visit(t, s.RedirectURI()+query); status != http.StatusBadRequest {
+			t.Errorf("callback %s answered %d, want 400", query, status)
+		}
+	}
+	assertWaiting(t, s)
+
+	// The real callback still completes the login
+	visit(t, s.RedirectURI()+"?code=the-code&state=expected")
+	if code, err := s.WaitForCallback(context.Background(), time.Second); err != nil || code != "the-code" {
+		t.Errorf("WaitForCallback = %q, %v, want the code", code, err)
+	}
+}
+
+func TestCallbackWrongPath(t *testing.T) {
+	s := startCallbackServer(t)
+	root := strings.TrimSuffix(s.RedirectURI(), "/callback")
+
+	for _, path := range []string{"/favicon.ico", "/", "/callback/extra", "/other?code=the-code&state=expected"} {
+		if status := visit(t, root+path); status != http.StatusNotFound {
+			t.Errorf("%s answered %d, want 404", path, status)
+		}
+	}
+	assertWaiting(t, s)
+}
+
+func TestCallbackDenied(t *testing.T) {
+	s := startCallbackServer(t)
+
+	if status := visit(t, s.RedirectURI()+"?error=access_denied&state=expected"); status != http.StatusBadRequest {
+		t.Errorf("callback answered %d, want 400", status)
+	}
+
+	if _, err := s.WaitForCallback(context.Background(), time.Second); err == nil || !strings.Contains(err.Error(), "access_denied") {
+		t.Errorf("WaitForCallback error %v, want the OAuth error", err)
+	}
+
+	// Only the first callback counts
+	visit(t, s.RedirectURI()+"?code=the-code&state=expected")
+	assertWaiting(t, s)
+}
+
+func TestCallbackPortInUse(t *testing.T) {
+	_, port := listen(t)
+
+	s := NewCallbackServer(fmt.Sprint(port), "callback", "expected")
+	if err := s.Start(); err == nil || !strings.Contains(err.Error(), "no free port") {
+		s.Stop()
+		t.Fatalf("Start on a port in use: error %v, want it refused", err)
+	}
+}
+
+func TestCallbackPortRange(t *testing.T) {
+	// Find two free ports in a row and take the first
+	var port int
+	for range 20 {
+		l, p := listen(t)
+		next, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", p+1))
+		if err == nil {
+			next.Close()
+			port = p
+			break
+		}
+		l.Close()
+	}
+	if port == 0 {
+		t.Skip("no two free ports in a row")
+	}
+
+	s := NewCallbackServer(fmt.Sprintf("%d-%d", port, port+1), "callback", "expected")
+	if err := s.Start(); err != nil {
+		t.Fatalf("failed to start callback server: %v", err)
+	}
+	defer s.Stop()
+
+	if want := fmt.Sprintf("http://127.0.0.1:%d/callback", port+1); s.RedirectURI() != want {
+		t.Errorf("redirect URI %s, want the next port's %s", s.RedirectURI(), want)
+	}
+}
+
+func TestCallbackRootPath(t *testing.T) {
+	s := NewCallbackServer("8888", "/", "expected")
+	if err := s.Start(); err == nil {
+		s.Stop()
+		t.Errorf("started with the callback at the root")
+	}
+}
+
+func TestParsePortRange(t *testing.T) {
+	tests := []struct {
+		ports    string
+		from, to int
+		wantErr  bool
+	}{
+		{"8888", 8888, 8888, false},
+		{"8888-8890", 8888, 8890, false},
+		{" 8888 - 8890 ", 8888, 8890, false},
+		{"8890-8888", 0, 0, true},
+		{"0", 0, 0, true},
+		{"65536", 0, 0, true},
+		{"8888-", 0, 0, true},
+		{"port", 0, 0, true},
+	}
+
+	for _, tt := range tests {
+		from, to, err := ParsePortRange(tt.ports)
+		if (err != nil) != tt.wantErr || from != tt.from || to != tt.to {
+			t.Errorf("ParsePortRange(%q) = %d, %d, %v, want %d, %d (error %t)", tt.ports, from, to, err, tt.from, tt.to, tt.wantErr)
+		}
+	}
+}
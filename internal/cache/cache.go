@@ -0,0 +1,255 @@
+// Package cache keeps Spotify Web API responses for catalog lookups and
+// searches on disk, so repeated lookups don't go to the network.
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultMaxSize caps the cache when no size is configured
+const DefaultMaxSize = 50 << 20
+
+// Cache is an on-disk HTTP response cache with a size cap, evicting the
+// least recently used entries first
+type Cache struct {
+	dir     string
+	maxSize int64
+	debug   io.Writer
+}
+
+// entry is a cached response
+type entry struct {
+	Owner   string      `json:"owner"`
+	URL     string      `json:"url"`
+	Header  http.Header `json:"header"`
+	Body    []byte      `json:"body"`
+	ETag    string      `json:"etag,omitempty"`
+	Expires time.Time   `json:"expires"`
+}
+
+// Dir returns the default cache directory, $XDG_CACHE_HOME/spotifycli
+func Dir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "spotifycli"), nil
+}
+
+// New creates a cache in dir holding at most maxSize bytes, DefaultMaxSize
+// when zero
+func New(dir string, maxSize int64) *Cache {
+	if maxSize == 0 {
+		maxSize = DefaultMaxSize
+	}
+	return &Cache{dir: dir, maxSize: maxSize}
+}
+
+// SetDebug logs cache hits, misses and revalidations to w
+func (c *Cache) SetDebug(w io.Writer) {
+	c.debug = w
+}
+
+func (c *Cache) logf(format string, args ...any) {
+	if c.debug != nil {
+		fmt.Fprintf(c.debug, "[cache] "+format+"\n", args...)
+	}
+}
+
+func (c *Cache) path(owner, url string) string {
+	sum := sha256.Sum256([]byte(owner + " " + url))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:])+".json")
+}
+
+// load returns the owner's entry for url, marking it as recently used
+func (c *Cache) load(owner, url string) (*entry, bool) {
+	path := c.path(owner, url)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+
+	var e entry
+	if err := json.Unmarshal(data, &e); err != nil || e.Owner != owner || e.URL != url {
+		return nil, false
+	}
+
+	// The modification time orders entries for eviction
+	now := time.Now()
+	os.Chtimes(path, now, now)
+
+	return &e, true
+}
+
+// store writes the entry, then evicts entries until the cache fits its cap
+func (c *Cache) store(e *entry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	if int64(len(data)) > c.maxSize {
+		return nil
+	}
+
+	if err := os.MkdirAll(c.dir, 0700); err != nil {
+		return err
+	}
+
+	// Write to a temporary file and rename it so other processes never read a partial entry
+	tmp, err := os.CreateTemp(c.dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp.Name(), c.path(e.Owner, e.URL)); err != nil {
+		return err
+	}
+
+	return c.evict()
+}
+
+// file is a cache entry on disk
+type file struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+func (c *Cache) files() ([]file, error) {
+	dirEntries, err := os.ReadDir(c.dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var files []file
+	for _, d := range dirEntries {
+		if d.IsDir() || !strings.HasSuffix(d.Name(), ".json") {
+			continue
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			continue
+		}
+
+		files = append(files, file{
+			path:    filepath.Join(c.dir, d.Name()),
+			size:    info.Size(),
+			modTime: info.ModTime(),
+		})
+	}
+
+	return files, nil
+}
+
+// evict removes the least recently used entries until the cache fits its cap
+func (c *Cache) evict() error {
+	files, err := c.files()
+	if err != nil {
+		return err
+	}
+
+	var total int64
+	for _, f := range files {
+		total += f.size
+	}
+
+	if total <= c.maxSize {
+		return nil
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].modTime.Before(files[j].modTime)
+	})
+
+	for _, f := range files {
+		if total <= c.maxSize {
+			break
+		}
+		if err := os.Remove(f.path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		total -= f.size
+		c.logf("evicted %s", filepath.Base(f.path))
+	}
+
+	return nil
+}
+
+// Clear removes every entry, returning how many there were
+func (c *Cache) Clear() (int, error) {
+	files, err := c.files()
+	if err != nil {
+		return 0, err
+	}
+
+	for _, f := range files {
+		if err := os.Remove(f.path); err != nil && !os.IsNotExist(err) {
+			return 0, fmt.Errorf("failed to remove cache entry: %w", err)
+		}
+	}
+
+	return len(files), nil
+}
+
+// Stats describes what the cache holds
+type Stats struct {
+	Dir     string
+	Entries int
+	// Fresh entries are served without asking Spotify, the rest are revalidated first
+	Fresh   int
+	Size    int64
+	MaxSize int64
+}
+
+// Stats reports the number and size of the cached entries
+func (c *Cache) Stats() (Stats, error) {
+	stats := Stats{Dir: c.dir, MaxSize: c.maxSize}
+
+	files, err := c.files()
+	if err != nil {
+		return stats, err
+	}
+
+	now := time.Now()
+	for _, f := range files {
+		stats.Entries++
+		stats.Size += f.size
+
+		data, err := os.ReadFile(f.path)
+		if err != nil {
+			continue
+		}
+
+		var e entry
+		if json.Unmarshal(data, &e) == nil && now.Before(e.Expires) {
+			stats.Fresh++
+		}
+	}
+
+	return stats, nil
+}
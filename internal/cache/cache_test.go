@@ -0,0 +1,97 @@
+package cache
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// storeEntry caches a response for url of roughly size bytes, last used at
+// the given time
+func storeEntry(t *testing.T, c *Cache, url string, size int, used time.Time) {
+	t.Helper()
+
+	e := &entry{Owner: "user", URL: url, Body: []byte(strings.Repeat("x", size)), Expires: time.Now().Add(time.Hour)}
+	if err := c.store(e); err != nil {
+		t.Fatalf("failed to store %s: %v", url, err)
+	}
+	if err := os.Chtimes(c.path("user", url), used, used); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// entrySize returns the size on disk of an entry with a body of size bytes
+func entrySize(t *testing.T, size int) int64 {
+	t.Helper()
+
+	data, err := json.Marshal(&entry{Owner: "user", URL: "/v1/tracks/a", Body: []byte(strings.Repeat("x", size)), Expires: time.Now()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return int64(len(data))
+}
+
+func TestEvictsLeastRecentlyUsed(t *testing.T) {
+	// Room for three entries, not four
+	c := New(t.TempDir(), 3*entrySize(t, 1000)+100)
+
+	now := time.Now()
+	storeEntry(t, c, "/v1/tracks/a", 1000, now.Add(-4*time.Minute))
+	storeEntry(t, c, "/v1/tracks/b", 1000, now.Add(-3*time.Minute))
+	storeEntry(t, c, "/v1/tracks/c", 1000, now.Add(-2*time.Minute))
+
+	// Using a makes b the least recently used
+	if _, ok := c.load("user", "/v1/tracks/a"); !ok {
+		t.Fatalf("a was not cached")
+	}
+
+	storeEntry(t, c, "/v1/tracks/d", 1000, now)
+
+	for url, want := range map[string]bool{"/v1/tracks/a": true, "/v1/tracks/b": false, "/v1/tracks/c": true, "/v1/tracks/d": true} {
+		if _, ok := c.load("user", url); ok != want {
+			t.Errorf("%s cached %t, want %t", url, ok, want)
+		}
+	}
+
+	stats, err := c.Stats()
+	if err != nil {
+		t.Fatalf("failed to get stats: %v", err)
+	}
+	if stats.Entries != 3 || stats.Size > stats.MaxSize {
+		t.Errorf("cache holds %d entries of %d bytes, want 3 within %d", stats.Entries, stats.Size, stats.MaxSize)
+	}
+}
+
+func TestEntryLargerThanCacheNotStored(t *testing.T) {
+	c := New(t.TempDir(), entrySize(t, 1000))
+
+	storeEntry(t, c, "/v1/tracks/a", 1000, time.Now())
+	if err := c.store(&entry{Owner: "user", URL: "/v1/tracks/big", Body: make([]byte, 5000)}); err != nil {
+		t.Fatalf("failed to store: %v", err)
+	}
+
+	// The existing entry isn't evicted to make room for one that can't fit
+	if _, ok := c.load("user", "/v1/tracks/a"); !ok {
+		t.Errorf("a was evicted")
+	}
+	if _, ok := c.load("user", "/v1/tracks/big"); ok {
+		t.Errorf("an entry over the size cap was stored")
+	}
+}
+
+func TestClear(t *testing.T) {
+	c := New(t.TempDir(), 0)
+
+	storeEntry(t, c, "/v1/tracks/a", 10, time.Now())
+	storeEntry(t, c, "/v1/tracks/b", 10, time.Now())
+
+	n, err := c.Clear()
+	if err != nil || n != 2 {
+		t.Fatalf("Clear = %d, %v, want 2 entries removed", n, err)
+	}
+	if stats, _ := c.Stats(); stats.Entries != 0 {
+		t.Errorf("%d entries left after Clear", stats.Entries)
+	}
+}
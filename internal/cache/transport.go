@@ -0,0 +1,128 @@
+package cache
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// How long responses are served from the cache before being revalidated
+const (
+	searchTTL  = time.Hour
+	catalogTTL = 24 * time.Hour
+)
+
+// ttl returns how long a response for the API path may be cached, or zero
+// when it must not be. Only catalog lookups and searches are cached; anything
+// belonging to the user, such as the player state, library and playlists,
+// never is.
+func ttl(path string) time.Duration {
+	if i := strings.Index(path, "/v1/"); i >= 0 {
+		path = path[i+len("/v1/"):]
+	}
+
+	resource, _, _ := strings.Cut(strings.Trim(path, "/"), "/")
+	switch resource {
+	case "search":
+		return searchTTL
+	case "tracks", "albums", "artists", "shows", "episodes", "audiobooks", "chapters":
+		return catalogTTL
+	}
+
+	return 0
+}
+
+// Transport serves cacheable GET requests from the cache, revalidating stale
+// entries with If-None-Match, and caches successful responses. Owner is whose
+// responses they are, such as the user ID, since search results and
+// availability depend on the user's market; entries are never served to
+// another owner.
+func (c *Cache) Transport(base http.RoundTripper, owner string) http.RoundTripper {
+	return &transport{cache: c, base: base, owner: owner}
+}
+
+type transport struct {
+	cache *Cache
+	base  http.RoundTripper
+	owner string
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	maxAge := ttl(req.URL.Path)
+	if req.Method != http.MethodGet || maxAge == 0 {
+		return t.base.RoundTrip(req)
+	}
+
+	url := req.URL.String()
+	cached, ok := t.cache.load(t.owner, url)
+	if ok && time.Now().Before(cached.Expires) {
+		t.cache.logf("hit %s", req.URL.Path)
+		return cached.response(req), nil
+	}
+
+	if ok && cached.ETag != "" {
+		// RoundTrippers must not modify the caller's request
+		req = req.Clone(req.Context())
+		req.Header.Set("If-None-Match", cached.ETag)
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusNotModified && ok {
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+
+		t.cache.logf("revalidated %s", req.URL.Path)
+		cached.Expires = time.Now().Add(maxAge)
+		if err := t.cache.store(cached); err != nil {
+			t.cache.logf("failed to store %s: %v", req.URL.Path, err)
+		}
+		return cached.response(req), nil
+	}
+
+	if resp.StatusCode != http.StatusOK || strings.Contains(resp.Header.Get("Cache-Control"), "no-store") {
+		return resp, nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	t.cache.logf("miss %s", req.URL.Path)
+	e := &entry{
+		Owner:   t.owner,
+		URL:     url,
+		Header:  resp.Header.Clone(),
+		Body:    body,
+		ETag:    resp.Header.Get("ETag"),
+		Expires: time.Now().Add(maxAge),
+	}
+	if err := t.cache.store(e); err != nil {
+		t.cache.logf("failed to store %s: %v", req.URL.Path, err)
+	}
+
+	return resp, nil
+}
+
+// response rebuilds the cached response for req
+func (e *entry) response(req *http.Request) *http.Response {
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        e.Header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(e.Body)),
+		ContentLength: int64(len(e.Body)),
+		Request:       req,
+	}
+}
@@ -0,0 +1,206 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+// origin is a Web API stand-in answering with the current version of each
+// resource and its ETag, honouring If-None-Match
+type origin struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	version  int
+	requests []string
+}
+
+func newOrigin(t *testing.T) *origin {
+	o := &origin{version: 1}
+	o.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		o.mu.Lock()
+		defer o.mu.Unlock()
+
+		etag := fmt.Sprintf(`"v%d"`, o.version)
+		o.requests = append(o.requests, r.URL.Path+" "+r.Header.Get("If-None-Match"))
+
+		w.Header().Set("ETag", etag)
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		fmt.Fprintf(w, `{"path": %q, "version": %d}`, r.URL.Path, o.version)
+	}))
+	t.Cleanup(o.Close)
+	return o
+}
+
+// seen returns the requests that reached the origin, as "PATH IF-NONE-MATCH"
+func (o *origin) seen() []string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return append([]string(nil), o.requests...)
+}
+
+func (o *origin) update() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.version++
+}
+
+// get fetches path through the cache for owner and returns the body
+func get(t *testing.T, c *Cache, o *origin, owner, path string) string {
+	t.Helper()
+
+	client := &http.Client{Transport: c.Transport(http.DefaultTransport, owner)}
+	resp, err := client.Get(o.URL + path)
+	if err != nil {
+		t.Fatalf("GET %s: %v", path, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("GET %s: %v", path, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET %s: status %d, want 200", path, resp.StatusCode)
+	}
+	return string(body)
+}
+
+// expire makes the owner's entry for url stale, as if its TTL had passed
+func expire(t *testing.T, c *Cache, owner, url string) {
+	t.Helper()
+
+	e, ok := c.load(owner, url)
+	if !ok {
+		t.Fatalf("%s is not cached", url)
+	}
+	e.Expires = time.Now().Add(-time.Second)
+
+	data, err := json.Marshal(e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(c.path(owner, url), data, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func assertRequests(t *testing.T, o *origin, want ...string) {
+	t.Helper()
+
+	got := o.seen()
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("origin saw %q, want %q", got, want)
+	}
+}
+
+func TestTTL(t *testing.T) {
+	tests := []struct {
+		path string
+		want time.Duration
+	}{
+		{"/v1/tracks/dreams", catalogTTL},
+		{"/v1/albums/rumours/tracks", catalogTTL},
+		{"/v1/artists/fleetwoodmac", catalogTTL},
+		{"/v1/shows/thedaily", catalogTTL},
+		{"/v1/search", searchTTL},
+		{"/v1/me/player", 0},
+		{"/v1/me/tracks", 0},
+		{"/v1/playlists/mix", 0},
+		{"/v1/users/someone/playlists", 0},
+	}
+
+	for _, tt := range tests {
+		if got := ttl(tt.path); got != tt.want {
+			t.Errorf("ttl(%s) = %s, want %s", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestTransportServesFreshEntries(t *testing.T) {
+	o := newOrigin(t)
+	c := New(t.TempDir(), 0)
+
+	first := get(t, c, o, "user", "/v1/tracks/dreams")
+	o.update()
+
+	// Until the entry expires, the origin isn't asked again even if it changed
+	if again := get(t, c, o, "user", "/v1/tracks/dreams"); again != first {
+		t.Errorf("fresh entry served %s, want the cached %s", again, first)
+	}
+	assertRequests(t, o, `/v1/tracks/dreams `)
+
+	// Requests that mustn't be cached always go through
+	get(t, c, o, "user", "/v1/me/player")
+	get(t, c, o, "user", "/v1/me/player")
+	assertRequests(t, o, `/v1/tracks/dreams `, `/v1/me/player `, `/v1/me/player `)
+}
+
+func TestTransportRevalidatesExpiredEntries(t *testing.T) {
+	o := newOrigin(t)
+	c := New(t.TempDir(), 0)
+	url := o.URL + "/v1/albums/rumours"
+
+	first := get(t, c, o, "user", "/v1/albums/rumours")
+
+	// An expired entry is revalidated with its ETag, and a 304 serves it again
+	expire(t, c, "user", url)
+	if again := get(t, c, o, "user", "/v1/albums/rumours"); again != first {
+		t.Errorf("revalidated entry served %s, want %s", again, first)
+	}
+	assertRequests(t, o, `/v1/albums/rumours `, `/v1/albums/rumours "v1"`)
+
+	// and is fresh again afterwards
+	if e, ok := c.load("user", url); !ok || !time.Now().Before(e.Expires) {
+		t.Errorf("entry not fresh after a 304")
+	}
+	get(t, c, o, "user", "/v1/albums/rumours")
+	if n := len(o.seen()); n != 2 {
+		t.Errorf("origin asked %d times, want 2", n)
+	}
+
+	// A changed resource replaces the entry
+	o.update()
+	expire(t, c, "user", url)
+	if body := get(t, c, o, "user", "/v1/albums/rumours"); body != `{"path": "/v1/albums/rumours", "version": 2}` {
+		t.Errorf("changed resource served %s, want version 2", body)
+	}
+	if e, _ := c.load("user", url); e.ETag != `"v2"` {
+		t.Errorf("entry has ETag %s, want the new \"v2\"", e.ETag)
+	}
+}
+
+func TestTransportOwnerIsolation(t *testing.T) {
+	o := newOrigin(t)
+	c := New(t.TempDir(), 0)
+
+	alice := get(t, c, o, "alice", "/v1/search")
+	o.update()
+
+	// Another owner's results may differ, so they're never shared
+	bob := get(t, c, o, "bob", "/v1/search")
+	if bob == alice {
+		t.Errorf("bob was served alice's cached results %s", alice)
+	}
+	assertRequests(t, o, `/v1/search `, `/v1/search `)
+
+	if again := get(t, c, o, "alice", "/v1/search"); again != alice {
+		t.Errorf("alice served %s, want their own %s", again, alice)
+	}
+	if again := get(t, c, o, "bob", "/v1/search"); again != bob {
+		t.Errorf("bob served %s, want their own %s", again, bob)
+	}
+	if n := len(o.seen()); n != 2 {
+		t.Errorf("origin asked %d times, want 2", n)
+	}
+}
@@ -3,34 +3,149 @@ package config
 import (
 	"encoding/json"
 	"fmt"
-	"io"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 )
 
-const configFileName = "spotifycli.json"
+const (
+	configFileName = "spotifycli.json"
 
+	// DefaultProfile is the profile used when none is selected, and the one
+	// an old single-account config file is migrated into
+	DefaultProfile = "default"
+
+	// DefaultTimeout bounds each attempt of a request
+	DefaultTimeout = 30 * time.Second
+	// DefaultMaxRetries is how often a rate limited or failed request is retried
+	DefaultMaxRetries = 4
+	// DefaultRetryBudget bounds the time spent waiting to retry a request
+	DefaultRetryBudget = 30 * time.Second
+	// DefaultCacheSize caps the response cache, in megabytes
+	DefaultCacheSize = 50
+)
+
+// Config holds the settings and tokens of a single profile
 type Config struct {
-	ClientID     string `json:"client_id"`
-	RedirectPath string `json:"redirect_path"`
-	Port         string `json:"port"`
-	AccessToken  string `json:"access_token"`
-	RefreshToken string `json:"refresh_token"`
-	TokenType    string `json:"token_type"`
-	TokenExpiry  int64  `json:"token_expiry"`
-	LastSaved    int64  `json:"last_saved"`
+	ClientID string `json:"client_id"`
+	// ClientSecret enables app-only access for catalog commands without a login
+	ClientSecret  string `json:"client_secret,omitempty"`
+	RedirectPath  string `json:"redirect_path"`
+	Port          string `json:"port"`
+	DefaultDevice string `json:"default_device,omitempty"`
+	// Scopes to request at login, the defaults when empty
+	Scopes []string `json:"scopes,omitempty"`
+
+	// Endpoints and HTTP settings, for pointing at a mock or going through a proxy
+	AccountsURL string    `json:"accounts_url,omitempty"`
+	APIURL      string    `json:"api_url,omitempty"`
+	Timeout     *Duration `json:"timeout,omitempty"`
+	MaxRetries  *int      `json:"max_retries,omitempty"`
+	RetryBudget *Duration `json:"retry_budget,omitempty"`
+	Proxy       string    `json:"proxy,omitempty"`
+	CABundle    string    `json:"ca_bundle,omitempty"`
+	UserAgent   string    `json:"user_agent,omitempty"`
+
+	// CacheSize caps the response cache in megabytes, 0 disables it
+	CacheSize *int `json:"cache_size,omitempty"`
+
+	// EnvTokenReplaced is the fingerprint of the SPOTIFYCLI_REFRESH_TOKEN that
+	// Spotify replaced with the stored tokens
+	EnvTokenReplaced string `json:"env_token_replaced,omitempty"`
+
+	// Tokens are only written to the config file by the file token store
+	Tokens
+
+	name string
+	file *File
+	// savedTokens is what the token store last held, to skip needless writes
+	savedTokens Tokens
+	// tokensLoaded is set once the tokens have been read from the token store
+	tokensLoaded bool
+	// tokensReplaced is set when the tokens were set or cleared without
+	// loading them first, as login and logout do
+	tokensReplaced bool
+	// overrides are the settings taken from defaults, the environment or flags
+	overrides map[string]override
+	// envTokens is set when the tokens came from the environment
+	envTokens bool
 }
 
-func getConfigPath() (string, error) {
-	home, err := os.UserHomeDir()
-	if err != nil {
-		return "", err
+// File is the on-disk configuration, holding every named profile
+type File struct {
+	// Version is the schema version, see CurrentVersion
+	Version       int                `json:"version"`
+	ActiveProfile string             `json:"active_profile"`
+	Profiles      map[string]*Config `json:"profiles"`
+	KeySource     string             `json:"key_source,omitempty"`
+	KeySalt       string             `json:"key_salt,omitempty"`
+	TokenStore    string             `json:"token_store,omitempty"`
+	LastSaved     int64              `json:"last_saved"`
+
+	// key is the resolved encryption key, cached so a passphrase is only asked for once
+	key string
+
+	store    TokenStore
+	previous TokenStore
+	removed  []string
+
+	// lockMu guards the advisory lock held on the config file, see lock
+	lockMu     sync.Mutex
+	lockDepth  int
+	lockHandle *os.File
+}
+
+// Path returns the location of the config file
+func Path() (string, error) {
+	return getConfigPath()
+}
+
+func newProfile() *Config {
+	return &Config{
+		RedirectPath: "callback",
 	}
-	return filepath.Join(home, ".config", configFileName), nil
 }
 
+// LoadConfig loads the selected profile: SPOTIFYCLI_PROFILE if set, otherwise
+// the active one, with defaults, environment variables and flags applied
 func LoadConfig() (*Config, error) {
+	return LoadProfile("")
+}
+
+// LoadProfile loads the named profile, falling back to SPOTIFYCLI_PROFILE and
+// then the active profile when name is empty. Defaults, SPOTIFYCLI_*
+// environment variables and flag values are layered over the file's settings.
+func LoadProfile(name string) (*Config, error) {
+	f, err := LoadFile()
+	if err != nil {
+		return nil, err
+	}
+
+	if name == "" {
+		name = os.Getenv("SPOTIFYCLI_PROFILE")
+	}
+
+	if name == "" {
+		name = f.ActiveProfile
+	}
+
+	profile, err := f.Profile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := profile.applyLayers(); err != nil {
+		return nil, err
+	}
+	return profile, nil
+}
+
+// LoadFile loads the whole configuration file, first migrating a file written
+// by an older spotifycli to the current schema version
+func LoadFile() (*File, error) {
 	path, err := getConfigPath()
 	if err != nil {
 		return nil, err
@@ -38,76 +153,330 @@ func LoadConfig() (*Config, error) {
 
 	if _, err := os.Stat(path); os.IsNotExist(err) {
 		// Default config
-		return &Config{
-			RedirectPath: "callback",
-		}, nil
+		return newFile(newProfile()), nil
+	}
+
+	f, err := readFile(path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	for name, profile := range f.Profiles {
+		profile.name = name
+		profile.file = f
+	}
+
+	store, err := openTokenStore(f, f.TokenStoreName())
+	if err != nil {
+		return nil, err
+	}
+	f.store = store
+
+	// Tokens are only read from the store, and decrypted, by LoadTokens
+	for _, profile := range f.Profiles {
+		profile.Tokens = Tokens{}
+	}
+
+	return f, nil
+}
+
+// readFile reads and parses the config file, migrating it if it is out of
+// date. The migration holds the config lock of locker, a File that may
+// already hold it, or of the new File when locker is nil.
+func readFile(path string, locker *File) (*File, error) {
+	var f File
+	if locker == nil {
+		locker = &f
 	}
 
 	data, err := os.ReadFile(path)
-	if err != nil && err != io.EOF {
+	if err != nil {
 		return nil, err
 	}
 
-	var cfg Config
-	if err := json.Unmarshal(data, &cfg); err != nil {
+	data, err = migrate(path, data, locker)
+	if err != nil {
 		return nil, err
 	}
 
-	// Decrypt tokens if present
-	key := os.Getenv("SPOTIFYCLI_KEY")
-	if key == "" {
-		fmt.Println(key)
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
 	}
 
-	if cfg.AccessToken != "" {
-		plain, err := DecryptToken(cfg.AccessToken, key)
-		if err == nil {
-			cfg.AccessToken = plain
+	if len(f.Profiles) == 0 {
+		f.Profiles = map[string]*Config{DefaultProfile: newProfile()}
+	}
+	if f.ActiveProfile == "" {
+		f.ActiveProfile = DefaultProfile
+	}
+
+	for name, profile := range f.Profiles {
+		if err := profile.validate(); err != nil {
+			return nil, fmt.Errorf("%s: profile %q: %w", path, name, err)
 		}
 	}
 
-	if cfg.RefreshToken != "" {
-		plain, err := DecryptToken(cfg.RefreshToken, key)
-		if err == nil {
-			cfg.RefreshToken = plain
+	// The file may have been edited by hand
+	if err := ValidateTokenStore(f.TokenStoreName()); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return &f, nil
+}
+
+// loadAllTokens loads the tokens of every profile, for moving them to another
+// store or key
+func (f *File) loadAllTokens() error {
+	for _, profile := range f.Profiles {
+		if err := profile.LoadTokens(); err != nil {
+			return err
 		}
 	}
+	return nil
+}
 
-	return &cfg, nil
+// TokenStoreName returns the name of the store holding the tokens
+func (f *File) TokenStoreName() string {
+	if f.TokenStore != "" {
+		return f.TokenStore
+	}
+	return TokenStoreFile
 }
 
-func (c *Config) Save() error {
+// SwitchTokenStore moves every profile's tokens to the named store on the next Save
+func (f *File) SwitchTokenStore(name string) error {
+	if name == f.TokenStoreName() {
+		return nil
+	}
+	if err := ValidateTokenStore(name); err != nil {
+		return err
+	}
+
+	if err := f.loadAllTokens(); err != nil {
+		return err
+	}
+
+	store, err := openTokenStore(f, name)
+	if err != nil {
+		return err
+	}
+
+	f.previous = f.store
+	f.store = store
+	f.TokenStore = name
+
+	// Force every profile's tokens to be written to the new store
+	for _, profile := range f.Profiles {
+		profile.savedTokens = Tokens{}
+	}
+
+	return nil
+}
+
+// KeySourceName returns the name of the key source used to encrypt tokens
+func (f *File) KeySourceName() string {
+	if f.KeySource != "" {
+		return f.KeySource
+	}
+
+	// Existing setups that export SPOTIFYCLI_KEY keep using it
+	if os.Getenv("SPOTIFYCLI_KEY") != "" {
+		return KeySourceEnv
+	}
+
+	return KeySourceFile
+}
+
+// encryptionKey resolves the key from the configured key source
+func (f *File) encryptionKey() (string, error) {
+	if f.key != "" {
+		return f.key, nil
+	}
+
+	source, err := KeySourceByName(f.KeySourceName())
+	if err != nil {
+		return "", err
+	}
+
+	key, err := source.Key(f)
+	if err != nil {
+		return "", fmt.Errorf("failed to get %s encryption key: %w", source.Name(), err)
+	}
+
+	f.key = key
+	return key, nil
+}
+
+func decryptError(profile, source string, err error) error {
+	return fmt.Errorf("%w for profile %q with the %s key (%v); check the key or run 'spotifycli logout --profile %s' to discard them",
+		ErrTokenDecrypt, profile, source, err, profile)
+}
+
+// RotateKey re-encrypts all tokens with a new key from the given source and
+// saves them. The source may differ from the current one.
+func (f *File) RotateKey(source KeySource) error {
+	if f.TokenStoreName() != TokenStoreFile {
+		return fmt.Errorf("the %s token store does not use an encryption key", f.TokenStoreName())
+	}
+
+	// Both keys may have to be asked for, so get them before taking the
+	// lock rather than keep other spotifycli processes waiting on a prompt
+	if fileStore, ok := f.store.(*fileTokenStore); ok && len(fileStore.encrypted) > 0 {
+		if _, err := f.encryptionKey(); err != nil {
+			return err
+		}
+	}
+
+	key, commit, err := source.NewKey(f)
+	if err != nil {
+		return fmt.Errorf("failed to create %s encryption key: %w", source.Name(), err)
+	}
+
+	unlock, err := f.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	// Decrypt with the old key before it is replaced
+	if err := f.loadAllTokens(); err != nil {
+		return err
+	}
+
+	f.KeySource = source.Name()
+	f.key = key
+
+	// Force every profile's tokens to be re-encrypted
+	for _, profile := range f.Profiles {
+		profile.savedTokens = Tokens{}
+	}
+
+	if err := f.Save(); err != nil {
+		return err
+	}
+
+	return commit()
+}
+
+func newFile(profile *Config) *File {
+	f := &File{
+		Version:       CurrentVersion,
+		ActiveProfile: DefaultProfile,
+		Profiles:      map[string]*Config{DefaultProfile: profile},
+	}
+	profile.name = DefaultProfile
+	profile.file = f
+	f.store = newFileTokenStore(f)
+	return f
+}
+
+// Profile returns the named profile
+func (f *File) Profile(name string) (*Config, error) {
+	profile, ok := f.Profiles[name]
+	if !ok {
+		return nil, fmt.Errorf("profile %q does not exist, create it with 'spotifycli profile add %s'", name, name)
+	}
+	return profile, nil
+}
+
+// ProfileNames returns the names of all profiles in sorted order
+func (f *File) ProfileNames() []string {
+	names := make([]string, 0, len(f.Profiles))
+	for name := range f.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// AddProfile creates a new, unauthenticated profile
+func (f *File) AddProfile(name string) (*Config, error) {
+	if name == "" {
+		return nil, fmt.Errorf("profile name is required")
+	}
+
+	if _, ok := f.Profiles[name]; ok {
+		return nil, fmt.Errorf("profile %q already exists", name)
+	}
+
+	profile := newProfile()
+	profile.name = name
+	profile.file = f
+	f.Profiles[name] = profile
+
+	return profile, nil
+}
+
+// UseProfile makes the named profile the active one
+func (f *File) UseProfile(name string) error {
+	if _, err := f.Profile(name); err != nil {
+		return err
+	}
+
+	f.ActiveProfile = name
+	return nil
+}
+
+// RemoveProfile deletes the named profile along with its tokens
+func (f *File) RemoveProfile(name string) error {
+	if _, err := f.Profile(name); err != nil {
+		return err
+	}
+
+	if name == f.ActiveProfile {
+		return fmt.Errorf("cannot remove the active profile %q, switch to another profile first", name)
+	}
+
+	delete(f.Profiles, name)
+	f.removed = append(f.removed, name)
+	return nil
+}
+
+// Save writes the file atomically while holding the config lock. Tokens this
+// process has not changed are taken from the file on disk, in case another
+// process has refreshed them since the file was loaded.
+func (f *File) Save() error {
 	path, err := getConfigPath()
 	if err != nil {
 		return err
 	}
 
-	key := os.Getenv("SPOTIFYCLI_KEY")
-	if key == "" {
-		key = generateEncryptionKey()
+	unlock, err := f.lock()
+	if err != nil {
+		return err
 	}
+	defer unlock()
 
-	if err := os.Setenv("SPOTIFYCLI_KEY", key); err != nil {
+	if err := f.mergeDiskTokens(path); err != nil {
 		return err
 	}
 
-	encCfg := *c
-	if encCfg.AccessToken != "" {
-		enc, err := EncryptToken(encCfg.AccessToken, key)
-		if err == nil {
-			encCfg.AccessToken = enc
-		}
+	if err := f.saveTokens(); err != nil {
+		return err
+	}
+
+	encFile := File{
+		Version:       CurrentVersion,
+		ActiveProfile: f.ActiveProfile,
+		Profiles:      make(map[string]*Config, len(f.Profiles)),
+		KeySource:     f.KeySource,
+		KeySalt:       f.KeySalt,
+		TokenStore:    f.TokenStore,
+		LastSaved:     time.Now().Unix(),
 	}
 
-	if encCfg.RefreshToken != "" {
-		enc, err := EncryptToken(encCfg.RefreshToken, key)
-		if err == nil {
-			encCfg.RefreshToken = enc
+	fileStore, inFile := f.store.(*fileTokenStore)
+	for name, profile := range f.Profiles {
+		encCfg := profile.persisted()
+		encCfg.Tokens = Tokens{}
+		if inFile {
+			encCfg.Tokens = fileStore.encrypted[name]
 		}
+
+		encFile.Profiles[name] = &encCfg
 	}
 
-	encCfg.LastSaved = time.Now().Unix()
-	data, err := json.MarshalIndent(encCfg, "", "  ")
+	data, err := json.MarshalIndent(&encFile, "", "  ")
 	if err != nil {
 		return err
 	}
@@ -117,11 +486,230 @@ func (c *Config) Save() error {
 		return err
 	}
 
-	return os.WriteFile(path, data, 0600)
+	if err := writeFileAtomic(path, data, 0600); err != nil {
+		return err
+	}
+
+	f.LastSaved = encFile.LastSaved
+
+	// Only clear out the old store once the tokens are safely in the new one
+	if f.previous != nil {
+		for name := range f.Profiles {
+			if err := f.previous.Delete(name); err != nil {
+				return err
+			}
+		}
+		f.previous = nil
+	}
+
+	return nil
 }
 
+// mergeDiskTokens takes the encrypted tokens of each profile this process has
+// not changed from the config file on disk, so that saving never puts back a
+// refresh token another process has since rotated. The caller holds the lock.
+func (f *File) mergeDiskTokens(path string) error {
+	if f.previous != nil {
+		return nil
+	}
+
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil
+	}
+
+	disk, err := readFile(path, f)
+	if err != nil {
+		return err
+	}
+
+	// Which environment token the stored tokens replace goes along with them
+	for name, profile := range f.Profiles {
+		if diskProfile, ok := disk.Profiles[name]; ok && !profile.tokensChanged() {
+			profile.EnvTokenReplaced = diskProfile.EnvTokenReplaced
+		}
+	}
+
+	// Tokens encrypted with another key can't be used, e.g. after a rotate-key
+	fileStore, ok := f.store.(*fileTokenStore)
+	if !ok || disk.TokenStoreName() != TokenStoreFile || disk.KeySource != f.KeySource || disk.KeySalt != f.KeySalt {
+		return nil
+	}
+
+	for name, profile := range f.Profiles {
+		if profile.tokensChanged() {
+			continue
+		}
+
+		if diskProfile, ok := disk.Profiles[name]; ok && !diskProfile.Tokens.IsZero() {
+			fileStore.encrypted[name] = diskProfile.Tokens
+		} else {
+			delete(fileStore.encrypted, name)
+		}
+	}
+
+	return nil
+}
+
+// saveTokens writes changed tokens to the token store
+func (f *File) saveTokens() error {
+	for name, profile := range f.Profiles {
+		if !profile.tokensChanged() {
+			continue
+		}
+
+		var err error
+		if profile.Tokens.IsZero() {
+			err = f.store.Delete(name)
+		} else {
+			err = f.store.Store(name, profile.Tokens)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to save tokens to %s store: %w", f.store.Name(), err)
+		}
+
+		profile.savedTokens = profile.Tokens
+		profile.tokensLoaded = true
+		profile.tokensReplaced = false
+	}
+
+	for _, name := range f.removed {
+		if err := f.store.Delete(name); err != nil {
+			return fmt.Errorf("failed to delete tokens from %s store: %w", f.store.Name(), err)
+		}
+	}
+	f.removed = nil
+
+	return nil
+}
+
+// Save writes the profile, along with every other profile, to disk
+func (c *Config) Save() error {
+	if c.file == nil {
+		newFile(c)
+	}
+	return c.file.Save()
+}
+
+// tokensChanged reports whether the tokens need writing to the token store.
+// Tokens from the environment are only written to clear the stored ones.
+func (c *Config) tokensChanged() bool {
+	if c.envTokens && !c.Tokens.IsZero() {
+		return false
+	}
+	if !c.tokensLoaded {
+		return c.tokensReplaced
+	}
+	return c.Tokens != c.savedTokens
+}
+
+// LoadTokens reads the profile's tokens from the token store, decrypting
+// them. Only commands that use the login call it, so a profile whose tokens
+// can't be decrypted doesn't get in the way of the others.
+func (c *Config) LoadTokens() error {
+	if c.file == nil || c.tokensLoaded || c.tokensReplaced || c.envTokens {
+		return nil
+	}
+
+	tokens, err := c.file.store.Load(c.name)
+	if err != nil {
+		return err
+	}
+
+	c.Tokens = tokens
+	c.savedTokens = tokens
+	c.tokensLoaded = true
+	return nil
+}
+
+// HasStoredLogin reports whether the token store holds a login for the
+// profile, without decrypting it
+func (c *Config) HasStoredLogin() (bool, error) {
+	if c.file == nil || c.tokensLoaded || c.tokensReplaced {
+		return c.IsAuthenticated(), nil
+	}
+
+	if fileStore, ok := c.file.store.(*fileTokenStore); ok {
+		return fileStore.encrypted[c.name].RefreshToken != "", nil
+	}
+
+	tokens, err := c.file.store.Load(c.name)
+	if err != nil {
+		return false, err
+	}
+	return tokens.RefreshToken != "", nil
+}
+
+// LockTokens takes the config lock so the tokens can be re-read, refreshed and
+// saved without another spotifycli process doing the same in between
+func (c *Config) LockTokens() (func(), error) {
+	if c.file == nil {
+		return func() {}, nil
+	}
+	return c.file.lock()
+}
+
+// ReloadTokens re-reads the profile's tokens from the token store, picking up
+// tokens another spotifycli process has refreshed since loading
+func (c *Config) ReloadTokens() error {
+	if c.file == nil {
+		return nil
+	}
+
+	path, err := getConfigPath()
+	if err != nil {
+		return err
+	}
+
+	if c.envTokens {
+		// Another process may have had the environment token replaced
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			return nil
+		}
+
+		disk, err := readFile(path, c.file)
+		if err != nil {
+			return err
+		}
+
+		replaced := fingerprint(c.RefreshToken)
+		if diskProfile, ok := disk.Profiles[c.name]; !ok || diskProfile.EnvTokenReplaced != replaced {
+			return nil
+		}
+
+		c.EnvTokenReplaced = replaced
+		c.envTokens = false
+	}
+
+	if err := c.file.mergeDiskTokens(path); err != nil {
+		return err
+	}
+
+	tokens, err := c.file.store.Load(c.name)
+	if err != nil {
+		return err
+	}
+
+	c.Tokens = tokens
+	c.savedTokens = tokens
+	c.tokensLoaded = true
+	c.tokensReplaced = false
+	return nil
+}
+
+// Name returns the name of the profile
+func (c *Config) Name() string {
+	return c.name
+}
+
+// File returns the configuration file the profile belongs to
+func (c *Config) File() *File {
+	return c.file
+}
+
+// IsAuthenticated reports whether there is a user login. An access token can
+// always be got from the refresh token, so the refresh token is enough.
 func (c *Config) IsAuthenticated() bool {
-	return c.AccessToken != "" && c.RefreshToken != ""
+	return c.RefreshToken != ""
 }
 
 func (c *Config) IsTokenExpired() bool {
@@ -133,6 +721,8 @@ func (c *Config) IsTokenExpired() bool {
 }
 
 func (c *Config) SetTokens(access, refresh, tokenType string, expiresIn int64) {
+	c.markTokensReplaced()
+	c.replaceEnvTokens(refresh)
 	c.AccessToken = access
 	c.RefreshToken = refresh
 	c.TokenType = tokenType
@@ -140,10 +730,74 @@ func (c *Config) SetTokens(access, refresh, tokenType string, expiresIn int64) {
 }
 
 func (c *Config) ClearTokens() {
-	c.AccessToken = ""
-	c.RefreshToken = ""
-	c.TokenType = ""
-	c.TokenExpiry = 0
+	c.markTokensReplaced()
+	c.Tokens = Tokens{}
+}
+
+// markTokensReplaced records that tokens set before loading the stored ones
+// replace them, so Save writes them even when they look unchanged
+func (c *Config) markTokensReplaced() {
+	if !c.tokensLoaded {
+		c.tokensReplaced = true
+	}
+}
+
+// AppCredentials returns the client ID and secret for app-only access. The
+// secret is empty when none is configured.
+func (c *Config) AppCredentials() (clientID, clientSecret string) {
+	return c.ClientID, c.ClientSecret
+}
+
+// HasAppCredentials reports whether app-only access is configured
+func (c *Config) HasAppCredentials() bool {
+	clientID, clientSecret := c.AppCredentials()
+	return clientID != "" && clientSecret != ""
+}
+
+// GrantedScopes returns the scopes the stored tokens were granted
+func (c *Config) GrantedScopes() []string {
+	return strings.Fields(c.Scope)
+}
+
+// RequestTimeout returns the timeout of each attempt of a request
+func (c *Config) RequestTimeout() time.Duration {
+	if c.Timeout == nil {
+		return DefaultTimeout
+	}
+	return time.Duration(*c.Timeout)
+}
+
+// RetryLimit returns how often a failed request may be retried, 0 when
+// retrying is disabled by max_retries or a zero retry_budget
+func (c *Config) RetryLimit() int {
+	if c.RetryBudget != nil && *c.RetryBudget == 0 {
+		return 0
+	}
+	if c.MaxRetries == nil {
+		return DefaultMaxRetries
+	}
+	return *c.MaxRetries
+}
+
+// RetryWait returns the longest time to spend waiting to retry a request
+func (c *Config) RetryWait() time.Duration {
+	if c.RetryBudget == nil {
+		return DefaultRetryBudget
+	}
+	return time.Duration(*c.RetryBudget)
+}
+
+// CacheMaxSize returns the cap of the response cache in bytes, 0 when the
+// cache is disabled
+func (c *Config) CacheMaxSize() int64 {
+	if c.CacheSize == nil {
+		return DefaultCacheSize << 20
+	}
+	return int64(*c.CacheSize) << 20
+}
+
+func (c *Config) GetClientID() string {
+	return c.ClientID
 }
 
 func (c *Config) GetAccessToken() string {
@@ -0,0 +1,89 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// useTempConfig points the package at a config file in a new temporary
+// directory, with nothing from the environment running the tests leaking in,
+// and returns its path
+func useTempConfig(t *testing.T) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "spotifycli.json")
+	t.Setenv("SPOTIFYCLI_CONFIG", path)
+
+	for _, name := range []string{"SPOTIFYCLI_PROFILE", "SPOTIFYCLI_REFRESH_TOKEN", "SPOTIFYCLI_ACCESS_TOKEN", "SPOTIFYCLI_KEY", "SPOTIFYCLI_NEW_KEY", "SPOTIFYCLI_PASSPHRASE", "SPOTIFYCLI_NEW_PASSPHRASE"} {
+		t.Setenv(name, "")
+	}
+	for _, s := range settings {
+		t.Setenv("SPOTIFYCLI_"+strings.ToUpper(s.Key), "")
+	}
+
+	pathFlag, flagValues, PromptPassphrase = "", nil, nil
+	t.Cleanup(func() {
+		pathFlag, flagValues, PromptPassphrase = "", nil, nil
+	})
+
+	return path
+}
+
+// writeConfig writes data as the config file at path
+func writeConfig(t *testing.T, path, data string) {
+	t.Helper()
+
+	if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+}
+
+// readConfig returns the config file at path
+func readConfig(t *testing.T, path string) string {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read config: %v", err)
+	}
+	return string(data)
+}
+
+// saveLogin stores tokens for the default profile of a new config file
+func saveLogin(t *testing.T, access, refresh string) *File {
+	t.Helper()
+
+	f, err := LoadFile()
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+
+	profile, err := f.Profile(DefaultProfile)
+	if err != nil {
+		t.Fatalf("failed to get profile: %v", err)
+	}
+	profile.SetTokens(access, refresh, "Bearer", 3600)
+
+	if err := f.Save(); err != nil {
+		t.Fatalf("failed to save config: %v", err)
+	}
+	return f
+}
+
+// loadTokens loads the tokens of the default profile from a fresh load of
+// the config file
+func loadTokens(t *testing.T) Tokens {
+	t.Helper()
+
+	cfg, err := LoadProfile(DefaultProfile)
+	if err != nil {
+		t.Fatalf("failed to load profile: %v", err)
+	}
+	if err := cfg.LoadTokens(); err != nil {
+		t.Fatalf("failed to load tokens: %v", err)
+	}
+	return cfg.Tokens
+}
@@ -8,7 +8,6 @@ import (
 	"encoding/base64"
 	"fmt"
 	"io"
-	"os"
 )
 
 // EncryptToken encrypts a token using AES-256-GCM
@@ -78,13 +77,10 @@ func DecryptToken(ciphertext, key string) (string, error) {
 }
 
 // generateEncryptionKey generates a random encryption key
-func generateEncryptionKey() string {
+func generateEncryptionKey() (string, error) {
 	key := make([]byte, 32)
 	if _, err := rand.Read(key); err != nil {
-		// Fallback to a deterministic key based on user home directory
-		homeDir, _ := os.UserHomeDir()
-		hash := sha256.Sum256([]byte(homeDir + "spotify-cli-key"))
-		return base64.StdEncoding.EncodeToString(hash[:])
+		return "", fmt.Errorf("failed to generate encryption key: %w", err)
 	}
-	return base64.StdEncoding.EncodeToString(key)
+	return base64.StdEncoding.EncodeToString(key), nil
 }
@@ -0,0 +1,257 @@
+package config
+
+import (
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// KeySourceFile keeps a random key in a 0600 file next to the config
+	KeySourceFile = "file"
+	// KeySourcePassphrase derives the key from a passphrase with PBKDF2
+	KeySourcePassphrase = "passphrase"
+	// KeySourceEnv reads the key from SPOTIFYCLI_KEY
+	KeySourceEnv = "env"
+
+	keyFileName = "spotifycli.key"
+
+	// pbkdf2Iterations follows the OWASP recommendation for PBKDF2-HMAC-SHA256
+	pbkdf2Iterations = 600000
+	saltSize         = 16
+)
+
+// ErrTokenDecrypt is returned when stored tokens cannot be decrypted with the configured key
+var ErrTokenDecrypt = errors.New("failed to decrypt stored tokens")
+
+// PromptPassphrase asks the user for a passphrase. It is set by the CLI so this
+// package never touches the terminal itself; when nil, passphrases can only
+// come from the environment.
+var PromptPassphrase func(prompt string) (string, error)
+
+// KeySource provides the key used to encrypt stored tokens
+type KeySource interface {
+	// Name identifies the source in the config file
+	Name() string
+	// Key returns the current key
+	Key(f *File) (string, error)
+	// NewKey returns a fresh key for rotation. Any state recorded in f is
+	// saved along with the re-encrypted tokens; commit is called once they
+	// are safely on disk.
+	NewKey(f *File) (key string, commit func() error, err error)
+}
+
+// KeySourceByName returns the key source with the given name
+func KeySourceByName(name string) (KeySource, error) {
+	switch name {
+	case KeySourceFile:
+		return fileKeySource{}, nil
+	case KeySourcePassphrase:
+		return passphraseKeySource{}, nil
+	case KeySourceEnv:
+		return envKeySource{}, nil
+	default:
+		return nil, fmt.Errorf("unknown key source: %s (must be 'file', 'passphrase', or 'env')", name)
+	}
+}
+
+// fileKeySource stores a random key in a file only the user can read
+type fileKeySource struct{}
+
+func (fileKeySource) Name() string {
+	return KeySourceFile
+}
+
+func (fileKeySource) Key(f *File) (string, error) {
+	path, err := getKeyFilePath()
+	if err != nil {
+		return "", err
+	}
+
+	data, err := os.ReadFile(path)
+	if err == nil {
+		// Editors and echo add a trailing newline to a key put there by hand
+		key := strings.TrimRight(string(data), " \t\r\n")
+		if key == "" {
+			return "", fmt.Errorf("key file %s is empty", path)
+		}
+		return key, nil
+	}
+
+	if !os.IsNotExist(err) {
+		return "", fmt.Errorf("failed to read key file: %w", err)
+	}
+
+	// First use, so create the key file
+	key, err := generateEncryptionKey()
+	if err != nil {
+		return "", err
+	}
+
+	if err := writeKeyFile(path, key); err != nil {
+		return "", err
+	}
+
+	return key, nil
+}
+
+func (fileKeySource) NewKey(f *File) (string, func() error, error) {
+	path, err := getKeyFilePath()
+	if err != nil {
+		return "", nil, err
+	}
+
+	key, err := generateEncryptionKey()
+	if err != nil {
+		return "", nil, err
+	}
+
+	// Stage the new key so the old one stays valid until the tokens are re-encrypted
+	staged := path + ".new"
+	if err := writeKeyFile(staged, key); err != nil {
+		return "", nil, err
+	}
+
+	commit := func() error {
+		return os.Rename(staged, path)
+	}
+
+	return key, commit, nil
+}
+
+// passphraseKeySource derives the key from a passphrase and a salt stored in the config
+type passphraseKeySource struct{}
+
+func (passphraseKeySource) Name() string {
+	return KeySourcePassphrase
+}
+
+func (passphraseKeySource) Key(f *File) (string, error) {
+	passphrase, err := readPassphrase("SPOTIFYCLI_PASSPHRASE", "Passphrase: ")
+	if err != nil {
+		return "", err
+	}
+
+	if f.KeySalt == "" {
+		salt, err := generateSalt()
+		if err != nil {
+			return "", err
+		}
+		f.KeySalt = salt
+	}
+
+	return deriveKey(passphrase, f.KeySalt)
+}
+
+func (passphraseKeySource) NewKey(f *File) (string, func() error, error) {
+	passphrase, err := readPassphrase("SPOTIFYCLI_NEW_PASSPHRASE", "New passphrase: ")
+	if err != nil {
+		return "", nil, err
+	}
+
+	salt, err := generateSalt()
+	if err != nil {
+		return "", nil, err
+	}
+
+	key, err := deriveKey(passphrase, salt)
+	if err != nil {
+		return "", nil, err
+	}
+
+	f.KeySalt = salt
+	return key, func() error { return nil }, nil
+}
+
+// envKeySource reads the key from SPOTIFYCLI_KEY
+type envKeySource struct{}
+
+func (envKeySource) Name() string {
+	return KeySourceEnv
+}
+
+func (envKeySource) Key(f *File) (string, error) {
+	key := os.Getenv("SPOTIFYCLI_KEY")
+	if key == "" {
+		return "", fmt.Errorf("SPOTIFYCLI_KEY is not set")
+	}
+	return key, nil
+}
+
+func (envKeySource) NewKey(f *File) (string, func() error, error) {
+	key := os.Getenv("SPOTIFYCLI_NEW_KEY")
+	if key == "" {
+		return "", nil, fmt.Errorf("set SPOTIFYCLI_NEW_KEY to the new key, then use it as SPOTIFYCLI_KEY afterwards")
+	}
+	return key, func() error { return nil }, nil
+}
+
+func getKeyFilePath() (string, error) {
+	path, err := getConfigPath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(path), keyFileName), nil
+}
+
+func writeKeyFile(path, key string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	if err := writeFileAtomic(path, []byte(key), 0600); err != nil {
+		return fmt.Errorf("failed to write key file: %w", err)
+	}
+
+	return nil
+}
+
+func readPassphrase(envVar, prompt string) (string, error) {
+	if passphrase := os.Getenv(envVar); passphrase != "" {
+		return passphrase, nil
+	}
+
+	if PromptPassphrase == nil {
+		return "", fmt.Errorf("a passphrase is required, set %s", envVar)
+	}
+
+	passphrase, err := PromptPassphrase(prompt)
+	if err != nil {
+		return "", fmt.Errorf("failed to read passphrase: %w", err)
+	}
+
+	if passphrase == "" {
+		return "", fmt.Errorf("passphrase cannot be empty")
+	}
+
+	return passphrase, nil
+}
+
+// deriveKey runs the passphrase through PBKDF2-HMAC-SHA256
+func deriveKey(passphrase, salt string) (string, error) {
+	rawSalt, err := base64.StdEncoding.DecodeString(salt)
+	if err != nil {
+		return "", fmt.Errorf("invalid key salt in config: %w", err)
+	}
+
+	key, err := pbkdf2.Key(sha256.New, passphrase, rawSalt, pbkdf2Iterations, 32)
+	if err != nil {
+		return "", fmt.Errorf("failed to derive key: %w", err)
+	}
+
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+func generateSalt() (string, error) {
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("failed to generate salt: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(salt), nil
+}
@@ -0,0 +1,251 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// useKeySource writes a config file using the named key source, with the
+// environment it needs
+func useKeySource(t *testing.T, path, source string) {
+	t.Helper()
+
+	switch source {
+	case KeySourceEnv:
+		t.Setenv("SPOTIFYCLI_KEY", "the-env-key")
+	case KeySourcePassphrase:
+		t.Setenv("SPOTIFYCLI_PASSPHRASE", "correct horse battery staple")
+	}
+	writeConfig(t, path, `{"version": 1, "active_profile": "default", "key_source": "`+source+`", "profiles": {"default": {}}}`)
+}
+
+func TestKeySourceRoundTrip(t *testing.T) {
+	tests := []struct {
+		source string
+		// breakKey makes the key differ from the one the tokens were saved with
+		breakKey func(t *testing.T, path string)
+	}{
+		{KeySourceFile, func(t *testing.T, path string) {
+			key, _ := generateEncryptionKey()
+			writeKeyFile(filepath.Join(filepath.Dir(path), keyFileName), key)
+		}},
+		{KeySourceEnv, func(t *testing.T, path string) {
+			t.Setenv("SPOTIFYCLI_KEY", "another-key")
+		}},
+		{KeySourcePassphrase, func(t *testing.T, path string) {
+			t.Setenv("SPOTIFYCLI_PASSPHRASE", "wrong passphrase")
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			path := useTempConfig(t)
+			useKeySource(t, path, tt.source)
+
+			saveLogin(t, "access-secret", "refresh-secret")
+
+			data := readConfig(t, path)
+			if strings.Contains(data, "access-secret") || strings.Contains(data, "refresh-secret") || !strings.Contains(data, "refresh_token") {
+				t.Errorf("config file does not hold the tokens encrypted:\n%s", data)
+			}
+			if tt.source == KeySourcePassphrase && !strings.Contains(data, "key_salt") {
+				t.Errorf("config file has no salt for the passphrase:\n%s", data)
+			}
+
+			if tokens := loadTokens(t); tokens.AccessToken != "access-secret" || tokens.RefreshToken != "refresh-secret" {
+				t.Errorf("loaded tokens %+v, want those saved", tokens)
+			}
+
+			tt.breakKey(t, path)
+
+			cfg, err := LoadProfile(DefaultProfile)
+			if err != nil {
+				t.Fatalf("failed to load profile: %v", err)
+			}
+			if err := cfg.LoadTokens(); !errors.Is(err, ErrTokenDecrypt) {
+				t.Errorf("LoadTokens with the wrong key: error %v, want %v", err, ErrTokenDecrypt)
+			}
+		})
+	}
+}
+
+func TestKeyFileTrailingWhitespace(t *testing.T) {
+	path := useTempConfig(t)
+	keyFile := filepath.Join(filepath.Dir(path), keyFileName)
+
+	// A key file written by hand, with the newline echo adds
+	if err := os.WriteFile(keyFile, []byte("hand-made-key\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	saveLogin(t, "access-secret", "refresh-secret")
+
+	if err := os.WriteFile(keyFile, []byte("hand-made-key \r\n\t"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if tokens := loadTokens(t); tokens.RefreshToken != "refresh-secret" {
+		t.Errorf("loaded tokens %+v, want those saved", tokens)
+	}
+
+	// The key is the same without the whitespace
+	if err := os.WriteFile(keyFile, []byte("hand-made-key"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if tokens := loadTokens(t); tokens.RefreshToken != "refresh-secret" {
+		t.Errorf("loaded tokens %+v, want those saved", tokens)
+	}
+
+	if err := os.WriteFile(keyFile, []byte("\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := LoadProfile(DefaultProfile)
+	if err != nil {
+		t.Fatalf("failed to load profile: %v", err)
+	}
+	if err := cfg.LoadTokens(); err == nil || !strings.Contains(err.Error(), "is empty") {
+		t.Errorf("LoadTokens with an empty key file: error %v, want it refused", err)
+	}
+}
+
+func TestRotateKey(t *testing.T) {
+	tests := []struct {
+		from, to string
+		// useNewKey sets up the environment for the new key once rotated
+		useNewKey func(t *testing.T)
+	}{
+		{KeySourceFile, KeySourceFile, func(t *testing.T) {}},
+		{KeySourceFile, KeySourcePassphrase, func(t *testing.T) {
+			t.Setenv("SPOTIFYCLI_PASSPHRASE", "new passphrase")
+		}},
+		{KeySourcePassphrase, KeySourceEnv, func(t *testing.T) {
+			t.Setenv("SPOTIFYCLI_KEY", "the-new-env-key")
+		}},
+		{KeySourceEnv, KeySourceFile, func(t *testing.T) {
+			t.Setenv("SPOTIFYCLI_KEY", "")
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.from+" to "+tt.to, func(t *testing.T) {
+			path := useTempConfig(t)
+			useKeySource(t, path, tt.from)
+			t.Setenv("SPOTIFYCLI_NEW_PASSPHRASE", "new passphrase")
+			t.Setenv("SPOTIFYCLI_NEW_KEY", "the-new-env-key")
+
+			f := saveLogin(t, "access-secret", "refresh-secret")
+			work, err := f.AddProfile("work")
+			if err != nil {
+				t.Fatalf("failed to add profile: %v", err)
+			}
+			work.SetTokens("work-access", "work-refresh", "Bearer", 3600)
+			if err := f.Save(); err != nil {
+				t.Fatalf("failed to save config: %v", err)
+			}
+			before := readConfig(t, path)
+
+			f, err = LoadFile()
+			if err != nil {
+				t.Fatalf("failed to load config: %v", err)
+			}
+			source, err := KeySourceByName(tt.to)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := f.RotateKey(source); err != nil {
+				t.Fatalf("failed to rotate key: %v", err)
+			}
+
+			// Every profile's tokens are encrypted anew
+			after := readConfig(t, path)
+			for _, profile := range []string{DefaultProfile, "work"} {
+				old, _ := readFileTokens(before, profile)
+				rotated, ok := readFileTokens(after, profile)
+				if !ok || rotated.RefreshToken == "" || rotated.RefreshToken == old.RefreshToken || rotated.AccessToken == old.AccessToken {
+					t.Errorf("tokens of %s not re-encrypted:\nbefore %+v\nafter %+v", profile, old, rotated)
+				}
+			}
+			if !strings.Contains(after, `"key_source": "`+tt.to+`"`) {
+				t.Errorf("config file does not name the %s key source:\n%s", tt.to, after)
+			}
+			if _, err := os.Stat(filepath.Join(filepath.Dir(path), keyFileName+".new")); !os.IsNotExist(err) {
+				t.Errorf("the staged key file was left behind")
+			}
+
+			tt.useNewKey(t)
+			if tokens := loadTokens(t); tokens.AccessToken != "access-secret" || tokens.RefreshToken != "refresh-secret" {
+				t.Errorf("loaded tokens %+v, want those saved before rotating", tokens)
+			}
+
+			cfg, err := LoadProfile("work")
+			if err != nil {
+				t.Fatalf("failed to load profile: %v", err)
+			}
+			if err := cfg.LoadTokens(); err != nil || cfg.RefreshToken != "work-refresh" {
+				t.Errorf("work tokens %+v, %v, want those saved before rotating", cfg.Tokens, err)
+			}
+		})
+	}
+}
+
+func TestRotateKeyPromptsBeforeLocking(t *testing.T) {
+	path := useTempConfig(t)
+	writeConfig(t, path, `{"version": 1, "active_profile": "default", "key_source": "passphrase", "profiles": {"default": {}}}`)
+
+	var f *File
+	var prompts []string
+	PromptPassphrase = func(prompt string) (string, error) {
+		prompts = append(prompts, prompt)
+
+		if f != nil {
+			f.lockMu.Lock()
+			locked := f.lockDepth > 0
+			f.lockMu.Unlock()
+			if locked {
+				t.Errorf("prompted for %q while holding the config lock", prompt)
+			}
+		}
+
+		if prompt == "New passphrase: " {
+			return "new passphrase", nil
+		}
+		return "old passphrase", nil
+	}
+
+	saveLogin(t, "access-secret", "refresh-secret")
+
+	var err error
+	f, err = LoadFile()
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	prompts = nil
+	if err := f.RotateKey(passphraseKeySource{}); err != nil {
+		t.Fatalf("failed to rotate key: %v", err)
+	}
+
+	if strings.Join(prompts, "|") != "Passphrase: |New passphrase: " {
+		t.Errorf("prompted for %q, want the old then the new passphrase", prompts)
+	}
+
+	t.Setenv("SPOTIFYCLI_PASSPHRASE", "new passphrase")
+	if tokens := loadTokens(t); tokens.RefreshToken != "refresh-secret" {
+		t.Errorf("loaded tokens %+v, want those saved before rotating", tokens)
+	}
+}
+
+// readFileTokens returns the profile's tokens as written in the config file
+func readFileTokens(data, profile string) (Tokens, bool) {
+	var f File
+	if err := json.Unmarshal([]byte(data), &f); err != nil {
+		return Tokens{}, false
+	}
+
+	p, ok := f.Profiles[profile]
+	if !ok {
+		return Tokens{}, false
+	}
+	return p.Tokens, true
+}
@@ -0,0 +1,174 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Settings are layered, each overriding the one before: defaults, the config
+// file, SPOTIFYCLI_* environment variables and --set flags. Only values from
+// the config file, or changed since loading, are written back on Save.
+
+var (
+	// pathFlag is the config file given with --config
+	pathFlag string
+	// flagValues are the settings given with --set
+	flagValues map[string]string
+)
+
+// SetPath uses the config file at p instead of the default location
+func SetPath(p string) {
+	pathFlag = p
+}
+
+// SetFlagValues sets settings from "key=value" pairs given on the command
+// line, overriding the config file and environment for the loaded profile
+func SetFlagValues(pairs []string) error {
+	values := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return fmt.Errorf("invalid setting %q, expected key=value", pair)
+		}
+		s, ok := lookupSetting(key)
+		if !ok {
+			return fmt.Errorf("unknown setting: %s", key)
+		}
+		if s.validate != nil && value != "" {
+			if err := s.validate(value); err != nil {
+				return fmt.Errorf("invalid value for %s: %w", key, err)
+			}
+		}
+		values[key] = value
+	}
+
+	flagValues = values
+	return nil
+}
+
+// getConfigPath returns the config file location: --config, then
+// SPOTIFYCLI_CONFIG, then the XDG config directory
+func getConfigPath() (string, error) {
+	if pathFlag != "" {
+		return pathFlag, nil
+	}
+
+	if p := os.Getenv("SPOTIFYCLI_CONFIG"); p != "" {
+		return p, nil
+	}
+
+	if dir := os.Getenv("XDG_CONFIG_HOME"); filepath.IsAbs(dir) {
+		return filepath.Join(dir, configFileName), nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".config", configFileName), nil
+}
+
+// override records a value from a layer above the config file
+type override struct {
+	file   string
+	value  string
+	source string
+}
+
+// applyLayers overlays the defaults, environment and flags on the profile as
+// loaded from the config file. Values from the environment are validated like
+// those from flags.
+func (c *Config) applyLayers() error {
+	for _, s := range settings {
+		value, source := "", ""
+
+		if s.get(c) == "" && s.fallback != "" {
+			value, source = s.fallback, SourceDefault
+		}
+		if v := os.Getenv(s.env()); v != "" {
+			if s.validate != nil {
+				if err := s.validate(v); err != nil {
+					return fmt.Errorf("invalid value for %s in %s: %w", s.Key, s.env(), err)
+				}
+			}
+			value, source = v, SourceEnv
+		}
+		if v, set := flagValues[s.Key]; set {
+			value, source = v, SourceFlag
+		}
+
+		if source != "" {
+			if c.overrides == nil {
+				c.overrides = make(map[string]override)
+			}
+			file := s.get(c)
+			s.set(c, value)
+			// Typed settings are recorded as they read back, "1m0s" for "1m"
+			c.overrides[s.Key] = override{file: file, value: s.get(c), source: source}
+		}
+	}
+
+	// A refresh token is enough to get an access token, so CI and containers
+	// can run without logging in. It is only written to the token store once
+	// Spotify replaces it, and from then on the stored tokens are used instead.
+	if refresh := os.Getenv("SPOTIFYCLI_REFRESH_TOKEN"); refresh != "" && c.EnvTokenReplaced != fingerprint(refresh) {
+		c.Tokens = Tokens{
+			AccessToken:  os.Getenv("SPOTIFYCLI_ACCESS_TOKEN"),
+			RefreshToken: refresh,
+			TokenType:    "Bearer",
+		}
+		c.envTokens = true
+	}
+
+	return nil
+}
+
+// EnvTokenRotated is called when Spotify replaces the refresh token from
+// SPOTIFYCLI_REFRESH_TOKEN, which then no longer works. It is set by the CLI
+// to warn about it, as this package never touches the terminal itself.
+var EnvTokenRotated func(profile string)
+
+// replaceEnvTokens makes tokens that Spotify has refreshed with a new refresh
+// token the profile's stored tokens, remembering which environment token they
+// replace so later runs use them instead
+func (c *Config) replaceEnvTokens(refresh string) {
+	if !c.envTokens || refresh == c.RefreshToken {
+		return
+	}
+
+	c.EnvTokenReplaced = fingerprint(c.RefreshToken)
+	c.envTokens = false
+	c.tokensReplaced = true
+
+	if EnvTokenRotated != nil {
+		EnvTokenRotated(c.name)
+	}
+}
+
+// fingerprint identifies a token without keeping the token itself
+func fingerprint(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:16])
+}
+
+// persisted returns the profile as it should be written to the config file,
+// with values from the other layers swapped back for the file's own unless
+// they have been changed since loading
+func (c *Config) persisted() Config {
+	p := *c
+	for _, s := range settings {
+		if o, ok := c.overrides[s.Key]; ok && s.get(&p) == o.value {
+			s.set(&p, o.file)
+		}
+	}
+	return p
+}
+
+// TokensFromEnv reports whether the tokens came from SPOTIFYCLI_REFRESH_TOKEN
+func (c *Config) TokensFromEnv() bool {
+	return c.envTokens
+}
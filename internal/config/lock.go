@@ -0,0 +1,91 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// lock takes the advisory lock that keeps spotifycli processes from
+// interleaving read-modify-write cycles of the config file. It is reentrant
+// within a File, so Save can run while the tokens are locked for a refresh.
+func (f *File) lock() (func(), error) {
+	f.lockMu.Lock()
+	defer f.lockMu.Unlock()
+
+	if f.lockDepth > 0 {
+		f.lockDepth++
+		return f.unlock, nil
+	}
+
+	path, err := getConfigPath()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+
+	lf, err := os.OpenFile(path+".lock", os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open config lock: %w", err)
+	}
+
+	if err := lockFile(lf); err != nil {
+		lf.Close()
+		return nil, fmt.Errorf("failed to lock config: %w", err)
+	}
+
+	f.lockHandle = lf
+	f.lockDepth = 1
+	return f.unlock, nil
+}
+
+func (f *File) unlock() {
+	f.lockMu.Lock()
+	defer f.lockMu.Unlock()
+
+	f.lockDepth--
+	if f.lockDepth > 0 {
+		return
+	}
+
+	unlockFile(f.lockHandle)
+	f.lockHandle.Close()
+	f.lockHandle = nil
+}
+
+// writeFileAtomic writes data to a temporary file next to path and renames it
+// into place, so readers see either the old or the new contents and never a
+// partial write
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+
+	// Clean up if anything fails before the rename
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,15 @@
+//go:build !unix && !windows
+
+package config
+
+import "os"
+
+// Platforms without file locking rely on atomic writes alone
+
+func lockFile(f *os.File) error {
+	return nil
+}
+
+func unlockFile(f *os.File) error {
+	return nil
+}
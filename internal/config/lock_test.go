@@ -0,0 +1,50 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLockContention(t *testing.T) {
+	useTempConfig(t)
+
+	// Two handles on the same config file, as two processes would have
+	first, second := newFile(newProfile()), newFile(newProfile())
+
+	unlock, err := first.lock()
+	if err != nil {
+		t.Fatalf("failed to lock: %v", err)
+	}
+
+	// The lock is reentrant within a handle
+	unlockAgain, err := first.lock()
+	if err != nil {
+		t.Fatalf("failed to lock again: %v", err)
+	}
+	unlockAgain()
+
+	acquired := make(chan func(), 1)
+	go func() {
+		unlock, err := second.lock()
+		if err != nil {
+			t.Errorf("failed to lock the second handle: %v", err)
+			unlock = func() {}
+		}
+		acquired <- unlock
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatalf("the second handle took the lock while the first held it")
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	unlock()
+
+	select {
+	case unlock := <-acquired:
+		unlock()
+	case <-time.After(5 * time.Second):
+		t.Fatalf("the second handle did not get the lock once it was released")
+	}
+}
@@ -0,0 +1,137 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// CurrentVersion is the schema version of the config files this build writes.
+// Bump it together with a new entry in migrations when the layout changes.
+const CurrentVersion = 1
+
+// ErrNewerConfig is returned for a config file written by a newer spotifycli
+var ErrNewerConfig = errors.New("config file was written by a newer version of spotifycli")
+
+// migration upgrades a config file document by one schema version
+type migration func(doc map[string]json.RawMessage) error
+
+// migrations[i] upgrades a file from version i to version i+1
+var migrations = []migration{
+	migrateToProfiles,
+}
+
+// migrate upgrades the config file at path to the current schema version,
+// writing a backup of the original next to it first. It returns the upgraded
+// contents, or data unchanged when the file is already current. The file is
+// read again and rewritten holding the config lock of locker, so processes
+// starting at once neither both migrate it nor undo each other's saves.
+func migrate(path string, data []byte, locker *File) ([]byte, error) {
+	doc, version, err := parseVersion(path, data)
+	if err != nil {
+		return nil, err
+	}
+	if version == CurrentVersion {
+		return data, nil
+	}
+
+	unlock, err := locker.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	// Another process may have migrated or saved the file in the meantime
+	data, err = os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	doc, version, err = parseVersion(path, data)
+	if err != nil {
+		return nil, err
+	}
+	if version == CurrentVersion {
+		return data, nil
+	}
+
+	backup := fmt.Sprintf("%s.v%d.bak", path, version)
+	if err := os.WriteFile(backup, data, 0600); err != nil {
+		return nil, fmt.Errorf("failed to back up config before migrating: %w", err)
+	}
+
+	for v := version; v < CurrentVersion; v++ {
+		if err := migrations[v](doc); err != nil {
+			return nil, fmt.Errorf("failed to migrate config from version %d to %d: %w", v, v+1, err)
+		}
+	}
+
+	doc["version"] = json.RawMessage(fmt.Sprint(CurrentVersion))
+
+	migrated, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := writeFileAtomic(path, migrated, 0600); err != nil {
+		return nil, fmt.Errorf("failed to write migrated config: %w", err)
+	}
+
+	return migrated, nil
+}
+
+// parseVersion parses the config file and its schema version, refusing a
+// file written by a newer spotifycli
+func parseVersion(path string, data []byte) (map[string]json.RawMessage, int, error) {
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, 0, err
+	}
+
+	version := 0
+	if raw, ok := doc["version"]; ok {
+		if err := json.Unmarshal(raw, &version); err != nil {
+			return nil, 0, fmt.Errorf("invalid config version in %s: %w", path, err)
+		}
+	}
+
+	if version > CurrentVersion {
+		return nil, 0, fmt.Errorf("%w: %s has schema version %d but this spotifycli supports up to version %d; upgrade spotifycli or point --config at another file",
+			ErrNewerConfig, path, version, CurrentVersion)
+	}
+
+	return doc, version, nil
+}
+
+// fileKeys are the top-level fields of the file rather than of a profile
+var fileKeys = []string{"version", "active_profile", "profiles", "key_source", "key_salt", "token_store", "last_saved"}
+
+// migrateToProfiles moves the settings and tokens of the old single-account
+// layout into the default profile. Files that already have profiles, written
+// before the schema was versioned, are left as they are.
+func migrateToProfiles(doc map[string]json.RawMessage) error {
+	if _, ok := doc["profiles"]; ok {
+		return nil
+	}
+
+	profile := make(map[string]json.RawMessage)
+	for key, value := range doc {
+		profile[key] = value
+	}
+	for _, key := range fileKeys {
+		delete(profile, key)
+	}
+	for key := range profile {
+		delete(doc, key)
+	}
+
+	profiles, err := json.Marshal(map[string]any{DefaultProfile: profile})
+	if err != nil {
+		return err
+	}
+
+	doc["profiles"] = profiles
+	doc["active_profile"] = json.RawMessage(fmt.Sprintf("%q", DefaultProfile))
+	return nil
+}
@@ -0,0 +1,169 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMigrate(t *testing.T) {
+	access, _ := EncryptToken("legacy-access", "legacy-key")
+	refresh, _ := EncryptToken("legacy-refresh", "legacy-key")
+
+	tests := []struct {
+		name string
+		data string
+		// backup is the backup file expected, empty for none
+		backup string
+		// wantErr is the error expected, nil when the file loads
+		wantErr error
+		check   func(t *testing.T, f *File)
+	}{
+		{
+			name:   "single account",
+			data:   `{"client_id": "legacy-app", "port": "8888", "access_token": "` + access + `", "refresh_token": "` + refresh + `", "token_expiry": 1700000000}`,
+			backup: ".v0.bak",
+			check: func(t *testing.T, f *File) {
+				if f.ActiveProfile != DefaultProfile || len(f.Profiles) != 1 {
+					t.Errorf("migrated to profiles %v with %s active, want only the default", f.ProfileNames(), f.ActiveProfile)
+				}
+				profile := f.Profiles[DefaultProfile]
+				if profile.ClientID != "legacy-app" || profile.Port != "8888" {
+					t.Errorf("default profile has client %q and port %q, want the old settings", profile.ClientID, profile.Port)
+				}
+
+				// The old tokens were encrypted with SPOTIFYCLI_KEY, which keeps working
+				if tokens := loadTokens(t); tokens.AccessToken != "legacy-access" || tokens.RefreshToken != "legacy-refresh" || tokens.TokenExpiry != 1700000000 {
+					t.Errorf("migrated tokens %+v, want the old ones", tokens)
+				}
+			},
+		},
+		{
+			name:   "unversioned profiles",
+			data:   `{"active_profile": "work", "profiles": {"default": {"client_id": "a"}, "work": {"client_id": "b"}}}`,
+			backup: ".v0.bak",
+			check: func(t *testing.T, f *File) {
+				if f.ActiveProfile != "work" || len(f.Profiles) != 2 || f.Profiles["work"].ClientID != "b" {
+					t.Errorf("profiles %v with %s active, want them kept as they were", f.ProfileNames(), f.ActiveProfile)
+				}
+			},
+		},
+		{
+			name: "current",
+			data: `{"version": 1, "active_profile": "default", "profiles": {"default": {"client_id": "a"}}}`,
+			check: func(t *testing.T, f *File) {
+				if f.Profiles[DefaultProfile].ClientID != "a" {
+					t.Errorf("client ID %q, want a", f.Profiles[DefaultProfile].ClientID)
+				}
+			},
+		},
+		{
+			name:    "newer",
+			data:    `{"version": 2, "active_profile": "default", "profiles": {"default": {}}, "new_field": true}`,
+			wantErr: ErrNewerConfig,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := useTempConfig(t)
+			t.Setenv("SPOTIFYCLI_KEY", "legacy-key")
+			writeConfig(t, path, tt.data)
+
+			f, err := LoadFile()
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("LoadFile error %v, want %v", err, tt.wantErr)
+				}
+				// A file that can't be read is left alone
+				if data := readConfig(t, path); data != tt.data {
+					t.Errorf("refused config file was changed:\n%s", data)
+				}
+			} else if err != nil {
+				t.Fatalf("failed to load config: %v", err)
+			}
+
+			matches, _ := os.ReadDir(filepath.Dir(path))
+			var backups []string
+			for _, m := range matches {
+				if strings.HasSuffix(m.Name(), ".bak") {
+					backups = append(backups, m.Name())
+				}
+			}
+			if tt.backup == "" {
+				if len(backups) != 0 {
+					t.Errorf("backups %v written, want none", backups)
+				}
+			} else if data, err := os.ReadFile(path + tt.backup); err != nil || string(data) != tt.data {
+				t.Errorf("backup %s holds %q (%v), want the original file", tt.backup, data, err)
+			}
+
+			if tt.wantErr != nil {
+				return
+			}
+
+			// The migrated file is written back, so it only happens once
+			if data := readConfig(t, path); !strings.Contains(data, `"version": 1`) {
+				t.Errorf("config file not at version 1:\n%s", data)
+			}
+			tt.check(t, f)
+		})
+	}
+}
+
+func TestMigrateInvalidVersion(t *testing.T) {
+	path := useTempConfig(t)
+	writeConfig(t, path, `{"version": "one", "profiles": {}}`)
+
+	if _, err := LoadFile(); err == nil || !strings.Contains(err.Error(), "invalid config version") {
+		t.Errorf("LoadFile error %v, want the version refused", err)
+	}
+}
+
+func TestMigrateWaitsForLock(t *testing.T) {
+	path := useTempConfig(t)
+	writeConfig(t, path, `{"client_id": "legacy-app"}`)
+
+	// Another process is saving the config file
+	holder := newFile(newProfile())
+	unlock, err := holder.lock()
+	if err != nil {
+		t.Fatalf("failed to lock: %v", err)
+	}
+
+	loaded := make(chan error, 1)
+	go func() {
+		_, err := LoadFile()
+		loaded <- err
+	}()
+
+	select {
+	case err := <-loaded:
+		t.Fatalf("migrated while another handle held the lock (error %v)", err)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	// It saves the file in the current layout before letting go
+	writeConfig(t, path, `{"version": 1, "active_profile": "default", "profiles": {"default": {"client_id": "saved"}}}`)
+	unlock()
+
+	select {
+	case err := <-loaded:
+		if err != nil {
+			t.Fatalf("failed to load config: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("migration did not take the lock once it was released")
+	}
+
+	// The file was read again under the lock, so the save isn't undone
+	if _, err := os.Stat(path + ".v0.bak"); !os.IsNotExist(err) {
+		t.Errorf("migrated the file again after another process had")
+	}
+	if data := readConfig(t, path); !strings.Contains(data, `"saved"`) {
+		t.Errorf("the other process's save was overwritten:\n%s", data)
+	}
+}
@@ -0,0 +1,250 @@
+//go:build unix || windows
+
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	secretServiceName   = "org.freedesktop.secrets"
+	secretServicePath   = dbus.ObjectPath("/org/freedesktop/secrets")
+	secretDefaultPath   = dbus.ObjectPath("/org/freedesktop/secrets/aliases/default")
+	secretServiceIface  = "org.freedesktop.Secret.Service"
+	secretItemIface     = "org.freedesktop.Secret.Item"
+	secretPromptIface   = "org.freedesktop.Secret.Prompt"
+	secretSchema        = "io.github.austinmusiku.spotifycli"
+	secretPromptTimeout = 2 * time.Minute
+)
+
+// secret mirrors the Secret struct of the Secret Service API
+type secret struct {
+	Session     dbus.ObjectPath
+	Parameters  []byte
+	Value       []byte
+	ContentType string
+}
+
+// SecretServiceStore keeps tokens in the freedesktop Secret Service (GNOME
+// Keyring, KWallet and friends), one item per profile of each config file in
+// the default collection
+type SecretServiceStore struct {
+	conn       *dbus.Conn
+	session    dbus.ObjectPath
+	configPath string
+}
+
+// newSecretServiceStore creates a store for the profiles of the config file
+// at configPath on the given bus connection. A nil connection means the
+// session bus, which honours DBUS_SESSION_BUS_ADDRESS.
+func newSecretServiceStore(conn *dbus.Conn, configPath string) *SecretServiceStore {
+	return &SecretServiceStore{conn: conn, configPath: configPath}
+}
+
+// openSecretServiceStore opens the store on the session bus
+func openSecretServiceStore(configPath string) (TokenStore, error) {
+	return newSecretServiceStore(nil, configPath), nil
+}
+
+func (s *SecretServiceStore) Name() string {
+	return TokenStoreSecretService
+}
+
+func (s *SecretServiceStore) Load(profile string) (Tokens, error) {
+	items, err := s.search(profile)
+	if err != nil {
+		return Tokens{}, err
+	}
+
+	if len(items) == 0 {
+		return Tokens{}, nil
+	}
+
+	var sec secret
+	if err := s.conn.Object(secretServiceName, items[0]).Call(secretItemIface+".GetSecret", 0, s.session).Store(&sec); err != nil {
+		return Tokens{}, fmt.Errorf("failed to read secret: %w", err)
+	}
+
+	var tokens Tokens
+	if err := json.Unmarshal(sec.Value, &tokens); err != nil {
+		return Tokens{}, fmt.Errorf("invalid secret for profile %q: %w", profile, err)
+	}
+
+	return tokens, nil
+}
+
+func (s *SecretServiceStore) Store(profile string, tokens Tokens) error {
+	if err := s.connect(); err != nil {
+		return err
+	}
+
+	if err := s.unlock([]dbus.ObjectPath{secretDefaultPath}); err != nil {
+		return err
+	}
+
+	value, err := json.Marshal(tokens)
+	if err != nil {
+		return err
+	}
+
+	properties := map[string]dbus.Variant{
+		"org.freedesktop.Secret.Item.Label":      dbus.MakeVariant(fmt.Sprintf("spotifycli tokens (%s)", profile)),
+		"org.freedesktop.Secret.Item.Attributes": dbus.MakeVariant(s.attributes(profile)),
+	}
+
+	sec := secret{
+		Session:     s.session,
+		Value:       value,
+		ContentType: "application/json",
+	}
+
+	var item, prompt dbus.ObjectPath
+	err = s.conn.Object(secretServiceName, secretDefaultPath).
+		Call("org.freedesktop.Secret.Collection.CreateItem", 0, properties, sec, true).
+		Store(&item, &prompt)
+	if err != nil {
+		return fmt.Errorf("failed to store secret: %w", err)
+	}
+
+	return s.prompt(prompt)
+}
+
+func (s *SecretServiceStore) Delete(profile string) error {
+	items, err := s.search(profile)
+	if err != nil {
+		return err
+	}
+
+	for _, item := range items {
+		var prompt dbus.ObjectPath
+		if err := s.conn.Object(secretServiceName, item).Call(secretItemIface+".Delete", 0).Store(&prompt); err != nil {
+			return fmt.Errorf("failed to delete secret: %w", err)
+		}
+
+		if err := s.prompt(prompt); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// connect opens the bus connection and a plain-text transfer session. The
+// session bus is local to the user, so secrets never leave the machine.
+func (s *SecretServiceStore) connect() error {
+	if s.session != "" {
+		return nil
+	}
+
+	if s.conn == nil {
+		conn, err := dbus.SessionBus()
+		if err != nil {
+			return fmt.Errorf("failed to connect to the session bus: %w", err)
+		}
+		s.conn = conn
+	}
+
+	var output dbus.Variant
+	err := s.conn.Object(secretServiceName, secretServicePath).
+		Call(secretServiceIface+".OpenSession", 0, "plain", dbus.MakeVariant("")).
+		Store(&output, &s.session)
+	if err != nil {
+		return fmt.Errorf("failed to open secret service session: %w", err)
+	}
+
+	return nil
+}
+
+// search finds the profile's items, unlocking any that are locked
+func (s *SecretServiceStore) search(profile string) ([]dbus.ObjectPath, error) {
+	if err := s.connect(); err != nil {
+		return nil, err
+	}
+
+	var unlocked, locked []dbus.ObjectPath
+	err := s.conn.Object(secretServiceName, secretServicePath).
+		Call(secretServiceIface+".SearchItems", 0, s.attributes(profile)).
+		Store(&unlocked, &locked)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search secrets: %w", err)
+	}
+
+	if len(locked) > 0 {
+		if err := s.unlock(locked); err != nil {
+			return nil, err
+		}
+		unlocked = append(unlocked, locked...)
+	}
+
+	return unlocked, nil
+}
+
+func (s *SecretServiceStore) unlock(objects []dbus.ObjectPath) error {
+	var unlocked []dbus.ObjectPath
+	var prompt dbus.ObjectPath
+	err := s.conn.Object(secretServiceName, secretServicePath).
+		Call(secretServiceIface+".Unlock", 0, objects).
+		Store(&unlocked, &prompt)
+	if err != nil {
+		return fmt.Errorf("failed to unlock keyring: %w", err)
+	}
+
+	return s.prompt(prompt)
+}
+
+// prompt runs a Secret Service prompt, such as a keyring unlock dialog, and
+// waits for the user to complete it. "/" means no prompt is needed.
+func (s *SecretServiceStore) prompt(path dbus.ObjectPath) error {
+	if path == "/" || path == "" {
+		return nil
+	}
+
+	match := []dbus.MatchOption{
+		dbus.WithMatchObjectPath(path),
+		dbus.WithMatchInterface(secretPromptIface),
+		dbus.WithMatchMember("Completed"),
+	}
+	if err := s.conn.AddMatchSignal(match...); err != nil {
+		return fmt.Errorf("failed to watch keyring prompt: %w", err)
+	}
+	defer s.conn.RemoveMatchSignal(match...)
+
+	signals := make(chan *dbus.Signal, 1)
+	s.conn.Signal(signals)
+	defer s.conn.RemoveSignal(signals)
+
+	if err := s.conn.Object(secretServiceName, path).Call(secretPromptIface+".Prompt", 0, "").Err; err != nil {
+		return fmt.Errorf("failed to show keyring prompt: %w", err)
+	}
+
+	timeout := time.After(secretPromptTimeout)
+	for {
+		select {
+		case signal := <-signals:
+			if signal.Path != path || signal.Name != secretPromptIface+".Completed" {
+				continue
+			}
+
+			if len(signal.Body) > 0 && signal.Body[0] == true {
+				return fmt.Errorf("keyring prompt was dismissed")
+			}
+			return nil
+		case <-timeout:
+			return fmt.Errorf("keyring prompt timeout after %v", secretPromptTimeout)
+		}
+	}
+}
+
+// attributes identify the profile's item. Profiles of different config
+// files can share a name, so the file is part of it.
+func (s *SecretServiceStore) attributes(profile string) map[string]string {
+	return map[string]string{
+		"xdg:schema": secretSchema,
+		"config":     s.configPath,
+		"profile":    profile,
+	}
+}
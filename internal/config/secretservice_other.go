@@ -0,0 +1,11 @@
+//go:build !unix && !windows
+
+package config
+
+import "fmt"
+
+// Platforms without D-Bus have no Secret Service
+
+func openSecretServiceStore(configPath string) (TokenStore, error) {
+	return nil, fmt.Errorf("the %s token store is not supported on this platform", TokenStoreSecretService)
+}
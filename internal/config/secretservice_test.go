@@ -0,0 +1,247 @@
+//go:build unix
+
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// secretsStub is a Secret Service provider keeping items in memory. Items
+// start out locked, like those of a keyring that was not unlocked yet, and
+// unlocking never needs a prompt.
+type secretsStub struct {
+	conn *dbus.Conn
+
+	mu       sync.Mutex
+	next     int
+	items    map[dbus.ObjectPath]*stubItem
+	unlocked bool
+}
+
+type stubItem struct {
+	attributes map[string]string
+	value      []byte
+}
+
+// startSecretService runs a private dbus-daemon with a stub secrets provider
+// on it, and returns a connection to the bus along with the stub
+func startSecretService(t *testing.T) (*dbus.Conn, *secretsStub) {
+	t.Helper()
+
+	daemon, err := exec.LookPath("dbus-daemon")
+	if err != nil {
+		t.Skip("dbus-daemon not found")
+	}
+
+	dir := t.TempDir()
+	busConfig := filepath.Join(dir, "bus.conf")
+	err = os.WriteFile(busConfig, []byte(`<!DOCTYPE busconfig PUBLIC "-//freedesktop//DTD D-Bus Bus Configuration 1.0//EN"
+ "http://www.freedesktop.org/standards/dbus/1.0/busconfig.dtd">
+<busconfig>
+  <type>session</type>
+  <listen>unix:dir=`+dir+`</listen>
+  <auth>EXTERNAL</auth>
+  <policy context="default">
+    <allow send_destination="*" eavesdrop="true"/>
+    <allow eavesdrop="true"/>
+    <allow own="*"/>
+  </policy>
+</busconfig>
+`), 0600)
+	if err != nil {
+		t.Fatalf("failed to write bus config: %v", err)
+	}
+
+	cmd := exec.Command(daemon, "--config-file="+busConfig, "--print-address", "--nofork")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatalf("failed to start dbus-daemon: %v", err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("failed to start dbus-daemon: %v", err)
+	}
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+
+	address, err := bufio.NewReader(stdout).ReadString('\n')
+	if err != nil {
+		t.Fatalf("failed to read the bus address: %v", err)
+	}
+	address = strings.TrimSpace(address)
+
+	connect := func() *dbus.Conn {
+		conn, err := dbus.Connect(address)
+		if err != nil {
+			t.Fatalf("failed to connect to the bus: %v", err)
+		}
+		t.Cleanup(func() { conn.Close() })
+		return conn
+	}
+
+	stub := &secretsStub{conn: connect(), items: make(map[dbus.ObjectPath]*stubItem)}
+	if err := stub.conn.Export(stub, secretServicePath, secretServiceIface); err != nil {
+		t.Fatalf("failed to export the service: %v", err)
+	}
+	if err := stub.conn.ExportMethodTable(map[string]any{"CreateItem": stub.CreateItem}, secretDefaultPath, "org.freedesktop.Secret.Collection"); err != nil {
+		t.Fatalf("failed to export the collection: %v", err)
+	}
+
+	reply, err := stub.conn.RequestName(secretServiceName, dbus.NameFlagDoNotQueue)
+	if err != nil || reply != dbus.RequestNameReplyPrimaryOwner {
+		t.Fatalf("failed to own %s: %v", secretServiceName, err)
+	}
+
+	return connect(), stub
+}
+
+func (s *secretsStub) OpenSession(algorithm string, input dbus.Variant) (dbus.Variant, dbus.ObjectPath, *dbus.Error) {
+	if algorithm != "plain" {
+		return dbus.Variant{}, "", dbus.MakeFailedError(fmt.Errorf("unsupported algorithm %s", algorithm))
+	}
+	return dbus.MakeVariant(""), "/org/freedesktop/secrets/session/1", nil
+}
+
+func (s *secretsStub) SearchItems(attributes map[string]string) ([]dbus.ObjectPath, []dbus.ObjectPath, *dbus.Error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var found []dbus.ObjectPath
+	for path, item := range s.items {
+		if maps.Equal(item.attributes, attributes) {
+			found = append(found, path)
+		}
+	}
+
+	if s.unlocked {
+		return found, nil, nil
+	}
+	return nil, found, nil
+}
+
+func (s *secretsStub) Unlock(objects []dbus.ObjectPath) ([]dbus.ObjectPath, dbus.ObjectPath, *dbus.Error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.unlocked = true
+	return objects, "/", nil
+}
+
+func (s *secretsStub) CreateItem(properties map[string]dbus.Variant, sec secret, replace bool) (dbus.ObjectPath, dbus.ObjectPath, *dbus.Error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	attributes, ok := properties["org.freedesktop.Secret.Item.Attributes"].Value().(map[string]string)
+	if !ok {
+		return "", "", dbus.MakeFailedError(fmt.Errorf("item has no attributes"))
+	}
+
+	if replace {
+		for path, item := range s.items {
+			if maps.Equal(item.attributes, attributes) {
+				item.value = sec.Value
+				return path, "/", nil
+			}
+		}
+	}
+
+	s.next++
+	path := dbus.ObjectPath(fmt.Sprintf("/org/freedesktop/secrets/collection/login/%d", s.next))
+	item := &stubItem{attributes: attributes, value: sec.Value}
+
+	methods := map[string]any{
+		"GetSecret": func(session dbus.ObjectPath) (secret, *dbus.Error) {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			return secret{Session: session, Value: item.value, ContentType: "application/json"}, nil
+		},
+		"Delete": func() (dbus.ObjectPath, *dbus.Error) {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			delete(s.items, path)
+			s.conn.Export(nil, path, secretItemIface)
+			return "/", nil
+		},
+	}
+	if err := s.conn.ExportMethodTable(methods, path, secretItemIface); err != nil {
+		return "", "", dbus.MakeFailedError(err)
+	}
+
+	s.items[path] = item
+	return path, "/", nil
+}
+
+// itemCount returns how many items the stub holds
+func (s *secretsStub) itemCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.items)
+}
+
+func TestSecretServiceStore(t *testing.T) {
+	conn, stub := startSecretService(t)
+
+	// Two config files, both with a default profile
+	work := newSecretServiceStore(conn, "/home/user/.config/work.json")
+	home := newSecretServiceStore(conn, "/home/user/.config/spotifycli.json")
+
+	if tokens, err := work.Load(DefaultProfile); err != nil || !tokens.IsZero() {
+		t.Fatalf("Load before Store = %+v, %v, want no tokens", tokens, err)
+	}
+
+	workTokens := Tokens{AccessToken: "work-access", RefreshToken: "work-refresh", TokenType: "Bearer", TokenExpiry: 1700000000, Scope: "user-read-private"}
+	if err := work.Store(DefaultProfile, workTokens); err != nil {
+		t.Fatalf("failed to store tokens: %v", err)
+	}
+	if tokens, err := work.Load(DefaultProfile); err != nil || tokens != workTokens {
+		t.Errorf("Load = %+v, %v, want %+v", tokens, err, workTokens)
+	}
+
+	// The items are keyed by config file, so the other file sees none
+	if tokens, err := home.Load(DefaultProfile); err != nil || !tokens.IsZero() {
+		t.Errorf("Load from another config file = %+v, %v, want no tokens", tokens, err)
+	}
+
+	homeTokens := Tokens{AccessToken: "home-access", RefreshToken: "home-refresh", TokenType: "Bearer"}
+	if err := home.Store(DefaultProfile, homeTokens); err != nil {
+		t.Fatalf("failed to store tokens: %v", err)
+	}
+
+	// Storing again replaces the item rather than adding one
+	workTokens.AccessToken = "work-access-2"
+	if err := work.Store(DefaultProfile, workTokens); err != nil {
+		t.Fatalf("failed to store tokens: %v", err)
+	}
+	if n := stub.itemCount(); n != 2 {
+		t.Errorf("the keyring holds %d items, want 2", n)
+	}
+	if tokens, err := work.Load(DefaultProfile); err != nil || tokens != workTokens {
+		t.Errorf("Load after replacing = %+v, %v, want %+v", tokens, err, workTokens)
+	}
+
+	if err := work.Delete(DefaultProfile); err != nil {
+		t.Fatalf("failed to delete tokens: %v", err)
+	}
+	if tokens, err := work.Load(DefaultProfile); err != nil || !tokens.IsZero() {
+		t.Errorf("Load after Delete = %+v, %v, want no tokens", tokens, err)
+	}
+	if tokens, err := home.Load(DefaultProfile); err != nil || tokens != homeTokens {
+		t.Errorf("Load from the other config file after Delete = %+v, %v, want %+v", tokens, err, homeTokens)
+	}
+
+	// Deleting what isn't there is fine
+	if err := work.Delete(DefaultProfile); err != nil {
+		t.Errorf("failed to delete missing tokens: %v", err)
+	}
+}
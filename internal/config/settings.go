@@ -0,0 +1,372 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AustinMusiku/spotifycli/internal/auth"
+)
+
+// Where a setting's value came from
+const (
+	SourceDefault = "default"
+	SourceFile    = "file"
+	SourceEnv     = "env"
+	SourceFlag    = "flag"
+)
+
+// Setting describes a profile setting that can be viewed and changed
+type Setting struct {
+	Key         string
+	Description string
+	// Secret settings are masked unless asked for explicitly
+	Secret bool
+}
+
+// setting is the schema of a profile setting: how to read, write and
+// validate it, and its default
+type setting struct {
+	Setting
+	fallback string
+	get      func(c *Config) string
+	set      func(c *Config, value string)
+	validate func(value string) error
+	// typed settings are validated as the config file is read, since they
+	// are used without checking them again
+	typed bool
+}
+
+// env returns the environment variable overriding the setting
+func (s setting) env() string {
+	return "SPOTIFYCLI_" + strings.ToUpper(s.Key)
+}
+
+func stringSetting(key, description, fallback string, field func(c *Config) *string, validate func(string) error) setting {
+	return setting{
+		Setting:  Setting{Key: key, Description: description},
+		fallback: fallback,
+		get:      func(c *Config) string { return *field(c) },
+		set:      func(c *Config, value string) { *field(c) = value },
+		validate: validate,
+	}
+}
+
+// intSetting is a whole number setting, unset while the field is nil. The
+// value is validated before it is set.
+func intSetting(key, description, fallback string, field func(c *Config) **int, validate func(string) error) setting {
+	return setting{
+		Setting:  Setting{Key: key, Description: description},
+		fallback: fallback,
+		get: func(c *Config) string {
+			if n := *field(c); n != nil {
+				return strconv.Itoa(*n)
+			}
+			return ""
+		},
+		set: func(c *Config, value string) {
+			*field(c) = nil
+			if n, err := strconv.Atoi(value); err == nil {
+				*field(c) = &n
+			}
+		},
+		validate: validate,
+		typed:    true,
+	}
+}
+
+// durationSetting is a duration setting, unset while the field is nil. The
+// value is validated before it is set.
+func durationSetting(key, description, fallback string, field func(c *Config) **Duration, validate func(string) error) setting {
+	return setting{
+		Setting:  Setting{Key: key, Description: description},
+		fallback: fallback,
+		get: func(c *Config) string {
+			if d := *field(c); d != nil {
+				return d.String()
+			}
+			return ""
+		},
+		set: func(c *Config, value string) {
+			*field(c) = nil
+			if d, err := time.ParseDuration(value); err == nil {
+				*field(c) = (*Duration)(&d)
+			}
+		},
+		validate: validate,
+		typed:    true,
+	}
+}
+
+// Duration is a time.Duration written to the config file as a string such
+// as "45s"
+type Duration time.Duration
+
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return fmt.Errorf("duration must be a string such as \"30s\"")
+	}
+
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// masked marks a setting as a secret
+func masked(s setting) setting {
+	s.Secret = true
+	return s
+}
+
+var settings = []setting{
+	stringSetting("client_id", "Client ID of your Spotify app", "",
+		func(c *Config) *string { return &c.ClientID }, nil),
+	masked(stringSetting("client_secret", "Client secret, for app-only search without a login", "",
+		func(c *Config) *string { return &c.ClientSecret }, nil)),
+	stringSetting("redirect_path", "Path of the login callback on 127.0.0.1", "callback",
+		func(c *Config) *string { return &c.RedirectPath }, validateRedirectPath),
+	stringSetting("port", "Port or range of ports for the login callback, e.g. 8080-8089", "8080",
+		func(c *Config) *string { return &c.Port }, validatePort),
+	stringSetting("default_device", "Device name or ID to play on when none is active", "",
+		func(c *Config) *string { return &c.DefaultDevice }, nil),
+	{
+		Setting: Setting{Key: "scopes", Description: "Comma separated scopes to request at login"},
+		get:     func(c *Config) string { return strings.Join(c.Scopes, ",") },
+		set: func(c *Config, value string) {
+			c.Scopes = splitScopes(value)
+		},
+		validate: func(value string) error {
+			return auth.ValidateScopes(splitScopes(value))
+		},
+	},
+	stringSetting("accounts_url", "Base URL of the accounts service", "",
+		func(c *Config) *string { return &c.AccountsURL }, validateHTTPURL),
+	stringSetting("api_url", "Base URL of the Web API", "",
+		func(c *Config) *string { return &c.APIURL }, validateHTTPURL),
+	durationSetting("timeout", "Per-request timeout as a Go duration, e.g. 45s", DefaultTimeout.String(),
+		func(c *Config) **Duration { return &c.Timeout }, validateTimeout),
+	intSetting("max_retries", "Retries of a rate limited or failed request, 0 to disable", strconv.Itoa(DefaultMaxRetries),
+		func(c *Config) **int { return &c.MaxRetries }, validateRetries),
+	durationSetting("retry_budget", "Longest time to spend waiting to retry a request, e.g. 1m", DefaultRetryBudget.String(),
+		func(c *Config) **Duration { return &c.RetryBudget }, validateBudget),
+	intSetting("cache_size", "Size cap of the response cache in MB, 0 to disable it", strconv.Itoa(DefaultCacheSize),
+		func(c *Config) **int { return &c.CacheSize }, validateCacheSize),
+	stringSetting("proxy", "Proxy URL", "",
+		func(c *Config) *string { return &c.Proxy }, validateProxy),
+	stringSetting("ca_bundle", "PEM file of extra CAs to trust", "",
+		func(c *Config) *string { return &c.CABundle }, validateFile),
+	stringSetting("user_agent", "User-Agent header", "",
+		func(c *Config) *string { return &c.UserAgent }, nil),
+}
+
+func lookupSetting(key string) (setting, bool) {
+	for _, s := range settings {
+		if s.Key == key {
+			return s, true
+		}
+	}
+	return setting{}, false
+}
+
+// Settings returns every profile setting in display order
+func Settings() []Setting {
+	list := make([]Setting, len(settings))
+	for i, s := range settings {
+		list[i] = s.Setting
+	}
+	return list
+}
+
+// LookupSetting returns the named setting, or an error for an unknown key
+func LookupSetting(key string) (Setting, error) {
+	s, ok := lookupSetting(key)
+	if !ok {
+		return Setting{}, fmt.Errorf("unknown setting: %s", key)
+	}
+	return s.Setting, nil
+}
+
+// validate checks the typed settings of a profile as read from the config
+// file, which may have been edited by hand
+func (c *Config) validate() error {
+	for _, s := range settings {
+		if !s.typed || s.validate == nil {
+			continue
+		}
+		if value := s.get(c); value != "" {
+			if err := s.validate(value); err != nil {
+				return fmt.Errorf("invalid value for %s: %w", s.Key, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Get returns the value of a setting after layering and the layer it came
+// from. An unset setting has an empty value and source.
+func (c *Config) Get(key string) (value, source string, err error) {
+	s, ok := lookupSetting(key)
+	if !ok {
+		return "", "", fmt.Errorf("unknown setting: %s", key)
+	}
+
+	value = s.get(c)
+	if o, ok := c.overrides[key]; ok && o.value == value {
+		return value, o.source, nil
+	}
+	if value != "" {
+		source = SourceFile
+	}
+	return value, source, nil
+}
+
+// FileValue returns the value of a setting as stored in the config file
+func (c *Config) FileValue(key string) (string, error) {
+	s, ok := lookupSetting(key)
+	if !ok {
+		return "", fmt.Errorf("unknown setting: %s", key)
+	}
+
+	if o, ok := c.overrides[key]; ok && o.value == s.get(c) {
+		return o.file, nil
+	}
+	return s.get(c), nil
+}
+
+// Set validates a value and stores it in the profile, to be written to the
+// config file on Save
+func (c *Config) Set(key, value string) error {
+	s, ok := lookupSetting(key)
+	if !ok {
+		return fmt.Errorf("unknown setting: %s", key)
+	}
+
+	if s.validate != nil {
+		if err := s.validate(value); err != nil {
+			return fmt.Errorf("invalid value for %s: %w", key, err)
+		}
+	}
+
+	s.set(c, value)
+	delete(c.overrides, key)
+	return nil
+}
+
+// Unset removes a setting from the profile so its default applies again
+func (c *Config) Unset(key string) error {
+	s, ok := lookupSetting(key)
+	if !ok {
+		return fmt.Errorf("unknown setting: %s", key)
+	}
+
+	s.set(c, "")
+	delete(c.overrides, key)
+	return nil
+}
+
+// EnvOverride returns the environment variable overriding a setting, if it is set
+func EnvOverride(key string) (string, bool) {
+	s, ok := lookupSetting(key)
+	if !ok || os.Getenv(s.env()) == "" {
+		return "", false
+	}
+	return s.env(), true
+}
+
+func splitScopes(value string) []string {
+	return strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ' ' })
+}
+
+var redirectPathPattern = regexp.MustCompile(`^/?[A-Za-z0-9._~-]+(/[A-Za-z0-9._~-]+)*$`)
+
+func validateRedirectPath(value string) error {
+	if !redirectPathPattern.MatchString(value) {
+		return fmt.Errorf("must be a URL path such as callback")
+	}
+	return nil
+}
+
+func validatePort(value string) error {
+	_, _, err := auth.ParsePortRange(value)
+	return err
+}
+
+func validateHTTPURL(value string) error {
+	u, err := url.Parse(value)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("must be an http or https URL")
+	}
+	return nil
+}
+
+func validateProxy(value string) error {
+	u, err := url.Parse(value)
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("must be a URL such as http://proxy:3128")
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+		return nil
+	}
+	return fmt.Errorf("unsupported proxy scheme: %s", u.Scheme)
+}
+
+func validateTimeout(value string) error {
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		return fmt.Errorf("must be a positive duration such as 30s")
+	}
+	return nil
+}
+
+func validateRetries(value string) error {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return fmt.Errorf("must be a whole number, 0 or more")
+	}
+	return nil
+}
+
+func validateBudget(value string) error {
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return fmt.Errorf("must be a duration such as 1m, or 0 to disable retries")
+	}
+	return nil
+}
+
+func validateCacheSize(value string) error {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 || n > 1<<20 {
+		return fmt.Errorf("must be a size in MB such as 50, or 0 to disable the cache")
+	}
+	return nil
+}
+
+func validateFile(value string) error {
+	info, err := os.Stat(value)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return fmt.Errorf("%s is a directory", value)
+	}
+	return nil
+}
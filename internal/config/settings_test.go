@@ -0,0 +1,100 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHTTPSettingsDefaults(t *testing.T) {
+	useTempConfig(t)
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+
+	if cfg.RequestTimeout() != DefaultTimeout || cfg.RetryLimit() != DefaultMaxRetries || cfg.RetryWait() != DefaultRetryBudget {
+		t.Errorf("timeout %s, retries %d, budget %s, want the defaults", cfg.RequestTimeout(), cfg.RetryLimit(), cfg.RetryWait())
+	}
+	if cfg.CacheMaxSize() != 50<<20 {
+		t.Errorf("cache size %d, want the default 50MB", cfg.CacheMaxSize())
+	}
+
+	value, source, err := cfg.Get("timeout")
+	if err != nil || value != "30s" || source != SourceDefault {
+		t.Errorf("Get(timeout) = %q, %q, %v, want the default 30s", value, source, err)
+	}
+}
+
+func TestHTTPSettingsFromFile(t *testing.T) {
+	path := useTempConfig(t)
+	writeConfig(t, path, `{"version": 1, "active_profile": "default", "profiles": {"default": {"timeout": "45s", "max_retries": 0, "retry_budget": "1m", "cache_size": 0}}}`)
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+
+	// Zero retries is a setting of its own, not the default
+	if cfg.RequestTimeout() != 45*time.Second || cfg.RetryLimit() != 0 || cfg.RetryWait() != time.Minute {
+		t.Errorf("timeout %s, retries %d, budget %s, want 45s, 0 and 1m", cfg.RequestTimeout(), cfg.RetryLimit(), cfg.RetryWait())
+	}
+	if cfg.CacheMaxSize() != 0 {
+		t.Errorf("cache size %d, want the cache disabled", cfg.CacheMaxSize())
+	}
+
+	// Values from the environment and flags are typed the same way
+	t.Setenv("SPOTIFYCLI_TIMEOUT", "5s")
+	if err := SetFlagValues([]string{"max_retries=2", "retry_budget=0"}); err != nil {
+		t.Fatalf("failed to set flag values: %v", err)
+	}
+
+	cfg, err = LoadConfig()
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	if cfg.RequestTimeout() != 5*time.Second || cfg.RetryLimit() != 0 || cfg.RetryWait() != 0 {
+		t.Errorf("timeout %s, retries %d, budget %s, want 5s and no retries", cfg.RequestTimeout(), cfg.RetryLimit(), cfg.RetryWait())
+	}
+
+	// and are not written back to the file
+	if err := cfg.Set("timeout", "2m"); err != nil {
+		t.Fatalf("failed to set timeout: %v", err)
+	}
+	if err := cfg.Save(); err != nil {
+		t.Fatalf("failed to save config: %v", err)
+	}
+
+	data := readConfig(t, path)
+	for _, want := range []string{`"timeout": "2m0s"`, `"max_retries": 0`, `"retry_budget": "1m0s"`, `"cache_size": 0`} {
+		if !strings.Contains(data, want) {
+			t.Errorf("config file does not contain %s:\n%s", want, data)
+		}
+	}
+}
+
+func TestHTTPSettingsValidatedAtLoad(t *testing.T) {
+	tests := []struct {
+		profile string
+		wantErr string
+	}{
+		{`{"timeout": "0s"}`, "invalid value for timeout"},
+		{`{"timeout": "soon"}`, `invalid duration "soon"`},
+		{`{"timeout": 45}`, "duration must be a string"},
+		{`{"max_retries": -1}`, "invalid value for max_retries"},
+		{`{"max_retries": "3"}`, "max_retries"},
+		{`{"retry_budget": "-1s"}`, "invalid value for retry_budget"},
+		{`{"cache_size": -5}`, "invalid value for cache_size"},
+	}
+
+	for _, tt := range tests {
+		path := useTempConfig(t)
+		writeConfig(t, path, `{"version": 1, "active_profile": "default", "profiles": {"default": `+tt.profile+`}}`)
+
+		_, err := LoadFile()
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("loading %s: error %v, want %q", tt.profile, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+)
+
+const (
+	// TokenStoreFile keeps tokens encrypted in the config file
+	TokenStoreFile = "file"
+	// TokenStoreSecretService keeps tokens in the freedesktop Secret Service keyring
+	TokenStoreSecretService = "secret-service"
+	// TokenStoreMemory keeps tokens in memory for the life of the process, for tests
+	TokenStoreMemory = "memory"
+)
+
+// Tokens is the OAuth token set of a profile
+type Tokens struct {
+	AccessToken  string `json:"access_token,omitempty"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	TokenType    string `json:"token_type,omitempty"`
+	TokenExpiry  int64  `json:"token_expiry,omitempty"`
+	// Scope is the space separated list of scopes the tokens were granted
+	Scope string `json:"scope,omitempty"`
+}
+
+// IsZero reports whether no tokens are set
+func (t Tokens) IsZero() bool {
+	return t == Tokens{}
+}
+
+// TokenStore persists the tokens of each profile
+type TokenStore interface {
+	// Name identifies the store in the config file
+	Name() string
+	// Load returns the profile's tokens, or zero Tokens if none are stored
+	Load(profile string) (Tokens, error)
+	// Store saves the profile's tokens, replacing any existing ones
+	Store(profile string, tokens Tokens) error
+	// Delete removes the profile's tokens
+	Delete(profile string) error
+}
+
+// memoryStoreAllowed lets this package's tests select the memory store
+var memoryStoreAllowed = false
+
+// ValidateTokenStore checks that name is a token store the config file may
+// use. The memory store forgets the tokens when the process exits, so it is
+// refused outside tests.
+func ValidateTokenStore(name string) error {
+	switch name {
+	case TokenStoreFile, TokenStoreSecretService:
+		return nil
+	case TokenStoreMemory:
+		if memoryStoreAllowed {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown token store: %s (must be '%s' or '%s')", name, TokenStoreFile, TokenStoreSecretService)
+}
+
+// openTokenStore returns the named token store for the given file
+func openTokenStore(f *File, name string) (TokenStore, error) {
+	if err := ValidateTokenStore(name); err != nil {
+		return nil, err
+	}
+
+	switch name {
+	case TokenStoreFile:
+		return newFileTokenStore(f), nil
+	case TokenStoreSecretService:
+		path, err := getConfigPath()
+		if err != nil {
+			return nil, err
+		}
+		path, err = filepath.Abs(path)
+		if err != nil {
+			return nil, err
+		}
+		return openSecretServiceStore(path)
+	case TokenStoreMemory:
+		return memoryStore, nil
+	default:
+		return nil, fmt.Errorf("unknown token store: %s", name)
+	}
+}
+
+// fileTokenStore keeps tokens encrypted inside the config file itself
+type fileTokenStore struct {
+	file      *File
+	encrypted map[string]Tokens
+}
+
+// newFileTokenStore takes the still-encrypted tokens as read from the config file
+func newFileTokenStore(f *File) *fileTokenStore {
+	s := &fileTokenStore{
+		file:      f,
+		encrypted: make(map[string]Tokens, len(f.Profiles)),
+	}
+
+	for name, profile := range f.Profiles {
+		if !profile.Tokens.IsZero() {
+			s.encrypted[name] = profile.Tokens
+		}
+	}
+
+	return s
+}
+
+func (s *fileTokenStore) Name() string {
+	return TokenStoreFile
+}
+
+func (s *fileTokenStore) Load(profile string) (Tokens, error) {
+	tokens := s.encrypted[profile]
+	if tokens.AccessToken == "" && tokens.RefreshToken == "" {
+		return tokens, nil
+	}
+
+	key, err := s.file.encryptionKey()
+	if err != nil {
+		return Tokens{}, err
+	}
+
+	if tokens.AccessToken != "" {
+		plain, err := DecryptToken(tokens.AccessToken, key)
+		if err != nil {
+			return Tokens{}, decryptError(profile, s.file.KeySourceName(), err)
+		}
+		tokens.AccessToken = plain
+	}
+
+	if tokens.RefreshToken != "" {
+		plain, err := DecryptToken(tokens.RefreshToken, key)
+		if err != nil {
+			return Tokens{}, decryptError(profile, s.file.KeySourceName(), err)
+		}
+		tokens.RefreshToken = plain
+	}
+
+	return tokens, nil
+}
+
+func (s *fileTokenStore) Store(profile string, tokens Tokens) error {
+	key, err := s.file.encryptionKey()
+	if err != nil {
+		return err
+	}
+
+	if tokens.AccessToken != "" {
+		enc, err := EncryptToken(tokens.AccessToken, key)
+		if err != nil {
+			return err
+		}
+		tokens.AccessToken = enc
+	}
+
+	if tokens.RefreshToken != "" {
+		enc, err := EncryptToken(tokens.RefreshToken, key)
+		if err != nil {
+			return err
+		}
+		tokens.RefreshToken = enc
+	}
+
+	s.encrypted[profile] = tokens
+	return nil
+}
+
+func (s *fileTokenStore) Delete(profile string) error {
+	delete(s.encrypted, profile)
+	return nil
+}
+
+// memoryStore is shared so tokens survive reloading the config within a process
+var memoryStore = NewMemoryTokenStore()
+
+// MemoryTokenStore keeps plaintext tokens in memory
+type MemoryTokenStore struct {
+	mu     sync.Mutex
+	tokens map[string]Tokens
+}
+
+// NewMemoryTokenStore creates an empty in-memory token store
+func NewMemoryTokenStore() *MemoryTokenStore {
+	return &MemoryTokenStore{tokens: make(map[string]Tokens)}
+}
+
+func (s *MemoryTokenStore) Name() string {
+	return TokenStoreMemory
+}
+
+func (s *MemoryTokenStore) Load(profile string) (Tokens, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.tokens[profile], nil
+}
+
+func (s *MemoryTokenStore) Store(profile string, tokens Tokens) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens[profile] = tokens
+	return nil
+}
+
+func (s *MemoryTokenStore) Delete(profile string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.tokens, profile)
+	return nil
+}
@@ -0,0 +1,100 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateTokenStore(t *testing.T) {
+	tests := []struct {
+		name    string
+		allowed bool
+		wantErr bool
+	}{
+		{TokenStoreFile, false, false},
+		{TokenStoreSecretService, false, false},
+		{TokenStoreMemory, false, true},
+		{TokenStoreMemory, true, false},
+		{"keychain", true, true},
+	}
+
+	for _, tt := range tests {
+		memoryStoreAllowed = tt.allowed
+		err := ValidateTokenStore(tt.name)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ValidateTokenStore(%q) with memory allowed %t: error %v, want error %t", tt.name, tt.allowed, err, tt.wantErr)
+		}
+	}
+	memoryStoreAllowed = false
+}
+
+func TestMemoryTokenStoreRefusedInFile(t *testing.T) {
+	path := useTempConfig(t)
+	writeConfig(t, path, `{"version": 1, "active_profile": "default", "token_store": "memory", "profiles": {"default": {}}}`)
+
+	_, err := LoadFile()
+	if err == nil || !strings.Contains(err.Error(), "unknown token store: memory") {
+		t.Fatalf("LoadFile error %v, want the memory store refused", err)
+	}
+}
+
+func TestSwitchTokenStore(t *testing.T) {
+	path := useTempConfig(t)
+	memoryStoreAllowed = true
+	t.Cleanup(func() { memoryStoreAllowed = false })
+
+	f := saveLogin(t, "access-secret", "refresh-secret")
+
+	if err := f.SwitchTokenStore(TokenStoreMemory); err != nil {
+		t.Fatalf("failed to switch token store: %v", err)
+	}
+	if err := f.Save(); err != nil {
+		t.Fatalf("failed to save config: %v", err)
+	}
+
+	// The tokens have left the config file for the new store
+	data := readConfig(t, path)
+	if strings.Contains(data, "access_token") || strings.Contains(data, "refresh_token") {
+		t.Errorf("tokens still in the config file:\n%s", data)
+	}
+	if !strings.Contains(data, `"token_store": "memory"`) {
+		t.Errorf("config file does not name the memory store:\n%s", data)
+	}
+	if tokens := loadTokens(t); tokens.AccessToken != "access-secret" || tokens.RefreshToken != "refresh-secret" {
+		t.Errorf("loaded tokens %+v, want those saved before the switch", tokens)
+	}
+
+	// and come back when switching back
+	f, err := LoadFile()
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	if err := f.SwitchTokenStore(TokenStoreFile); err != nil {
+		t.Fatalf("failed to switch token store: %v", err)
+	}
+	if err := f.Save(); err != nil {
+		t.Fatalf("failed to save config: %v", err)
+	}
+
+	if tokens, _ := memoryStore.Load(DefaultProfile); !tokens.IsZero() {
+		t.Errorf("memory store still holds %+v after switching away", tokens)
+	}
+	if data := readConfig(t, path); strings.Contains(data, "refresh-secret") || !strings.Contains(data, "refresh_token") {
+		t.Errorf("config file does not hold the encrypted tokens:\n%s", data)
+	}
+	if tokens := loadTokens(t); tokens.RefreshToken != "refresh-secret" {
+		t.Errorf("loaded tokens %+v, want those saved before the switch", tokens)
+	}
+}
+
+func TestSwitchTokenStoreRefusesMemory(t *testing.T) {
+	useTempConfig(t)
+
+	f := saveLogin(t, "access-secret", "refresh-secret")
+	if err := f.SwitchTokenStore(TokenStoreMemory); err == nil {
+		t.Fatalf("switched to the memory store outside tests")
+	}
+	if f.TokenStoreName() != TokenStoreFile {
+		t.Errorf("token store is %s after a refused switch, want file", f.TokenStoreName())
+	}
+}
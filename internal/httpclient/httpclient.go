@@ -0,0 +1,139 @@
+package httpclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/AustinMusiku/spotifycli/internal/version"
+)
+
+// DefaultTimeout is used when no timeout is configured
+const DefaultTimeout = 30 * time.Second
+
+// Options configures the HTTP client used to reach Spotify
+type Options struct {
+	// Timeout bounds each attempt of a request, DefaultTimeout when zero
+	Timeout time.Duration
+	// MaxRetries limits how often a failed request is retried, DefaultMaxRetries
+	// when zero; negative disables retrying
+	MaxRetries int
+	// RetryBudget bounds the time spent waiting between attempts of a request,
+	// DefaultRetryBudget when zero
+	RetryBudget time.Duration
+	// Debug receives a trace of each request and retry when set, with
+	// credentials redacted
+	Debug io.Writer
+	// DebugBodies adds the headers and bodies to the trace
+	DebugBodies bool
+	// Proxy is the proxy URL; when empty HTTPS_PROXY, HTTP_PROXY and NO_PROXY are honoured
+	Proxy string
+	// CABundle is a PEM file of extra trusted CAs, for corporate TLS interception
+	CABundle string
+	// UserAgent replaces the default spotifycli/<version> User-Agent
+	UserAgent string
+}
+
+// New creates an HTTP client from the options
+func New(opts Options) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyFromEnvironment
+
+	if opts.Proxy != "" {
+		proxyURL, err := url.Parse(opts.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy URL: %w", err)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	if opts.CABundle != "" {
+		pool, err := loadCABundle(opts.CABundle)
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+
+	userAgent := opts.UserAgent
+	if userAgent == "" {
+		userAgent = "spotifycli/" + version.Version
+	}
+
+	timeout := opts.Timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+
+	maxRetries := opts.MaxRetries
+	if maxRetries == 0 {
+		maxRetries = DefaultMaxRetries
+	}
+
+	retryBudget := opts.RetryBudget
+	if retryBudget == 0 {
+		retryBudget = DefaultRetryBudget
+	}
+
+	var base http.RoundTripper = transport
+	if opts.Debug != nil {
+		// Traced below the retries so each attempt is logged
+		base = &traceTransport{
+			base:   transport,
+			out:    opts.Debug,
+			bodies: opts.DebugBodies,
+		}
+	}
+
+	// The timeout is applied per attempt by the retry transport, so waiting
+	// out a rate limit doesn't count against it
+	return &http.Client{
+		Transport: &retryTransport{
+			base: &userAgentTransport{
+				base:      base,
+				userAgent: userAgent,
+			},
+			timeout:    timeout,
+			maxRetries: max(maxRetries, 0),
+			budget:     retryBudget,
+			debug:      opts.Debug,
+		},
+	}, nil
+}
+
+// loadCABundle adds the CAs in the PEM file to the system pool
+func loadCABundle(path string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in CA bundle %s", path)
+	}
+
+	return pool, nil
+}
+
+// userAgentTransport sets the User-Agent on every request
+type userAgentTransport struct {
+	base      http.RoundTripper
+	userAgent string
+}
+
+func (t *userAgentTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// RoundTrippers must not modify the caller's request
+	req = req.Clone(req.Context())
+	req.Header.Set("User-Agent", t.userAgent)
+	return t.base.RoundTrip(req)
+}
@@ -0,0 +1,220 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// DefaultMaxRetries is used when no retry limit is configured
+	DefaultMaxRetries = 4
+	// DefaultRetryBudget is used when no retry budget is configured
+	DefaultRetryBudget = 30 * time.Second
+
+	baseRetryDelay = 500 * time.Millisecond
+	maxRetryDelay  = 8 * time.Second
+)
+
+// retryTransport retries requests that were rate limited (429) after the
+// Retry-After delay, and those that failed with a 5xx or a transient network
+// error after a jittered exponential backoff. The time spent waiting is
+// bounded by the budget, and each attempt gets its own timeout.
+type retryTransport struct {
+	base       http.RoundTripper
+	timeout    time.Duration
+	maxRetries int
+	budget     time.Duration
+	debug      io.Writer
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var waited time.Duration
+
+	for attempt := 0; ; attempt++ {
+		resp, err := t.attempt(req)
+
+		delay, reason, retry := t.retryDelay(req, resp, err, attempt)
+		if !retry {
+			return resp, err
+		}
+
+		if attempt >= t.maxRetries {
+			t.logf("giving up on %s %s after %d attempt(s): %s", req.Method, req.URL.Path, attempt+1, reason)
+			return resp, err
+		}
+
+		if waited+delay > t.budget {
+			t.logf("giving up on %s %s: %s, waiting %s more would exceed the %s retry budget", req.Method, req.URL.Path, reason, delay.Round(time.Millisecond), t.budget)
+			return resp, err
+		}
+
+		retryReq, cloneErr := replay(req)
+		if cloneErr != nil {
+			return resp, err
+		}
+
+		if resp != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		t.logf("retrying %s %s in %s: %s (retry %d of %d)", req.Method, req.URL.Path, delay.Round(time.Millisecond), reason, attempt+1, t.maxRetries)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+
+		waited += delay
+		req = retryReq
+	}
+}
+
+// attempt sends the request once, bounded by the per-attempt timeout
+func (t *retryTransport) attempt(req *http.Request) (*http.Response, error) {
+	if t.timeout <= 0 {
+		return t.base.RoundTrip(req)
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), t.timeout)
+	resp, err := t.base.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	// The timeout keeps applying while the body is read
+	resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// retryDelay decides whether a failed attempt is worth retrying, and how long
+// to wait first
+func (t *retryTransport) retryDelay(req *http.Request, resp *http.Response, err error, attempt int) (time.Duration, string, bool) {
+	// The caller gave up, or the request can't be sent again
+	if req.Context().Err() != nil || (req.Body != nil && req.Body != http.NoBody && req.GetBody == nil) {
+		return 0, "", false
+	}
+
+	if err != nil {
+		if !idempotent(req.Method) || !transient(err) {
+			return 0, "", false
+		}
+		return backoff(attempt), err.Error(), true
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		// Rate limited requests were not processed, so any method can be retried
+		if delay, ok := retryAfter(resp); ok {
+			return delay, "rate limited", true
+		}
+		return backoff(attempt), "rate limited", true
+
+	case resp.StatusCode >= 500 && resp.StatusCode != http.StatusNotImplemented:
+		// A POST, such as skipping a track, may have taken effect already
+		if !idempotent(req.Method) {
+			return 0, "", false
+		}
+		if delay, ok := retryAfter(resp); ok {
+			return delay, resp.Status, true
+		}
+		return backoff(attempt), resp.Status, true
+	}
+
+	return 0, "", false
+}
+
+func (t *retryTransport) logf(format string, args ...any) {
+	if t.debug != nil {
+		fmt.Fprintf(t.debug, "[http] "+format+"\n", args...)
+	}
+}
+
+// backoff returns the jittered exponential delay before the given retry
+func backoff(attempt int) time.Duration {
+	delay := maxRetryDelay
+	if attempt < 5 {
+		delay = min(baseRetryDelay<<attempt, maxRetryDelay)
+	}
+
+	// Between half and all of the delay, so clients don't retry in lockstep
+	return delay/2 + rand.N(delay/2+1)
+}
+
+// retryAfter parses the Retry-After header, given in seconds or as a date
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	value := resp.Header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		return max(time.Until(date), 0), true
+	}
+
+	return 0, false
+}
+
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// transient reports whether a network error is likely to go away on retry
+func transient(err error) bool {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && connectionLost(opErr) {
+		return true
+	}
+
+	return errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, io.EOF)
+}
+
+// replay clones the request with a fresh body for another attempt
+func replay(req *http.Request) (*http.Request, error) {
+	retry := req.Clone(req.Context())
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		retry.Body = body
+	}
+	return retry, nil
+}
+
+// cancelBody releases the attempt's context once the body is closed
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
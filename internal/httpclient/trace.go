@@ -0,0 +1,134 @@
+package httpclient
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// maxTracedBody is the most of a body written to the trace
+const maxTracedBody = 4 << 10
+
+// secretParams are the query, form and JSON fields whose values are never traced
+var secretParams = []string{"access_token", "refresh_token", "client_secret", "code", "code_verifier", "id_token"}
+
+var secretJSONField = regexp.MustCompile(`("(?:` + strings.Join(secretParams, "|") + `)"\s*:\s*)"[^"]*"`)
+
+// traceTransport writes a line for each request with its status and latency,
+// and optionally the headers and bodies, with credentials redacted
+type traceTransport struct {
+	base   http.RoundTripper
+	out    io.Writer
+	bodies bool
+}
+
+func (t *traceTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+
+	if t.bodies {
+		fmt.Fprintf(t.out, "[http] > %s %s\n", req.Method, redactURL(req.URL))
+		t.traceHeaders(">", req.Header)
+		if req.GetBody != nil {
+			if body, err := req.GetBody(); err == nil {
+				data, _ := io.ReadAll(body)
+				body.Close()
+				t.traceBody(">", req.Header.Get("Content-Type"), data)
+			}
+		}
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	elapsed := time.Since(start).Round(time.Millisecond)
+
+	if err != nil {
+		fmt.Fprintf(t.out, "[http] %s %s failed after %s: %v\n", req.Method, redactURL(req.URL), elapsed, err)
+		return nil, err
+	}
+
+	if !t.bodies {
+		fmt.Fprintf(t.out, "[http] %s %s %s %s\n", req.Method, redactURL(req.URL), resp.Status, elapsed)
+		return resp, nil
+	}
+
+	fmt.Fprintf(t.out, "[http] < %s %s\n", resp.Status, elapsed)
+	t.traceHeaders("<", resp.Header)
+
+	data, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(data))
+	t.traceBody("<", resp.Header.Get("Content-Type"), data)
+
+	return resp, nil
+}
+
+func (t *traceTransport) traceHeaders(direction string, header http.Header) {
+	for _, name := range slices.Sorted(maps.Keys(header)) {
+		for _, value := range header[name] {
+			fmt.Fprintf(t.out, "[http] %s %s: %s\n", direction, name, redactHeader(name, value))
+		}
+	}
+}
+
+func (t *traceTransport) traceBody(direction, contentType string, data []byte) {
+	if len(data) == 0 {
+		return
+	}
+
+	body := strings.TrimSpace(redactBody(contentType, data))
+	if len(body) > maxTracedBody {
+		body = body[:maxTracedBody] + fmt.Sprintf("... (%d bytes)", len(data))
+	}
+	fmt.Fprintf(t.out, "[http] %s %s\n", direction, body)
+}
+
+// redactHeader hides credentials, keeping the scheme of an Authorization header
+func redactHeader(name, value string) string {
+	switch http.CanonicalHeaderKey(name) {
+	case "Authorization", "Proxy-Authorization":
+		scheme, _, _ := strings.Cut(value, " ")
+		return scheme + " [REDACTED]"
+	case "Cookie", "Set-Cookie":
+		return "[REDACTED]"
+	}
+	return value
+}
+
+// redactURL hides tokens and codes given as query parameters
+func redactURL(u *url.URL) string {
+	if u.RawQuery == "" {
+		return u.String()
+	}
+
+	redacted := *u
+	redacted.RawQuery = redactValues(u.Query()).Encode()
+	return redacted.String()
+}
+
+func redactValues(values url.Values) url.Values {
+	for _, param := range secretParams {
+		if values.Has(param) {
+			values.Set(param, "REDACTED")
+		}
+	}
+	return values
+}
+
+// redactBody hides tokens in form and JSON bodies
+func redactBody(contentType string, data []byte) string {
+	if strings.HasPrefix(contentType, "application/x-www-form-urlencoded") {
+		if values, err := url.ParseQuery(string(data)); err == nil {
+			return redactValues(values).Encode()
+		}
+	}
+	return secretJSONField.ReplaceAllString(string(data), `$1"REDACTED"`)
+}
@@ -0,0 +1,12 @@
+//go:build !unix && !windows
+
+package httpclient
+
+import "net"
+
+// Platforms without the socket errno values can't tell a refused or reset
+// connection apart, so any failure to connect or read is taken as one
+
+func connectionLost(err *net.OpError) bool {
+	return err.Op == "dial" || err.Op == "read"
+}
@@ -0,0 +1,14 @@
+//go:build unix
+
+package httpclient
+
+import (
+	"errors"
+	"net"
+	"syscall"
+)
+
+// connectionLost reports whether the connection was refused or reset
+func connectionLost(err *net.OpError) bool {
+	return errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED)
+}
@@ -0,0 +1,17 @@
+//go:build windows
+
+package httpclient
+
+import (
+	"errors"
+	"net"
+	"syscall"
+)
+
+// wsaeconnrefused is WSAECONNREFUSED, which the syscall package leaves out
+const wsaeconnrefused syscall.Errno = 10061
+
+// connectionLost reports whether the connection was refused or reset
+func connectionLost(err *net.OpError) bool {
+	return errors.Is(err, syscall.WSAECONNRESET) || errors.Is(err, wsaeconnrefused)
+}
@@ -153,44 +153,79 @@ func FormatDevice(device spotify.PlayerDevice) string {
 		volume)
 }
 
-// FormatPlaybackState formats the current playback state
-func FormatPlaybackState(playback *spotify.PlayerState) string {
-	if playback == nil || playback.Item == nil {
+// PlaybackState is the state of the player as FormatPlaybackState shows it
+type PlaybackState struct {
+	// Track or Episode is set to the item playing, neither while an ad plays
+	Track   *spotify.FullTrack
+	Episode *spotify.EpisodePage
+	Ad      bool
+
+	// Progress is the position in the item in milliseconds
+	Progress int
+	Shuffle  bool
+	Repeat   string
+}
+
+// FormatPlaybackState formats the current playback state: a track, a podcast
+// episode and its show, or an ad
+func FormatPlaybackState(playback *PlaybackState) string {
+	if playback == nil {
 		return "No playback"
 	}
 
-	track := playback.Item
-	artistNames := make([]string, len(track.Artists))
-	for i, artist := range track.Artists {
-		artistNames[i] = artist.Name
-	}
+	position := playback.Progress
+	var duration int
 
-	artists := strings.Join(artistNames, ", ")
+	var title string
+	switch {
+	case playback.Track != nil:
+		artistNames := make([]string, len(playback.Track.Artists))
+		for i, artist := range playback.Track.Artists {
+			artistNames[i] = artist.Name
+		}
+		title = fmt.Sprintf("♪ %s - %s", BoldColor.Sprint(strings.Join(artistNames, ", ")), playback.Track.Name)
+		duration = int(playback.Track.Duration)
+	case playback.Episode != nil:
+		title = fmt.Sprintf("🎙️ %s - %s", BoldColor.Sprint(playback.Episode.Show.Name), playback.Episode.Name)
+		// An episode that hasn't started playing yet picks up where it was left
+		if position == 0 {
+			position = int(playback.Episode.ResumePoint.ResumePositionMs)
+		}
+		duration = int(playback.Episode.Duration_ms)
+	case playback.Ad:
+		return "📢 Advertisement"
+	default:
+		return "No playback"
+	}
 
-	// Progress bar
-	progress := float64(playback.Progress) / float64(track.Duration)
+	// Progress bar. Some episodes and local files don't report their length.
+	var progress float64
+	length := ""
+	if duration > 0 {
+		progress = float64(position) / float64(duration)
+		length = DimColor.Sprint("/" + FormatDuration(duration))
+	}
 	progressBar := createProgressBar(progress, 20)
 
 	// Shuffle and repeat indicators
 	shuffle := ""
-	if playback.ShuffleState {
+	if playback.Shuffle {
 		shuffle = " 🔀"
 	}
 
 	repeat := ""
-	switch playback.RepeatState {
+	switch playback.Repeat {
 	case "track":
 		repeat = " 🔁"
 	case "context":
 		repeat = " 🔂"
 	}
 
-	return fmt.Sprintf("♪ %s - %s\n   %s %s%s%s",
-		BoldColor.Sprint(artists),
-		track.Name,
+	return fmt.Sprintf("%s\n   %s %s%s%s",
+		title,
 		progressBar,
-		FormatDuration(int(playback.Progress)),
-		DimColor.Sprint("/"+FormatDuration(int(track.Duration))),
+		FormatDuration(position),
+		length,
 		shuffle+repeat)
 }
 
@@ -0,0 +1,46 @@
+package ui
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"golang.org/x/term"
+)
+
+// Progress shows how far a long fetch has got on stderr. It only draws when
+// stderr is a terminal, so it stays out of redirected errors and never mixes
+// with stdout.
+type Progress struct {
+	label   string
+	out     io.Writer
+	enabled bool
+	shown   bool
+}
+
+// NewProgress creates a progress indicator for items of the given kind, e.g. "tracks"
+func NewProgress(label string) *Progress {
+	return &Progress{
+		label:   label,
+		out:     os.Stderr,
+		enabled: term.IsTerminal(int(os.Stderr.Fd())),
+	}
+}
+
+// Update shows the number of items fetched so far out of the total
+func (p *Progress) Update(done, total int) {
+	if !p.enabled {
+		return
+	}
+
+	fmt.Fprintf(p.out, "\r⏳ Fetched %d/%d %s", done, total, p.label)
+	p.shown = true
+}
+
+// Done clears the progress line
+func (p *Progress) Done() {
+	if p.shown {
+		fmt.Fprint(p.out, "\r\033[K")
+		p.shown = false
+	}
+}
@@ -0,0 +1,36 @@
+package ui
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestProgress(t *testing.T) {
+	var out bytes.Buffer
+	p := &Progress{label: "tracks", out: &out, enabled: true}
+
+	p.Update(50, 120)
+	p.Update(100, 120)
+	if got, want := out.String(), "\r⏳ Fetched 50/120 tracks\r⏳ Fetched 100/120 tracks"; got != want {
+		t.Errorf("drew %q, want %q", got, want)
+	}
+
+	// The line is cleared once, leaving the terminal as it was
+	out.Reset()
+	p.Done()
+	p.Done()
+	if got, want := out.String(), "\r\033[K"; got != want {
+		t.Errorf("Done wrote %q, want %q", got, want)
+	}
+}
+
+func TestProgressDisabled(t *testing.T) {
+	var out bytes.Buffer
+	p := &Progress{label: "tracks", out: &out}
+
+	p.Update(50, 120)
+	p.Done()
+	if out.Len() != 0 {
+		t.Errorf("drew %q with stderr not a terminal", out.String())
+	}
+}
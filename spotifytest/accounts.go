@@ -0,0 +1,300 @@
+package spotifytest
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/AustinMusiku/spotifycli/internal/auth"
+)
+
+// Token is a token pair issued by the fake accounts service
+type Token struct {
+	AccessToken  string
+	RefreshToken string
+	TokenType    string
+	ExpiresIn    int64
+	Scope        string
+}
+
+type authCode struct {
+	clientID      string
+	redirectURI   string
+	codeChallenge string
+	scopes        []string
+}
+
+type accessToken struct {
+	scopes []string
+	expiry time.Time
+	// app tokens come from the client credentials grant and carry no user
+	app bool
+}
+
+type refreshToken struct {
+	scopes []string
+}
+
+// IssueToken mints a user token pair as if the user had logged in, granting
+// the given scopes or, when none are given, every scope spotifycli requests
+func (s *Server) IssueToken(scopes ...string) Token {
+	if len(scopes) == 0 {
+		scopes = auth.DefaultScopes
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token := s.issue(scopes, false)
+	s.issueRefresh(&token, scopes)
+	return token
+}
+
+// ExpireTokens expires every access token issued so far, so the next Web API
+// call is rejected with 401 until the client refreshes
+func (s *Server) ExpireTokens() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, token := range s.access {
+		token.expiry = time.Now().Add(-time.Second)
+	}
+}
+
+// RevokeTokens invalidates every access and refresh token issued so far
+func (s *Server) RevokeTokens() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	clear(s.access)
+	clear(s.refresh)
+}
+
+// issue records a new access token. The caller holds s.mu.
+func (s *Server) issue(scopes []string, app bool) Token {
+	token := Token{
+		AccessToken: randomToken(),
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(s.tokenTTL / time.Second),
+		Scope:       strings.Join(scopes, " "),
+	}
+	s.access[token.AccessToken] = &accessToken{
+		scopes: scopes,
+		expiry: time.Now().Add(s.tokenTTL),
+		app:    app,
+	}
+
+	return token
+}
+
+// issueRefresh adds a new refresh token to token. The caller holds s.mu.
+func (s *Server) issueRefresh(token *Token, scopes []string) {
+	token.RefreshToken = randomToken()
+	s.refresh[token.RefreshToken] = &refreshToken{scopes: scopes}
+}
+
+func randomToken() string {
+	b := make([]byte, 24)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// handleAuthorize approves every authorization request straight away and
+// redirects back with a code, so a login can complete without a browser
+func (s *Server) handleAuthorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	redirectURI, err := url.Parse(q.Get("redirect_uri"))
+	if err != nil || redirectURI.Scheme == "" {
+		http.Error(w, "INVALID_CLIENT: Invalid redirect URI", http.StatusBadRequest)
+		return
+	}
+	if q.Get("client_id") == "" || (s.clientID != "" && q.Get("client_id") != s.clientID) {
+		http.Error(w, "INVALID_CLIENT: Invalid client", http.StatusBadRequest)
+		return
+	}
+	if q.Get("response_type") != "code" {
+		http.Error(w, "unsupported_response_type", http.StatusBadRequest)
+		return
+	}
+
+	scopes := strings.Fields(q.Get("scope"))
+	if err := auth.ValidateScopes(scopes); err != nil {
+		http.Error(w, "invalid_scope", http.StatusBadRequest)
+		return
+	}
+
+	code := randomToken()
+	s.mu.Lock()
+	s.codes[code] = authCode{
+		clientID:      q.Get("client_id"),
+		redirectURI:   q.Get("redirect_uri"),
+		codeChallenge: q.Get("code_challenge"),
+		scopes:        scopes,
+	}
+	s.mu.Unlock()
+
+	params := redirectURI.Query()
+	params.Set("code", code)
+	if state := q.Get("state"); state != "" {
+		params.Set("state", state)
+	}
+	redirectURI.RawQuery = params.Encode()
+
+	http.Redirect(w, r, redirectURI.String(), http.StatusFound)
+}
+
+// handleToken implements the authorization code, refresh token and client
+// credentials grants
+func (s *Server) handleToken(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request", "Malformed request body")
+		return
+	}
+
+	clientID, clientSecret, ok := r.BasicAuth()
+	if !ok {
+		clientID = r.PostForm.Get("client_id")
+		clientSecret = r.PostForm.Get("client_secret")
+	}
+	if clientID == "" || (s.clientID != "" && clientID != s.clientID) {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_client", "Invalid client")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var token Token
+	switch r.PostForm.Get("grant_type") {
+	case "authorization_code":
+		code, ok := s.codes[r.PostForm.Get("code")]
+		if !ok || code.clientID != clientID {
+			writeOAuthError(w, http.StatusBadRequest, "invalid_grant", "Invalid authorization code")
+			return
+		}
+		if code.redirectURI != r.PostForm.Get("redirect_uri") {
+			writeOAuthError(w, http.StatusBadRequest, "invalid_grant", "Invalid redirect URI")
+			return
+		}
+		if code.codeChallenge != "" && pkceChallenge(r.PostForm.Get("code_verifier")) != code.codeChallenge {
+			writeOAuthError(w, http.StatusBadRequest, "invalid_grant", "code_verifier was incorrect")
+			return
+		}
+		delete(s.codes, r.PostForm.Get("code"))
+
+		token = s.issue(code.scopes, false)
+		s.issueRefresh(&token, code.scopes)
+
+	case "refresh_token":
+		old := r.PostForm.Get("refresh_token")
+		refresh, ok := s.refresh[old]
+		if !ok {
+			writeOAuthError(w, http.StatusBadRequest, "invalid_grant", "Refresh token revoked")
+			return
+		}
+
+		token = s.issue(refresh.scopes, false)
+		if s.rotate {
+			delete(s.refresh, old)
+			s.issueRefresh(&token, refresh.scopes)
+		}
+
+	case "client_credentials":
+		if s.clientSecret != "" && clientSecret != s.clientSecret {
+			writeOAuthError(w, http.StatusBadRequest, "invalid_client", "Invalid client secret")
+			return
+		}
+
+		token = s.issue(nil, true)
+
+	default:
+		writeOAuthError(w, http.StatusBadRequest, "unsupported_grant_type", "grant_type parameter is missing or unsupported")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, struct {
+		AccessToken  string `json:"access_token"`
+		TokenType    string `json:"token_type"`
+		ExpiresIn    int64  `json:"expires_in"`
+		RefreshToken string `json:"refresh_token,omitempty"`
+		Scope        string `json:"scope"`
+	}{token.AccessToken, token.TokenType, token.ExpiresIn, token.RefreshToken, token.Scope})
+}
+
+func pkceChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func anyOf(scopes ...string) []string {
+	return scopes
+}
+
+// app wraps a Web API handler that accepts any valid token, including app
+// tokens from the client credentials grant. Handlers run with s.mu held.
+func (s *Server) app(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		if _, ok := s.authorize(w, r); !ok {
+			return
+		}
+
+		h(w, r)
+	}
+}
+
+// user wraps a Web API handler that needs a user token granted at least one
+// of the given scopes. Handlers run with s.mu held.
+func (s *Server) user(scopes []string, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		token, ok := s.authorize(w, r)
+		if !ok {
+			return
+		}
+		if token.app {
+			writeError(w, http.StatusUnauthorized, "Valid user authentication required", "")
+			return
+		}
+		if len(scopes) > 0 && !slices.ContainsFunc(scopes, func(scope string) bool {
+			return slices.Contains(token.scopes, scope)
+		}) {
+			writeError(w, http.StatusForbidden, "Insufficient client scope", "")
+			return
+		}
+
+		h(w, r)
+	}
+}
+
+// authorize checks the bearer token of a Web API request. The caller holds s.mu.
+func (s *Server) authorize(w http.ResponseWriter, r *http.Request) (*accessToken, bool) {
+	header := r.Header.Get("Authorization")
+	if header == "" {
+		writeError(w, http.StatusUnauthorized, "No token provided", "")
+		return nil, false
+	}
+
+	token, ok := s.access[strings.TrimPrefix(header, "Bearer ")]
+	if !ok || !strings.HasPrefix(header, "Bearer ") {
+		writeError(w, http.StatusUnauthorized, "Invalid access token", "")
+		return nil, false
+	}
+	if time.Now().After(token.expiry) {
+		writeError(w, http.StatusUnauthorized, "The access token expired", "")
+		return nil, false
+	}
+
+	return token, true
+}
@@ -0,0 +1,77 @@
+// Command fakespotify runs the spotifytest fake Spotify server and points a
+// spotifycli profile at it, already logged in, so every command can be tried
+// end to end without a Spotify account:
+//
+//	go run ./spotifytest/fakespotify -profile fake
+//	spotifycli --profile fake status
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/AustinMusiku/spotifycli/internal/config"
+	"github.com/AustinMusiku/spotifycli/spotifytest"
+)
+
+func main() {
+	addr := flag.String("addr", "127.0.0.1:0", "address to listen on")
+	profileName := flag.String("profile", "fake", "spotifycli profile to point at the server")
+	flag.Parse()
+
+	srv := spotifytest.NewServer(
+		spotifytest.WithAddress(*addr),
+		spotifytest.WithCallHook(func(call spotifytest.Call) {
+			fmt.Printf("%d %s %s\n", call.Status, call.Method, call.Path)
+		}),
+	)
+	defer srv.Close()
+
+	if err := setupProfile(srv, *profileName); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Fake Spotify listening on %s\n", srv.URL())
+	fmt.Printf("Profile %q is logged in, try: spotifycli --profile %s status\n", *profileName, *profileName)
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	<-sig
+}
+
+// setupProfile creates or updates the profile to use the server and stores a
+// freshly issued token in it
+func setupProfile(srv *spotifytest.Server, name string) error {
+	file, err := config.LoadFile()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	profile, err := file.Profile(name)
+	if err != nil {
+		profile, err = file.AddProfile(name)
+		if err != nil {
+			return err
+		}
+	}
+
+	if profile.ClientID == "" {
+		profile.ClientID = "spotifytest"
+	}
+	profile.AccountsURL = srv.AccountsURL()
+	profile.APIURL = srv.APIURL()
+
+	token := srv.IssueToken()
+	profile.SetTokens(token.AccessToken, token.RefreshToken, token.TokenType, token.ExpiresIn)
+	profile.Scope = token.Scope
+
+	if err := file.Save(); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,184 @@
+package spotifytest
+
+import (
+	"time"
+
+	"github.com/zmb3/spotify/v2"
+)
+
+// NewArtist builds a catalog artist
+func NewArtist(id, name string) spotify.FullArtist {
+	return spotify.FullArtist{
+		SimpleArtist: spotify.SimpleArtist{
+			ID:   spotify.ID(id),
+			Name: name,
+			URI:  spotify.URI("spotify:artist:" + id),
+		},
+		Popularity: 50,
+	}
+}
+
+// NewAlbum builds a catalog album by the given artists
+func NewAlbum(id, name, releaseDate string, artists ...spotify.FullArtist) spotify.FullAlbum {
+	album := spotify.FullAlbum{
+		SimpleAlbum: spotify.SimpleAlbum{
+			ID:                   spotify.ID(id),
+			Name:                 name,
+			URI:                  spotify.URI("spotify:album:" + id),
+			AlbumType:            "album",
+			ReleaseDate:          releaseDate,
+			ReleaseDatePrecision: "day",
+		},
+		Popularity: 50,
+	}
+	for _, artist := range artists {
+		album.Artists = append(album.Artists, artist.SimpleArtist)
+	}
+	return album
+}
+
+// NewTrack builds a catalog track on the given album, credited to the
+// album's artists
+func NewTrack(id, name string, album spotify.FullAlbum, durationMs int) spotify.FullTrack {
+	return spotify.FullTrack{
+		SimpleTrack: spotify.SimpleTrack{
+			ID:       spotify.ID(id),
+			Name:     name,
+			URI:      spotify.URI("spotify:track:" + id),
+			Type:     "track",
+			Artists:  album.Artists,
+			Duration: spotify.Numeric(durationMs),
+		},
+		Album:      album.SimpleAlbum,
+		Popularity: 50,
+	}
+}
+
+// NewPlaylist builds a catalog playlist owned by the given user
+func NewPlaylist(id, name, owner string, tracks ...spotify.FullTrack) Playlist {
+	playlist := Playlist{
+		SimplePlaylist: spotify.SimplePlaylist{
+			ID:    spotify.ID(id),
+			Name:  name,
+			URI:   spotify.URI("spotify:playlist:" + id),
+			Owner: spotify.User{ID: owner, DisplayName: owner},
+		},
+	}
+	for _, track := range tracks {
+		playlist.Items = append(playlist.Items, track.URI)
+	}
+	playlist.Tracks.Total = spotify.Numeric(len(playlist.Items))
+	return playlist
+}
+
+// NewShow builds a catalog show
+func NewShow(id, name, publisher string) spotify.FullShow {
+	return spotify.FullShow{
+		SimpleShow: spotify.SimpleShow{
+			ID:        spotify.ID(id),
+			Name:      name,
+			Publisher: publisher,
+			URI:       spotify.URI("spotify:show:" + id),
+			Type:      "show",
+			MediaType: "audio",
+		},
+	}
+}
+
+// NewEpisode builds a catalog episode of the given show
+func NewEpisode(id, name string, show spotify.FullShow, durationMs int) spotify.EpisodePage {
+	return spotify.EpisodePage{
+		ID:          spotify.ID(id),
+		Name:        name,
+		URI:         spotify.URI("spotify:episode:" + id),
+		Type:        "episode",
+		Duration_ms: spotify.Numeric(durationMs),
+		IsPlayable:  true,
+		Show:        show.SimpleShow,
+	}
+}
+
+// NewDevice builds an inactive device
+func NewDevice(id, name, deviceType string, volume int) spotify.PlayerDevice {
+	return spotify.PlayerDevice{
+		ID:     spotify.ID(id),
+		Name:   name,
+		Type:   deviceType,
+		Volume: spotify.Numeric(volume),
+	}
+}
+
+// DefaultState returns a small library for a user with two devices, one of
+// them active and playing an album
+func DefaultState() *State {
+	daftPunk := NewArtist("daftpunk", "Daft Punk")
+	radiohead := NewArtist("radiohead", "Radiohead")
+	fleetwoodMac := NewArtist("fleetwoodmac", "Fleetwood Mac")
+
+	discovery := NewAlbum("discovery", "Discovery", "2001-03-12", daftPunk)
+	okComputer := NewAlbum("okcomputer", "OK Computer", "1997-05-21", radiohead)
+	rumours := NewAlbum("rumours", "Rumours", "1977-02-04", fleetwoodMac)
+
+	oneMoreTime := NewTrack("onemoretime", "One More Time", discovery, 320357)
+	digitalLove := NewTrack("digitallove", "Digital Love", discovery, 301373)
+	harderBetter := NewTrack("harderbetter", "Harder, Better, Faster, Stronger", discovery, 224693)
+	paranoidAndroid := NewTrack("paranoidandroid", "Paranoid Android", okComputer, 387227)
+	karmaPolice := NewTrack("karmapolice", "Karma Police", okComputer, 263827)
+	dreams := NewTrack("dreams", "Dreams", rumours, 257800)
+	theChain := NewTrack("thechain", "The Chain", rumours, 270000)
+
+	show := NewShow("dailyfake", "The Daily Fake", "Fake Media")
+	firstEpisode := NewEpisode("episode1", "Episode 1: Hello World", show, 1800000)
+	secondEpisode := NewEpisode("episode2", "Episode 2: Offline First", show, 2100000)
+
+	laptop := NewDevice("laptop", "Laptop", "Computer", 60)
+	laptop.Active = true
+	speaker := NewDevice("speaker", "Kitchen Speaker", "Speaker", 40)
+
+	added := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	return &State{
+		User: spotify.PrivateUser{
+			User: spotify.User{
+				ID:          "testuser",
+				DisplayName: "Test User",
+				URI:         "spotify:user:testuser",
+			},
+			Country: "GB",
+			Email:   "testuser@example.com",
+			Product: "premium",
+		},
+		Devices: []spotify.PlayerDevice{laptop, speaker},
+		Player: Player{
+			Item:       oneMoreTime.URI,
+			Context:    discovery.URI,
+			ProgressMs: 30000,
+			Playing:    true,
+			Repeat:     "off",
+		},
+		Catalog: Catalog{
+			Artists: []spotify.FullArtist{daftPunk, radiohead, fleetwoodMac},
+			Albums:  []spotify.FullAlbum{discovery, okComputer, rumours},
+			Tracks: []spotify.FullTrack{
+				oneMoreTime, digitalLove, harderBetter,
+				paranoidAndroid, karmaPolice,
+				dreams, theChain,
+			},
+			Playlists: []Playlist{
+				NewPlaylist("roadtrip", "Road Trip", "testuser", digitalLove, dreams, karmaPolice),
+				NewPlaylist("focus", "Deep Focus", "spotify", paranoidAndroid, theChain),
+			},
+			Shows:    []spotify.FullShow{show},
+			Episodes: []spotify.EpisodePage{firstEpisode, secondEpisode},
+		},
+		Library: Library{
+			Playlists: []spotify.ID{"roadtrip"},
+			Tracks: []Saved{
+				{ID: karmaPolice.ID, AddedAt: added},
+				{ID: dreams.ID, AddedAt: added},
+			},
+			Albums: []Saved{{ID: discovery.ID, AddedAt: added}},
+			Shows:  []Saved{{ID: show.ID, AddedAt: added}},
+		},
+	}
+}
@@ -0,0 +1,392 @@
+package spotifytest
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zmb3/spotify/v2"
+)
+
+// page is a Web API paging object
+type page[T any] struct {
+	Href     string  `json:"href"`
+	Limit    int     `json:"limit"`
+	Offset   int     `json:"offset"`
+	Total    int     `json:"total"`
+	Next     *string `json:"next"`
+	Previous *string `json:"previous"`
+	Items    []T     `json:"items"`
+}
+
+// pageParams reads limit and offset, writing an error response when either
+// is out of range
+func pageParams(w http.ResponseWriter, r *http.Request) (limit, offset int, ok bool) {
+	limit, offset = 20, 0
+
+	q := r.URL.Query()
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 50 {
+			writeError(w, http.StatusBadRequest, "Invalid limit", "")
+			return 0, 0, false
+		}
+		limit = n
+	}
+	if v := q.Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeError(w, http.StatusBadRequest, "Invalid offset", "")
+			return 0, 0, false
+		}
+		offset = n
+	}
+
+	return limit, offset, true
+}
+
+// newPage returns the window of items selected by limit and offset, with
+// links to the neighbouring pages
+func newPage[T any](s *Server, r *http.Request, items []T, limit, offset int) page[T] {
+	link := func(offset int) *string {
+		q := r.URL.Query()
+		q.Set("limit", strconv.Itoa(limit))
+		q.Set("offset", strconv.Itoa(offset))
+		u := s.URL() + r.URL.Path + "?" + q.Encode()
+		return &u
+	}
+
+	p := page[T]{
+		Href:   *link(offset),
+		Limit:  limit,
+		Offset: offset,
+		Total:  len(items),
+		Items:  []T{},
+	}
+	if offset < len(items) {
+		p.Items = items[offset:min(offset+limit, len(items))]
+	}
+	if offset+limit < len(items) {
+		p.Next = link(offset + limit)
+	}
+	if offset > 0 {
+		p.Previous = link(max(offset-limit, 0))
+	}
+
+	return p
+}
+
+func (s *Server) handleSavedPlaylists(w http.ResponseWriter, r *http.Request) {
+	limit, offset, ok := pageParams(w, r)
+	if !ok {
+		return
+	}
+
+	var playlists []spotify.SimplePlaylist
+	for _, id := range s.state.Library.Playlists {
+		if playlist := s.state.Catalog.Playlist(id); playlist != nil {
+			playlists = append(playlists, playlist.SimplePlaylist)
+		}
+	}
+
+	writeJSON(w, http.StatusOK, newPage(s, r, playlists, limit, offset))
+}
+
+func (s *Server) handleSavedTracks(w http.ResponseWriter, r *http.Request) {
+	limit, offset, ok := pageParams(w, r)
+	if !ok {
+		return
+	}
+
+	var tracks []spotify.SavedTrack
+	for _, saved := range s.state.Library.Tracks {
+		if track := s.state.Catalog.Track(saved.ID); track != nil {
+			tracks = append(tracks, spotify.SavedTrack{
+				AddedAt:   saved.AddedAt.UTC().Format(spotify.TimestampLayout),
+				FullTrack: *track,
+			})
+		}
+	}
+
+	writeJSON(w, http.StatusOK, newPage(s, r, tracks, limit, offset))
+}
+
+func (s *Server) handleSavedAlbums(w http.ResponseWriter, r *http.Request) {
+	limit, offset, ok := pageParams(w, r)
+	if !ok {
+		return
+	}
+
+	var albums []spotify.SavedAlbum
+	for _, saved := range s.state.Library.Albums {
+		if album := s.state.Catalog.Album(saved.ID); album != nil {
+			albums = append(albums, spotify.SavedAlbum{
+				AddedAt:   saved.AddedAt.UTC().Format(spotify.TimestampLayout),
+				FullAlbum: *album,
+			})
+		}
+	}
+
+	writeJSON(w, http.StatusOK, newPage(s, r, albums, limit, offset))
+}
+
+func (s *Server) handleSavedShows(w http.ResponseWriter, r *http.Request) {
+	limit, offset, ok := pageParams(w, r)
+	if !ok {
+		return
+	}
+
+	var shows []spotify.SavedShow
+	for _, saved := range s.state.Library.Shows {
+		if show := s.state.Catalog.Show(saved.ID); show != nil {
+			shows = append(shows, spotify.SavedShow{
+				AddedAt:  saved.AddedAt.UTC().Format(spotify.TimestampLayout),
+				FullShow: *show,
+			})
+		}
+	}
+
+	writeJSON(w, http.StatusOK, newPage(s, r, shows, limit, offset))
+}
+
+type libraryKind int
+
+const (
+	kindTrack libraryKind = iota
+	kindAlbum
+	kindShow
+)
+
+// saved returns the library list for kind and whether id is in the catalog
+func (s *Server) saved(kind libraryKind, id spotify.ID) (*[]Saved, bool) {
+	switch kind {
+	case kindAlbum:
+		return &s.state.Library.Albums, s.state.Catalog.Album(id) != nil
+	case kindShow:
+		return &s.state.Library.Shows, s.state.Catalog.Show(id) != nil
+	default:
+		return &s.state.Library.Tracks, s.state.Catalog.Track(id) != nil
+	}
+}
+
+// libraryIDs reads the ids parameter, writing an error response when it is
+// missing, too long or names something not in the catalog
+func (s *Server) libraryIDs(w http.ResponseWriter, r *http.Request, kind libraryKind) ([]spotify.ID, bool) {
+	param := r.URL.Query().Get("ids")
+	if param == "" {
+		writeError(w, http.StatusBadRequest, "Missing required field: ids", "")
+		return nil, false
+	}
+
+	var ids []spotify.ID
+	for _, id := range strings.Split(param, ",") {
+		if _, ok := s.saved(kind, spotify.ID(id)); !ok {
+			writeError(w, http.StatusBadRequest, "Invalid id: "+id, "")
+			return nil, false
+		}
+		ids = append(ids, spotify.ID(id))
+	}
+	if len(ids) > 50 {
+		writeError(w, http.StatusBadRequest, "Too many ids requested", "")
+		return nil, false
+	}
+
+	return ids, true
+}
+
+func (s *Server) handleSave(kind libraryKind) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ids, ok := s.libraryIDs(w, r, kind)
+		if !ok {
+			return
+		}
+
+		for _, id := range ids {
+			list, _ := s.saved(kind, id)
+			if !slices.ContainsFunc(*list, func(saved Saved) bool { return saved.ID == id }) {
+				*list = slices.Insert(*list, 0, Saved{ID: id, AddedAt: time.Now()})
+			}
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+func (s *Server) handleRemove(kind libraryKind) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ids, ok := s.libraryIDs(w, r, kind)
+		if !ok {
+			return
+		}
+
+		for _, id := range ids {
+			list, _ := s.saved(kind, id)
+			*list = slices.DeleteFunc(*list, func(saved Saved) bool { return saved.ID == id })
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+var searchTypes = []string{"album", "artist", "playlist", "track", "show", "episode"}
+
+// handleSearch matches every word of the query, ignoring field filters such
+// as "artist:", against item names and the names of their artists, album,
+// owner or show. Items come back in catalog order.
+func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("q") == "" {
+		writeError(w, http.StatusBadRequest, "No search query", "")
+		return
+	}
+	if q.Get("type") == "" {
+		writeError(w, http.StatusBadRequest, "Missing parameter type", "")
+		return
+	}
+
+	types := strings.Split(q.Get("type"), ",")
+	for _, t := range types {
+		if !slices.Contains(searchTypes, t) {
+			writeError(w, http.StatusBadRequest, "Bad search type field "+t, "")
+			return
+		}
+	}
+
+	limit, offset, ok := pageParams(w, r)
+	if !ok {
+		return
+	}
+
+	terms := searchTerms(q.Get("q"))
+	catalog := &s.state.Catalog
+
+	result := make(map[string]any)
+	for _, t := range types {
+		switch t {
+		case "track":
+			var tracks []spotify.FullTrack
+			for _, track := range catalog.Tracks {
+				if matches(terms, track.Name, track.Album.Name, artistNames(track.Artists)) {
+					tracks = append(tracks, track)
+				}
+			}
+			result["tracks"] = newPage(s, r, tracks, limit, offset)
+		case "album":
+			var albums []spotify.SimpleAlbum
+			for _, album := range catalog.Albums {
+				if matches(terms, album.Name, artistNames(album.Artists)) {
+					albums = append(albums, album.SimpleAlbum)
+				}
+			}
+			result["albums"] = newPage(s, r, albums, limit, offset)
+		case "artist":
+			var artists []spotify.FullArtist
+			for _, artist := range catalog.Artists {
+				if matches(terms, artist.Name) {
+					artists = append(artists, artist)
+				}
+			}
+			result["artists"] = newPage(s, r, artists, limit, offset)
+		case "playlist":
+			var playlists []spotify.SimplePlaylist
+			for _, playlist := range catalog.Playlists {
+				if matches(terms, playlist.Name, playlist.Description, playlist.Owner.DisplayName) {
+					playlists = append(playlists, playlist.SimplePlaylist)
+				}
+			}
+			result["playlists"] = newPage(s, r, playlists, limit, offset)
+		case "show":
+			var shows []spotify.SimpleShow
+			for _, show := range catalog.Shows {
+				if matches(terms, show.Name, show.Publisher) {
+					shows = append(shows, show.SimpleShow)
+				}
+			}
+			result["shows"] = newPage(s, r, shows, limit, offset)
+		case "episode":
+			var episodes []spotify.EpisodePage
+			for _, episode := range catalog.Episodes {
+				if matches(terms, episode.Name, episode.Show.Name) {
+					episodes = append(episodes, episode)
+				}
+			}
+			result["episodes"] = newPage(s, r, episodes, limit, offset)
+		}
+	}
+
+	writeCatalogJSON(w, r, result)
+}
+
+func searchTerms(query string) []string {
+	var terms []string
+	for _, field := range strings.Fields(strings.ToLower(query)) {
+		if _, value, ok := strings.Cut(field, ":"); ok {
+			field = value
+		}
+		if field = strings.Trim(field, `"`); field != "" {
+			terms = append(terms, field)
+		}
+	}
+	return terms
+}
+
+func matches(terms []string, fields ...string) bool {
+	haystack := strings.ToLower(strings.Join(fields, " "))
+	for _, term := range terms {
+		if !strings.Contains(haystack, term) {
+			return false
+		}
+	}
+	return true
+}
+
+func artistNames(artists []spotify.SimpleArtist) string {
+	names := make([]string, len(artists))
+	for i, artist := range artists {
+		names[i] = artist.Name
+	}
+	return strings.Join(names, " ")
+}
+
+func (s *Server) handleTrack(w http.ResponseWriter, r *http.Request) {
+	track := s.state.Catalog.Track(spotify.ID(r.PathValue("id")))
+	if track == nil {
+		writeError(w, http.StatusNotFound, "Non existing id", "")
+		return
+	}
+
+	writeCatalogJSON(w, r, track)
+}
+
+func (s *Server) handleAlbum(w http.ResponseWriter, r *http.Request) {
+	found := s.state.Catalog.Album(spotify.ID(r.PathValue("id")))
+	if found == nil {
+		writeError(w, http.StatusNotFound, "Non existing id", "")
+		return
+	}
+
+	album := *found
+	album.Tracks = spotify.SimpleTrackPage{}
+	for _, track := range s.state.Catalog.Tracks {
+		if track.Album.ID == album.ID {
+			album.Tracks.Tracks = append(album.Tracks.Tracks, track.SimpleTrack)
+		}
+	}
+	album.Tracks.Limit = 50
+	album.Tracks.Total = spotify.Numeric(len(album.Tracks.Tracks))
+	album.TotalTracks = album.Tracks.Total
+
+	writeCatalogJSON(w, r, album)
+}
+
+func (s *Server) handleArtist(w http.ResponseWriter, r *http.Request) {
+	artist := s.state.Catalog.Artist(spotify.ID(r.PathValue("id")))
+	if artist == nil {
+		writeError(w, http.StatusNotFound, "Non existing id", "")
+		return
+	}
+
+	writeCatalogJSON(w, r, artist)
+}
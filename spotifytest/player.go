@@ -0,0 +1,411 @@
+package spotifytest
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zmb3/spotify/v2"
+)
+
+// currentlyPlaying is the body of the currently-playing endpoint. Unlike
+// spotify.CurrentlyPlaying it can carry an episode.
+type currentlyPlaying struct {
+	Timestamp            int64                    `json:"timestamp"`
+	Context              *spotify.PlaybackContext `json:"context"`
+	ProgressMs           int                      `json:"progress_ms"`
+	IsPlaying            bool                     `json:"is_playing"`
+	Item                 any                      `json:"item"`
+	CurrentlyPlayingType string                   `json:"currently_playing_type"`
+}
+
+type playerState struct {
+	currentlyPlaying
+	Device       spotify.PlayerDevice `json:"device"`
+	ShuffleState bool                 `json:"shuffle_state"`
+	RepeatState  string               `json:"repeat_state"`
+}
+
+func (s *Server) handleMe(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.state.User)
+}
+
+func (s *Server) handlePlayerState(w http.ResponseWriter, r *http.Request) {
+	device := s.state.ActiveDevice()
+	if device == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, playerState{
+		currentlyPlaying: s.currentlyPlaying(r),
+		Device:           *device,
+		ShuffleState:     s.state.Player.Shuffle,
+		RepeatState:      s.state.Player.Repeat,
+	})
+}
+
+func (s *Server) handleCurrentlyPlaying(w http.ResponseWriter, r *http.Request) {
+	if s.state.ActiveDevice() == nil || s.state.Player.Item == "" {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, s.currentlyPlaying(r))
+}
+
+// currentlyPlaying describes the player. Episodes are only included when the
+// request lists them in additional_types, as on Spotify.
+func (s *Server) currentlyPlaying(r *http.Request) currentlyPlaying {
+	player := s.state.Player
+
+	cp := currentlyPlaying{
+		Timestamp:            time.Now().UnixMilli(),
+		ProgressMs:           player.ProgressMs,
+		IsPlaying:            player.Playing,
+		CurrentlyPlayingType: "unknown",
+	}
+
+	if player.Context != "" {
+		kind, id := splitURI(player.Context)
+		cp.Context = &spotify.PlaybackContext{
+			Type:     kind,
+			URI:      player.Context,
+			Endpoint: s.APIURL() + kind + "s/" + string(id),
+		}
+	}
+
+	kind, _ := splitURI(player.Item)
+	switch kind {
+	case "track":
+		cp.CurrentlyPlayingType = "track"
+		cp.Item = s.resolve(player.Item)
+	case "episode":
+		cp.CurrentlyPlayingType = "episode"
+		if slices.Contains(strings.Split(r.URL.Query().Get("additional_types"), ","), "episode") {
+			cp.Item = s.resolve(player.Item)
+		}
+	}
+
+	return cp
+}
+
+// resolve returns the catalog track or episode for a URI, or nil
+func (s *Server) resolve(uri spotify.URI) any {
+	kind, id := splitURI(uri)
+	switch kind {
+	case "track":
+		if track := s.state.Catalog.Track(id); track != nil {
+			return track
+		}
+	case "episode":
+		if episode := s.state.Catalog.Episode(id); episode != nil {
+			return episode
+		}
+	}
+	return nil
+}
+
+func (s *Server) handleDevices(w http.ResponseWriter, r *http.Request) {
+	devices := s.state.Devices
+	if devices == nil {
+		devices = []spotify.PlayerDevice{}
+	}
+
+	writeJSON(w, http.StatusOK, struct {
+		Devices []spotify.PlayerDevice `json:"devices"`
+	}{devices})
+}
+
+func (s *Server) handleTransfer(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		DeviceIDs []spotify.ID `json:"device_ids"`
+		Play      bool         `json:"play"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "Malformed json", "")
+		return
+	}
+	if len(body.DeviceIDs) != 1 {
+		writeError(w, http.StatusBadRequest, "Only one device ID is currently supported", "")
+		return
+	}
+	if !s.state.Activate(body.DeviceIDs[0]) {
+		writeError(w, http.StatusNotFound, "Device not found", "")
+		return
+	}
+
+	if body.Play {
+		s.state.Player.Playing = true
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// targetDevice returns the device named by the device_id parameter or, when
+// there is none, the active device. It writes the error response on failure.
+func (s *Server) targetDevice(w http.ResponseWriter, r *http.Request) (*spotify.PlayerDevice, bool) {
+	if id := r.URL.Query().Get("device_id"); id != "" {
+		device := s.state.Device(spotify.ID(id))
+		if device == nil {
+			writeError(w, http.StatusNotFound, "Device not found", "")
+			return nil, false
+		}
+		return device, true
+	}
+
+	device := s.state.ActiveDevice()
+	if device == nil {
+		writeError(w, http.StatusNotFound, "Player command failed: No active device found", "NO_ACTIVE_DEVICE")
+		return nil, false
+	}
+	return device, true
+}
+
+func (s *Server) handlePlay(w http.ResponseWriter, r *http.Request) {
+	device, ok := s.targetDevice(w, r)
+	if !ok {
+		return
+	}
+
+	var body struct {
+		ContextURI spotify.URI   `json:"context_uri"`
+		URIs       []spotify.URI `json:"uris"`
+		Offset     *struct {
+			Position *int        `json:"position"`
+			URI      spotify.URI `json:"uri"`
+		} `json:"offset"`
+		PositionMs int `json:"position_ms"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeError(w, http.StatusBadRequest, "Malformed json", "")
+			return
+		}
+	}
+
+	player := &s.state.Player
+
+	var items []spotify.URI
+	switch {
+	case body.ContextURI != "" && len(body.URIs) > 0:
+		writeError(w, http.StatusBadRequest, "Only one of context_uri and uris can be specified", "")
+		return
+	case body.ContextURI != "":
+		items = s.state.Catalog.ContextItems(body.ContextURI)
+		if len(items) == 0 {
+			writeError(w, http.StatusBadRequest, "Invalid context uri", "")
+			return
+		}
+	case len(body.URIs) > 0:
+		for _, uri := range body.URIs {
+			if s.resolve(uri) == nil {
+				writeError(w, http.StatusBadRequest, "Invalid track uri: "+string(uri), "")
+				return
+			}
+		}
+		items = body.URIs
+	}
+
+	if items != nil {
+		start := 0
+		if body.Offset != nil {
+			switch {
+			case body.Offset.Position != nil:
+				start = *body.Offset.Position
+			case body.Offset.URI != "":
+				start = slices.Index(items, body.Offset.URI)
+			}
+			if start < 0 || start >= len(items) {
+				writeError(w, http.StatusBadRequest, "Invalid offset", "")
+				return
+			}
+		}
+
+		if body.ContextURI != "" {
+			player.Context = body.ContextURI
+			player.URIs = nil
+		} else {
+			player.Context = ""
+			player.URIs = items
+		}
+		player.Item = items[start]
+		player.ProgressMs = body.PositionMs
+	} else if body.PositionMs != 0 {
+		player.ProgressMs = body.PositionMs
+	}
+
+	s.state.Activate(device.ID)
+	player.Playing = true
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handlePause(w http.ResponseWriter, r *http.Request) {
+	if _, ok := s.targetDevice(w, r); !ok {
+		return
+	}
+	if !s.state.Player.Playing {
+		writeError(w, http.StatusForbidden, "Player command failed: Restriction violated", "UNKNOWN")
+		return
+	}
+
+	s.state.Player.Playing = false
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleNext(w http.ResponseWriter, r *http.Request) {
+	if _, ok := s.targetDevice(w, r); !ok {
+		return
+	}
+
+	player := &s.state.Player
+
+	next := player.Item
+	if len(player.Queue) > 0 {
+		next = player.Queue[0]
+		player.Queue = player.Queue[1:]
+	} else if items := s.upNext(); len(items) > 0 {
+		i := slices.Index(items, player.Item) + 1
+		switch {
+		case i < len(items):
+			next = items[i]
+		case player.Repeat == "context":
+			next = items[0]
+		default:
+			player.Playing = false
+		}
+	}
+
+	if next != player.Item {
+		player.History = append(player.History, player.Item)
+		player.Item = next
+		player.Playing = true
+	}
+	player.ProgressMs = 0
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// upNext returns the items playing from, either the context or a list of URIs
+func (s *Server) upNext() []spotify.URI {
+	if s.state.Player.Context != "" {
+		return s.state.Catalog.ContextItems(s.state.Player.Context)
+	}
+	return s.state.Player.URIs
+}
+
+func (s *Server) handlePrevious(w http.ResponseWriter, r *http.Request) {
+	if _, ok := s.targetDevice(w, r); !ok {
+		return
+	}
+
+	player := &s.state.Player
+	if n := len(player.History); n > 0 {
+		player.Item = player.History[n-1]
+		player.History = player.History[:n-1]
+	}
+	player.ProgressMs = 0
+	player.Playing = true
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleSeek(w http.ResponseWriter, r *http.Request) {
+	if _, ok := s.targetDevice(w, r); !ok {
+		return
+	}
+
+	position, err := strconv.Atoi(r.URL.Query().Get("position_ms"))
+	if err != nil || position < 0 {
+		writeError(w, http.StatusBadRequest, "Invalid position_ms", "")
+		return
+	}
+
+	s.state.Player.ProgressMs = position
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleVolume(w http.ResponseWriter, r *http.Request) {
+	device, ok := s.targetDevice(w, r)
+	if !ok {
+		return
+	}
+
+	volume, err := strconv.Atoi(r.URL.Query().Get("volume_percent"))
+	if err != nil || volume < 0 || volume > 100 {
+		writeError(w, http.StatusBadRequest, "volume_percent must be in the range 0 to 100", "")
+		return
+	}
+
+	device.Volume = spotify.Numeric(volume)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleShuffle(w http.ResponseWriter, r *http.Request) {
+	if _, ok := s.targetDevice(w, r); !ok {
+		return
+	}
+
+	shuffle, err := strconv.ParseBool(r.URL.Query().Get("state"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid state", "")
+		return
+	}
+
+	s.state.Player.Shuffle = shuffle
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleRepeat(w http.ResponseWriter, r *http.Request) {
+	if _, ok := s.targetDevice(w, r); !ok {
+		return
+	}
+
+	state := r.URL.Query().Get("state")
+	if state != "off" && state != "track" && state != "context" {
+		writeError(w, http.StatusBadRequest, "State must be one of track, context or off", "")
+		return
+	}
+
+	s.state.Player.Repeat = state
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleQueue(w http.ResponseWriter, r *http.Request) {
+	queue := []any{}
+	for _, uri := range s.state.Player.Queue {
+		if item := s.resolve(uri); item != nil {
+			queue = append(queue, item)
+		}
+	}
+
+	writeJSON(w, http.StatusOK, struct {
+		CurrentlyPlaying any   `json:"currently_playing"`
+		Queue            []any `json:"queue"`
+	}{s.resolve(s.state.Player.Item), queue})
+}
+
+func (s *Server) handleAddToQueue(w http.ResponseWriter, r *http.Request) {
+	if _, ok := s.targetDevice(w, r); !ok {
+		return
+	}
+
+	uri := spotify.URI(r.URL.Query().Get("uri"))
+	if s.resolve(uri) == nil {
+		writeError(w, http.StatusBadRequest, "Invalid uri", "")
+		return
+	}
+
+	s.state.Player.Queue = append(s.state.Player.Queue, uri)
+
+	w.WriteHeader(http.StatusNoContent)
+}
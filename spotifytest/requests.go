@@ -0,0 +1,173 @@
+package spotifytest
+
+import (
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// Failure scripts the server to answer matching requests with an error
+// instead of handling them
+type Failure struct {
+	// Method and Path select the requests to fail, e.g. "PUT" and
+	// "/v1/me/player/play". Empty fields match any request.
+	Method string
+	Path   string
+
+	// Status is the HTTP status to answer with, such as 401, 403, 429 or 503
+	Status int
+	// Message is the error message; it defaults to the status text
+	Message string
+	// Reason is the Web API error reason, such as "NO_ACTIVE_DEVICE"
+	Reason string
+	// RetryAfter sets the Retry-After header, rounded up to whole seconds
+	RetryAfter time.Duration
+
+	// After is how many matching requests succeed before the failure applies
+	After int
+	// Times is how many matching requests fail; zero means one, and a
+	// negative value fails every matching request
+	Times int
+}
+
+// Fail queues a scripted failure. Failures are matched in the order they
+// were added, and each is dropped once it has been used Times times.
+func (s *Server) Fail(f Failure) {
+	if f.Times == 0 {
+		f.Times = 1
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.failures = append(s.failures, &f)
+}
+
+// ClearFailures drops every scripted failure still queued
+func (s *Server) ClearFailures() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.failures = nil
+}
+
+// matchFailure returns the first queued failure matching r and uses it up
+func (s *Server) matchFailure(r *http.Request) *Failure {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, f := range s.failures {
+		if (f.Method != "" && f.Method != r.Method) || (f.Path != "" && f.Path != r.URL.Path) {
+			continue
+		}
+
+		if f.After > 0 {
+			f.After--
+			continue
+		}
+
+		if f.Times > 0 {
+			f.Times--
+			if f.Times == 0 {
+				s.failures = append(s.failures[:i], s.failures[i+1:]...)
+			}
+		}
+		return f
+	}
+	return nil
+}
+
+// write answers with the failure, in the accounts service format when oauth is set
+func (f *Failure) write(w http.ResponseWriter, oauth bool) {
+	message := f.Message
+	if message == "" {
+		message = http.StatusText(f.Status)
+	}
+
+	if f.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(f.RetryAfter.Seconds()))))
+	}
+
+	if oauth {
+		code := "server_error"
+		if f.Status < 500 {
+			code = "invalid_request"
+		}
+		writeOAuthError(w, f.Status, code, message)
+		return
+	}
+	writeError(w, f.Status, message, f.Reason)
+}
+
+// Call is a request received by the server
+type Call struct {
+	Method string
+	Path   string
+	Query  url.Values
+	Header http.Header
+	Body   []byte
+	// Status is the HTTP status the server answered with
+	Status int
+}
+
+func (s *Server) record(call Call) {
+	s.mu.Lock()
+	s.calls = append(s.calls, call)
+	s.mu.Unlock()
+
+	if s.onCall != nil {
+		s.onCall(call)
+	}
+}
+
+// Calls returns every request received so far, in order
+func (s *Server) Calls() []Call {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Call(nil), s.calls...)
+}
+
+// CallsTo returns the requests received for the given method and path
+func (s *Server) CallsTo(method, path string) []Call {
+	var calls []Call
+	for _, call := range s.Calls() {
+		if call.Method == method && call.Path == path {
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
+
+// ResetCalls forgets the requests received so far
+func (s *Server) ResetCalls() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.calls = nil
+}
+
+// AssertCalled fails the test unless the server received exactly times
+// requests for the given method and path, and returns them
+func (s *Server) AssertCalled(t testing.TB, method, path string, times int) []Call {
+	t.Helper()
+
+	calls := s.CallsTo(method, path)
+	if len(calls) != times {
+		t.Errorf("spotifytest: got %d %s %s calls, want %d", len(calls), method, path, times)
+	}
+	return calls
+}
+
+// AssertNotCalled fails the test if the server received any request for the
+// given method and path
+func (s *Server) AssertNotCalled(t testing.TB, method, path string) {
+	t.Helper()
+
+	if calls := s.CallsTo(method, path); len(calls) > 0 {
+		t.Errorf("spotifytest: got %d unexpected %s %s calls", len(calls), method, path)
+	}
+}
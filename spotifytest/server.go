@@ -0,0 +1,298 @@
+// Package spotifytest provides a fake Spotify accounts service and Web API
+// for exercising spotifycli without a network connection or a Spotify account.
+//
+// The fake keeps an in-memory model of a user's devices, player and library,
+// issues and checks OAuth tokens, can be scripted to fail requests and records
+// every call it receives:
+//
+//	srv := spotifytest.NewServer()
+//	defer srv.Close()
+//
+//	token := srv.IssueToken()
+//	srv.Fail(spotifytest.Failure{Method: "GET", Path: "/v1/me/player", Status: 429, RetryAfter: time.Second})
+//
+// Point a profile's accounts_url at srv.AccountsURL() and its api_url at
+// srv.APIURL() to run spotifycli commands against it.
+package spotifytest
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AustinMusiku/spotifycli/internal/auth"
+)
+
+// Server is a fake Spotify accounts service and Web API
+type Server struct {
+	srv *httptest.Server
+	mux *http.ServeMux
+
+	clientID     string
+	clientSecret string
+	tokenTTL     time.Duration
+	rotate       bool
+	addr         string
+	onCall       func(Call)
+
+	mu       sync.Mutex
+	state    *State
+	codes    map[string]authCode
+	access   map[string]*accessToken
+	refresh  map[string]*refreshToken
+	failures []*Failure
+	calls    []Call
+}
+
+// Option configures a Server
+type Option func(*Server)
+
+// WithState starts the server with the given model instead of DefaultState
+func WithState(state *State) Option {
+	return func(s *Server) {
+		s.state = state
+	}
+}
+
+// WithClient makes the token endpoint accept only the given client. The
+// secret is checked for the client credentials grant; an empty secret
+// accepts any.
+func WithClient(clientID, clientSecret string) Option {
+	return func(s *Server) {
+		s.clientID = clientID
+		s.clientSecret = clientSecret
+	}
+}
+
+// WithTokenTTL sets how long issued access tokens are valid (default one hour)
+func WithTokenTTL(ttl time.Duration) Option {
+	return func(s *Server) {
+		s.tokenTTL = ttl
+	}
+}
+
+// WithRefreshTokenRotation makes every refresh return a new refresh token and
+// invalidate the old one, as Spotify does for some apps
+func WithRefreshTokenRotation() Option {
+	return func(s *Server) {
+		s.rotate = true
+	}
+}
+
+// WithAddress listens on the given address, such as "127.0.0.1:9000",
+// instead of a random local port
+func WithAddress(addr string) Option {
+	return func(s *Server) {
+		s.addr = addr
+	}
+}
+
+// WithCallHook calls fn after every request is handled
+func WithCallHook(fn func(Call)) Option {
+	return func(s *Server) {
+		s.onCall = fn
+	}
+}
+
+// NewServer starts a fake Spotify server on a local port. Close it when done.
+// It panics if it cannot listen, like httptest.NewServer.
+func NewServer(opts ...Option) *Server {
+	s := &Server{
+		tokenTTL: time.Hour,
+		codes:    make(map[string]authCode),
+		access:   make(map[string]*accessToken),
+		refresh:  make(map[string]*refreshToken),
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	if s.state == nil {
+		s.state = DefaultState()
+	}
+
+	s.mux = http.NewServeMux()
+	s.routes()
+	s.srv = httptest.NewUnstartedServer(http.HandlerFunc(s.serveHTTP))
+	if s.addr != "" {
+		l, err := net.Listen("tcp", s.addr)
+		if err != nil {
+			panic(fmt.Sprintf("spotifytest: failed to listen on %s: %v", s.addr, err))
+		}
+		s.srv.Listener.Close()
+		s.srv.Listener = l
+	}
+	s.srv.Start()
+
+	return s
+}
+
+// Close shuts the server down
+func (s *Server) Close() {
+	s.srv.Close()
+}
+
+// URL returns the base URL of the server
+func (s *Server) URL() string {
+	return s.srv.URL
+}
+
+// AccountsURL returns the base URL of the fake accounts service
+func (s *Server) AccountsURL() string {
+	return s.srv.URL
+}
+
+// APIURL returns the base URL of the fake Web API
+func (s *Server) APIURL() string {
+	return s.srv.URL + "/v1/"
+}
+
+// Update runs fn with exclusive access to the model, for setting up or
+// changing state between requests
+func (s *Server) Update(fn func(state *State)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fn(s.state)
+}
+
+func (s *Server) routes() {
+	s.mux.HandleFunc("GET /authorize", s.handleAuthorize)
+	s.mux.HandleFunc("POST /api/token", s.handleToken)
+
+	s.mux.HandleFunc("GET /v1/me", s.user(nil, s.handleMe))
+
+	s.mux.HandleFunc("GET /v1/me/player", s.user(anyOf(auth.ScopeReadPlaybackState), s.handlePlayerState))
+	s.mux.HandleFunc("PUT /v1/me/player", s.user(anyOf(auth.ScopeModifyPlaybackState), s.handleTransfer))
+	s.mux.HandleFunc("GET /v1/me/player/currently-playing", s.user(anyOf(auth.ScopeReadCurrentlyPlaying, auth.ScopeReadPlaybackState), s.handleCurrentlyPlaying))
+	s.mux.HandleFunc("GET /v1/me/player/devices", s.user(anyOf(auth.ScopeReadPlaybackState), s.handleDevices))
+	s.mux.HandleFunc("PUT /v1/me/player/play", s.user(anyOf(auth.ScopeModifyPlaybackState), s.handlePlay))
+	s.mux.HandleFunc("PUT /v1/me/player/pause", s.user(anyOf(auth.ScopeModifyPlaybackState), s.handlePause))
+	s.mux.HandleFunc("POST /v1/me/player/next", s.user(anyOf(auth.ScopeModifyPlaybackState), s.handleNext))
+	s.mux.HandleFunc("POST /v1/me/player/previous", s.user(anyOf(auth.ScopeModifyPlaybackState), s.handlePrevious))
+	s.mux.HandleFunc("PUT /v1/me/player/seek", s.user(anyOf(auth.ScopeModifyPlaybackState), s.handleSeek))
+	s.mux.HandleFunc("PUT /v1/me/player/volume", s.user(anyOf(auth.ScopeModifyPlaybackState), s.handleVolume))
+	s.mux.HandleFunc("PUT /v1/me/player/shuffle", s.user(anyOf(auth.ScopeModifyPlaybackState), s.handleShuffle))
+	s.mux.HandleFunc("PUT /v1/me/player/repeat", s.user(anyOf(auth.ScopeModifyPlaybackState), s.handleRepeat))
+	s.mux.HandleFunc("GET /v1/me/player/queue", s.user(anyOf(auth.ScopeReadPlaybackState), s.handleQueue))
+	s.mux.HandleFunc("POST /v1/me/player/queue", s.user(anyOf(auth.ScopeModifyPlaybackState), s.handleAddToQueue))
+
+	s.mux.HandleFunc("GET /v1/me/playlists", s.user(anyOf(auth.ScopePlaylistReadPrivate), s.handleSavedPlaylists))
+	s.mux.HandleFunc("GET /v1/me/tracks", s.user(anyOf(auth.ScopeLibraryRead), s.handleSavedTracks))
+	s.mux.HandleFunc("PUT /v1/me/tracks", s.user(anyOf(auth.ScopeLibraryModify), s.handleSave(kindTrack)))
+	s.mux.HandleFunc("DELETE /v1/me/tracks", s.user(anyOf(auth.ScopeLibraryModify), s.handleRemove(kindTrack)))
+	s.mux.HandleFunc("GET /v1/me/albums", s.user(anyOf(auth.ScopeLibraryRead), s.handleSavedAlbums))
+	s.mux.HandleFunc("PUT /v1/me/albums", s.user(anyOf(auth.ScopeLibraryModify), s.handleSave(kindAlbum)))
+	s.mux.HandleFunc("DELETE /v1/me/albums", s.user(anyOf(auth.ScopeLibraryModify), s.handleRemove(kindAlbum)))
+	s.mux.HandleFunc("GET /v1/me/shows", s.user(anyOf(auth.ScopeLibraryRead), s.handleSavedShows))
+	s.mux.HandleFunc("PUT /v1/me/shows", s.user(anyOf(auth.ScopeLibraryModify), s.handleSave(kindShow)))
+	s.mux.HandleFunc("DELETE /v1/me/shows", s.user(anyOf(auth.ScopeLibraryModify), s.handleRemove(kindShow)))
+
+	s.mux.HandleFunc("GET /v1/search", s.app(s.handleSearch))
+	s.mux.HandleFunc("GET /v1/tracks/{id}", s.app(s.handleTrack))
+	s.mux.HandleFunc("GET /v1/albums/{id}", s.app(s.handleAlbum))
+	s.mux.HandleFunc("GET /v1/artists/{id}", s.app(s.handleArtist))
+
+	s.mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, http.StatusNotFound, "Service not found", "")
+	})
+}
+
+// serveHTTP records the call and applies any scripted failure before routing
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+	defer func() {
+		s.record(Call{
+			Method: r.Method,
+			Path:   r.URL.Path,
+			Query:  r.URL.Query(),
+			Header: r.Header.Clone(),
+			Body:   body,
+			Status: rec.status,
+		})
+	}()
+
+	if f := s.matchFailure(r); f != nil {
+		f.write(rec, isAccountsPath(r.URL.Path))
+		return
+	}
+
+	s.mux.ServeHTTP(rec, r)
+}
+
+func isAccountsPath(path string) bool {
+	return !strings.HasPrefix(path, "/v1/")
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// apiError mirrors the error object the Web API returns
+type apiError struct {
+	Status  int    `json:"status"`
+	Message string `json:"message"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+func writeError(w http.ResponseWriter, status int, message, reason string) {
+	writeJSON(w, status, struct {
+		Error apiError `json:"error"`
+	}{apiError{Status: status, Message: message, Reason: reason}})
+}
+
+// writeOAuthError writes an error in the accounts service format
+func writeOAuthError(w http.ResponseWriter, status int, code, description string) {
+	writeJSON(w, status, struct {
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description,omitempty"`
+	}{code, description})
+}
+
+// writeCatalogJSON writes a catalog object or search result with an ETag,
+// answering 304 Not Modified when it matches If-None-Match as Spotify does
+func writeCatalogJSON(w http.ResponseWriter, r *http.Request, v any) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error(), "")
+		return
+	}
+
+	sum := sha256.Sum256(data)
+	etag := `"` + hex.EncodeToString(sum[:8]) + `"`
+	w.Header().Set("ETag", etag)
+
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(data)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
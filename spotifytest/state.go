@@ -0,0 +1,202 @@
+package spotifytest
+
+import (
+	"strings"
+	"time"
+
+	"github.com/zmb3/spotify/v2"
+)
+
+// State is the in-memory model behind the fake Web API. Change it through
+// Server.Update.
+type State struct {
+	User    spotify.PrivateUser
+	Devices []spotify.PlayerDevice
+	Player  Player
+	Catalog Catalog
+	Library Library
+}
+
+// Player is the playback state of the active device
+type Player struct {
+	// Item is the URI of the track or episode loaded, empty when nothing is
+	Item spotify.URI
+	// Context is the album, playlist, artist or show the item plays from
+	Context spotify.URI
+	// URIs are the items started without a context, played in order
+	URIs       []spotify.URI
+	ProgressMs int
+	Playing    bool
+	Shuffle    bool
+	// Repeat is "off", "track" or "context"
+	Repeat string
+	Queue  []spotify.URI
+	// History holds the items skipped past, most recent last
+	History []spotify.URI
+}
+
+// Catalog is everything the fake can search for and look up
+type Catalog struct {
+	Artists   []spotify.FullArtist
+	Albums    []spotify.FullAlbum
+	Tracks    []spotify.FullTrack
+	Playlists []Playlist
+	Shows     []spotify.FullShow
+	Episodes  []spotify.EpisodePage
+}
+
+// Playlist is a playlist and the URIs of its items
+type Playlist struct {
+	spotify.SimplePlaylist
+	Items []spotify.URI
+}
+
+// Library is the current user's saved items, most recently saved first
+type Library struct {
+	Playlists []spotify.ID
+	Tracks    []Saved
+	Albums    []Saved
+	Shows     []Saved
+}
+
+// Saved is an item in the user's library
+type Saved struct {
+	ID      spotify.ID
+	AddedAt time.Time
+}
+
+// ActiveDevice returns the active device, or nil when there is none
+func (s *State) ActiveDevice() *spotify.PlayerDevice {
+	for i := range s.Devices {
+		if s.Devices[i].Active {
+			return &s.Devices[i]
+		}
+	}
+	return nil
+}
+
+// Device returns the device with the given ID, or nil
+func (s *State) Device(id spotify.ID) *spotify.PlayerDevice {
+	for i := range s.Devices {
+		if s.Devices[i].ID == id {
+			return &s.Devices[i]
+		}
+	}
+	return nil
+}
+
+// Activate makes the device with the given ID the only active one
+func (s *State) Activate(id spotify.ID) bool {
+	if s.Device(id) == nil {
+		return false
+	}
+	for i := range s.Devices {
+		s.Devices[i].Active = s.Devices[i].ID == id
+	}
+	return true
+}
+
+// Track returns the catalog track with the given ID, or nil
+func (c *Catalog) Track(id spotify.ID) *spotify.FullTrack {
+	for i := range c.Tracks {
+		if c.Tracks[i].ID == id {
+			return &c.Tracks[i]
+		}
+	}
+	return nil
+}
+
+// Album returns the catalog album with the given ID, or nil
+func (c *Catalog) Album(id spotify.ID) *spotify.FullAlbum {
+	for i := range c.Albums {
+		if c.Albums[i].ID == id {
+			return &c.Albums[i]
+		}
+	}
+	return nil
+}
+
+// Artist returns the catalog artist with the given ID, or nil
+func (c *Catalog) Artist(id spotify.ID) *spotify.FullArtist {
+	for i := range c.Artists {
+		if c.Artists[i].ID == id {
+			return &c.Artists[i]
+		}
+	}
+	return nil
+}
+
+// Playlist returns the catalog playlist with the given ID, or nil
+func (c *Catalog) Playlist(id spotify.ID) *Playlist {
+	for i := range c.Playlists {
+		if c.Playlists[i].ID == id {
+			return &c.Playlists[i]
+		}
+	}
+	return nil
+}
+
+// Show returns the catalog show with the given ID, or nil
+func (c *Catalog) Show(id spotify.ID) *spotify.FullShow {
+	for i := range c.Shows {
+		if c.Shows[i].ID == id {
+			return &c.Shows[i]
+		}
+	}
+	return nil
+}
+
+// Episode returns the catalog episode with the given ID, or nil
+func (c *Catalog) Episode(id spotify.ID) *spotify.EpisodePage {
+	for i := range c.Episodes {
+		if c.Episodes[i].ID == id {
+			return &c.Episodes[i]
+		}
+	}
+	return nil
+}
+
+// ContextItems returns the playable items of an album, playlist, artist or
+// show URI, in order
+func (c *Catalog) ContextItems(uri spotify.URI) []spotify.URI {
+	kind, id := splitURI(uri)
+
+	var items []spotify.URI
+	switch kind {
+	case "album":
+		for _, track := range c.Tracks {
+			if track.Album.ID == id {
+				items = append(items, track.URI)
+			}
+		}
+	case "artist":
+		for _, track := range c.Tracks {
+			for _, artist := range track.Artists {
+				if artist.ID == id {
+					items = append(items, track.URI)
+					break
+				}
+			}
+		}
+	case "playlist":
+		if playlist := c.Playlist(id); playlist != nil {
+			items = append(items, playlist.Items...)
+		}
+	case "show":
+		for _, episode := range c.Episodes {
+			if episode.Show.ID == id {
+				items = append(items, episode.URI)
+			}
+		}
+	}
+	return items
+}
+
+// splitURI splits "spotify:track:abc" into "track" and "abc"
+func splitURI(uri spotify.URI) (string, spotify.ID) {
+	parts := strings.Split(string(uri), ":")
+	if len(parts) != 3 || parts[0] != "spotify" {
+		return "", ""
+	}
+	return parts[1], spotify.ID(parts[2])
+}